@@ -0,0 +1,254 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ListCheckpoints returns the checkpoints the CLI has written to the checkpoints/
+// directory of the session workspace, oldest first. A missing directory yields no
+// checkpoints.
+//
+// The CLI writes checkpoints of the conversation, e.g. when it compacts it (see
+// [CompactionResult.CheckpointNumber]), and [Session.CreateCheckpoint] adds snapshots
+// of the workspace files. Like [Session.Workspace], this is only useful when the CLI
+// runs locally.
+//
+// Returns an error if infinite sessions are not enabled for this session.
+//
+// Example:
+//
+//	checkpoints, err := session.ListCheckpoints()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, cp := range checkpoints {
+//	    fmt.Printf("#%d written %s\n", cp.Number, cp.CreatedAt.Format(time.Kitchen))
+//	}
+func (s *Session) ListCheckpoints() ([]Checkpoint, error) {
+	if err := s.requireWorkspace(); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(s.workspacePath, "checkpoints")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	var checkpoints []Checkpoint
+	for _, entry := range entries {
+		number, ok := checkpointNumber(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+		}
+		checkpoints = append(checkpoints, Checkpoint{
+			Number:    number,
+			Path:      filepath.Join(dir, entry.Name()),
+			CreatedAt: info.ModTime(),
+		})
+	}
+	slices.SortFunc(checkpoints, func(a, b Checkpoint) int { return a.Number - b.Number })
+	return checkpoints, nil
+}
+
+// ReadCheckpoint returns the contents of the checkpoint with the given number: the
+// conversation history the CLI recorded, or the workspace files for a checkpoint made
+// by [Session.CreateCheckpoint].
+//
+// Returns an error if infinite sessions are not enabled for this session or no such
+// checkpoint exists.
+func (s *Session) ReadCheckpoint(number int) (json.RawMessage, error) {
+	checkpoints, err := s.ListCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(checkpoints, func(cp Checkpoint) bool { return cp.Number == number })
+	if i < 0 {
+		return nil, fmt.Errorf("checkpoint %d not found", number)
+	}
+
+	data, err := os.ReadFile(checkpoints[i].Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("checkpoint %d is not valid JSON", number)
+	}
+	return json.RawMessage(data), nil
+}
+
+// CreateCheckpoint snapshots the workspace files/ directory and plan.md into the next
+// numbered file in checkpoints/, so they can be rolled back later with
+// [Session.RestoreCheckpoint]. Only the workspace is captured: the conversation and
+// files the agent edited outside the workspace are not.
+//
+// Returns an error if infinite sessions are not enabled for this session.
+//
+// Example:
+//
+//	before, err := session.CreateCheckpoint()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	// ... let the agent work, then undo its workspace changes
+//	err = session.RestoreCheckpoint(before.Number)
+func (s *Session) CreateCheckpoint() (*Checkpoint, error) {
+	checkpoints, err := s.ListCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	ws, err := s.Workspace()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := workspaceSnapshot{Files: map[string][]byte{}}
+	names, err := ws.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if snapshot.Files[name], err = ws.ReadFile(name); err != nil {
+			return nil, err
+		}
+	}
+	plan, err := os.ReadFile(filepath.Join(s.workspacePath, "plan.md"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	if err == nil {
+		text := string(plan)
+		snapshot.Plan = &text
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+
+	number := 1
+	if len(checkpoints) > 0 {
+		number = checkpoints[len(checkpoints)-1].Number + 1
+	}
+	dir := filepath.Join(s.workspacePath, "checkpoints")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+	// O_EXCL keeps a checkpoint the CLI wrote meanwhile from being overwritten
+	path := filepath.Join(dir, fmt.Sprintf("%03d.json", number))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+	return &Checkpoint{Number: number, Path: path, CreatedAt: info.ModTime()}, nil
+}
+
+// RestoreCheckpoint rolls the workspace files/ directory and plan.md back to a
+// checkpoint made with [Session.CreateCheckpoint]: files added since are removed and
+// changed files are rewritten. Checkpoints written by the CLI hold conversation history
+// rather than workspace files and can't be restored.
+//
+// Returns an error if infinite sessions are not enabled for this session or the
+// checkpoint doesn't exist or wasn't made by CreateCheckpoint.
+func (s *Session) RestoreCheckpoint(number int) error {
+	data, err := s.ReadCheckpoint(number)
+	if err != nil {
+		return err
+	}
+	var snapshot workspaceSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil || snapshot.Files == nil {
+		return fmt.Errorf("checkpoint %d was not created by CreateCheckpoint", number)
+	}
+	ws, err := s.Workspace()
+	if err != nil {
+		return err
+	}
+
+	names, err := ws.ListFiles()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, ok := snapshot.Files[name]; !ok {
+			if err := ws.RemoveFile(name); err != nil {
+				return err
+			}
+		}
+	}
+	for name, content := range snapshot.Files {
+		if err := ws.WriteFile(name, content); err != nil {
+			return err
+		}
+	}
+
+	planPath := filepath.Join(s.workspacePath, "plan.md")
+	if snapshot.Plan == nil {
+		err = os.Remove(planPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	} else {
+		err = os.WriteFile(planPath, []byte(*snapshot.Plan), 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore plan: %w", err)
+	}
+	return nil
+}
+
+// workspaceSnapshot is the contents of a checkpoint made by [Session.CreateCheckpoint]
+type workspaceSnapshot struct {
+	// Plan is the contents of plan.md, or nil if there was none
+	Plan *string `json:"plan,omitempty"`
+	// Files maps workspace file names to their contents
+	Files map[string][]byte `json:"files"`
+}
+
+// checkpointNumber parses a checkpoint file name such as "003.json"
+func checkpointNumber(name string) (int, bool) {
+	digits, ok := strings.CutSuffix(name, ".json")
+	if !ok || digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, false
+	}
+	number, err := strconv.Atoi(digits)
+	return number, err == nil
+}
+
+// requireWorkspace returns an error if the session has no infinite-session workspace
+func (s *Session) requireWorkspace() error {
+	if s.workspacePath == "" {
+		return fmt.Errorf("infinite sessions are not enabled for this session")
+	}
+	return nil
+}
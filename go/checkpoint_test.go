@@ -0,0 +1,109 @@
+package copilot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSession_Checkpoints(t *testing.T) {
+	t.Run("requires infinite sessions", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+
+		if _, err := session.ListCheckpoints(); err == nil {
+			t.Error("Expected error without a workspace")
+		}
+		if _, err := session.ReadCheckpoint(1); err == nil {
+			t.Error("Expected error without a workspace")
+		}
+	})
+
+	t.Run("returns no checkpoints before any are written", func(t *testing.T) {
+		session := newSession("session-1", nil, t.TempDir())
+
+		checkpoints, err := session.ListCheckpoints()
+		if err != nil || len(checkpoints) != 0 {
+			t.Errorf("Expected no checkpoints, got %v, %v", checkpoints, err)
+		}
+	})
+
+	t.Run("lists and reads checkpoint files in order", func(t *testing.T) {
+		workspace := t.TempDir()
+		dir := filepath.Join(workspace, "checkpoints")
+		os.MkdirAll(dir, 0o755)
+		for name, content := range map[string]string{
+			"010.json":  `{"n":10}`,
+			"002.json":  `{"n":2}`,
+			"notes.txt": "ignored",
+			"tmp.json":  "{}",
+		} {
+			os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		}
+		session := newSession("session-1", nil, workspace)
+
+		checkpoints, err := session.ListCheckpoints()
+		if err != nil {
+			t.Fatalf("ListCheckpoints failed: %v", err)
+		}
+		if len(checkpoints) != 2 || checkpoints[0].Number != 2 || checkpoints[1].Number != 10 {
+			t.Fatalf("Unexpected checkpoints: %+v", checkpoints)
+		}
+		if checkpoints[1].Path != filepath.Join(dir, "010.json") || checkpoints[1].CreatedAt.IsZero() {
+			t.Errorf("Unexpected checkpoint metadata: %+v", checkpoints[1])
+		}
+
+		data, err := session.ReadCheckpoint(10)
+		if err != nil || string(data) != `{"n":10}` {
+			t.Errorf("Expected checkpoint 10 contents, got %s, %v", data, err)
+		}
+		if _, err := session.ReadCheckpoint(3); err == nil {
+			t.Error("Expected error for a missing checkpoint")
+		}
+	})
+
+	t.Run("restores workspace files and plan from a created checkpoint", func(t *testing.T) {
+		workspace := t.TempDir()
+		session := newSession("session-1", nil, workspace)
+		ws, _ := session.Workspace()
+		ws.WriteFile("notes/todo.md", []byte("before"))
+		os.WriteFile(filepath.Join(workspace, "plan.md"), []byte("plan v1"), 0o644)
+		os.MkdirAll(filepath.Join(workspace, "checkpoints"), 0o755)
+		os.WriteFile(filepath.Join(workspace, "checkpoints", "004.json"), []byte(`{"messages":[]}`), 0o644)
+
+		checkpoint, err := session.CreateCheckpoint()
+		if err != nil {
+			t.Fatalf("CreateCheckpoint failed: %v", err)
+		}
+		if checkpoint.Number != 5 || checkpoint.Path != filepath.Join(workspace, "checkpoints", "005.json") {
+			t.Errorf("Expected checkpoint 5 after the CLI's 4, got %+v", checkpoint)
+		}
+
+		ws.WriteFile("notes/todo.md", []byte("after"))
+		ws.WriteFile("added.txt", []byte("new"))
+		os.WriteFile(filepath.Join(workspace, "plan.md"), []byte("plan v2"), 0o644)
+
+		if err := session.RestoreCheckpoint(5); err != nil {
+			t.Fatalf("RestoreCheckpoint failed: %v", err)
+		}
+		if data, _ := ws.ReadFile("notes/todo.md"); string(data) != "before" {
+			t.Errorf("Expected notes/todo.md to be restored, got %q", data)
+		}
+		if names, _ := ws.ListFiles(); len(names) != 1 {
+			t.Errorf("Expected files added after the checkpoint to be removed, got %v", names)
+		}
+		if plan, _ := session.GetPlan(); plan != "plan v1" {
+			t.Errorf("Expected the plan to be restored, got %q", plan)
+		}
+	})
+
+	t.Run("refuses to restore checkpoints written by the CLI", func(t *testing.T) {
+		workspace := t.TempDir()
+		os.MkdirAll(filepath.Join(workspace, "checkpoints"), 0o755)
+		os.WriteFile(filepath.Join(workspace, "checkpoints", "001.json"), []byte(`{"messages":[]}`), 0o644)
+		session := newSession("session-1", nil, workspace)
+
+		if err := session.RestoreCheckpoint(1); err == nil {
+			t.Error("Expected error restoring a CLI checkpoint")
+		}
+	})
+}
@@ -30,16 +30,25 @@ package copilot
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"net/url"
 	"os"
 	"os/exec"
+	"reflect"
 	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
@@ -71,20 +80,39 @@ type Client struct {
 	actualPort             int
 	actualHost             string
 	state                  ConnectionState
+	stateMux               sync.RWMutex
 	sessions               map[string]*Session
 	sessionsMux            sync.Mutex
 	isExternalServer       bool
-	conn                   net.Conn // stores net.Conn for external TCP connections
-	useStdio               bool     // resolved value from options
-	autoStart              bool     // resolved value from options
-	autoRestart            bool     // resolved value from options
+	conn                   io.ReadWriteCloser // stores the connection for external servers
+	webSocketURL           *url.URL           // set when CLIUrl uses the ws:// or wss:// scheme
+	useStdio               bool               // resolved value from options
+	autoStart              bool               // resolved value from options
+	autoRestart            bool               // resolved value from options
 	modelsCache            []ModelInfo
 	modelsCacheMux         sync.Mutex
 	lifecycleHandlers      []SessionLifecycleHandler
 	typedLifecycleHandlers map[SessionLifecycleEventType][]SessionLifecycleHandler
 	lifecycleHandlersMux   sync.Mutex
+	requestHandlers        map[string]RequestHandler // added with HandleRequest
+	requestHandlersMux     sync.Mutex
+	protocolVersion        int           // negotiated with the server during Start
+	eventLog               *eventLog     // set when ClientOptions.EventLog is
+	logger                 *slog.Logger  // ClientOptions.Logger or slog.Default()
+	processExitExpected    *atomic.Bool  // set before the client intentionally kills the CLI process
+	tokenRefresh           *tokenRefresh // the RefreshToken call in flight, if any
+	tokenRefreshMux        sync.Mutex
 }
 
+// tokenRefresh is an in-flight [Client.RefreshToken] call that concurrent callers share
+type tokenRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// tokenRefreshTimeout bounds the refresh the client starts after an authentication error
+const tokenRefreshTimeout = 30 * time.Second
+
 // NewClient creates a new Copilot CLI client with the given options.
 //
 // If options is nil, default options are used (spawns CLI server using stdio).
@@ -102,7 +130,6 @@ type Client struct {
 //	})
 func NewClient(options *ClientOptions) *Client {
 	opts := ClientOptions{
-		CLIPath:  "copilot",
 		Cwd:      "",
 		Port:     0,
 		LogLevel: "info",
@@ -125,14 +152,38 @@ func NewClient(options *ClientOptions) *Client {
 			panic("CLIUrl is mutually exclusive with UseStdio and CLIPath")
 		}
 
+		if options.Conn != nil && (options.CLIUrl != "" || options.UseStdio != nil || options.CLIPath != "" || options.Dial != nil) {
+			panic("Conn is mutually exclusive with CLIUrl, UseStdio, CLIPath, and Dial")
+		}
+
 		// Validate auth options with external server
 		if options.CLIUrl != "" && (options.GithubToken != "" || options.UseLoggedInUser != nil) {
 			panic("GithubToken and UseLoggedInUser cannot be used with CLIUrl (external server manages its own auth)")
 		}
+		if options.Conn != nil && (options.GithubToken != "" || options.UseLoggedInUser != nil) {
+			panic("GithubToken and UseLoggedInUser cannot be used with Conn (external server manages its own auth)")
+		}
+		if options.TokenProvider != nil && (options.CLIUrl != "" || options.Conn != nil) {
+			panic("TokenProvider cannot be used with CLIUrl or Conn (external server manages its own auth)")
+		}
+		if options.TokenProvider != nil && options.GithubToken != "" {
+			panic("GithubToken and TokenProvider are mutually exclusive")
+		}
+		switch options.Framing {
+		case "", FramingContentLength, FramingNDJSON:
+		default:
+			panic(fmt.Sprintf("Unknown Framing: %q", options.Framing))
+		}
 
 		// Parse CLIUrl if provided
 		if options.CLIUrl != "" {
-			host, port := parseCliUrl(options.CLIUrl)
+			var host string
+			var port int
+			if isWebSocketURL(options.CLIUrl) {
+				client.webSocketURL, host, port = parseWebSocketURL(options.CLIUrl)
+			} else {
+				host, port = parseCliUrl(options.CLIUrl)
+			}
 			client.actualHost = host
 			client.actualPort = port
 			client.isExternalServer = true
@@ -140,9 +191,22 @@ func NewClient(options *ClientOptions) *Client {
 			opts.CLIUrl = options.CLIUrl
 		}
 
-		if options.CLIPath != "" {
-			opts.CLIPath = options.CLIPath
+		// An injected connection always talks to an external server
+		if options.Conn != nil {
+			client.isExternalServer = true
+			client.useStdio = false
+			opts.Conn = options.Conn
 		}
+		opts.Dial = options.Dial
+		opts.Framing = options.Framing
+		opts.Trace = options.Trace
+		opts.MaxMessageSize = options.MaxMessageSize
+		opts.OnMessageDropped = options.OnMessageDropped
+		opts.HeartbeatInterval = options.HeartbeatInterval
+		opts.HeartbeatTimeout = options.HeartbeatTimeout
+
+		opts.CLIPath = options.CLIPath
+		opts.CLISources = options.CLISources
 		if options.Cwd != "" {
 			opts.Cwd = options.Cwd
 		}
@@ -169,9 +233,21 @@ func NewClient(options *ClientOptions) *Client {
 		if options.GithubToken != "" {
 			opts.GithubToken = options.GithubToken
 		}
+		opts.TokenProvider = options.TokenProvider
 		if options.UseLoggedInUser != nil {
 			opts.UseLoggedInUser = options.UseLoggedInUser
 		}
+		opts.OnProcessExit = options.OnProcessExit
+		opts.MinProtocolVersion = options.MinProtocolVersion
+		opts.MaxProtocolVersion = options.MaxProtocolVersion
+		opts.AllowProtocolMismatch = options.AllowProtocolMismatch
+		opts.EventQueueSize = options.EventQueueSize
+		opts.EventOverflow = options.EventOverflow
+		opts.PanicHandler = options.PanicHandler
+		opts.RepanicOnHandlerPanic = options.RepanicOnHandlerPanic
+		opts.Metrics = options.Metrics
+		opts.EventLog = options.EventLog
+		opts.Logger = options.Logger
 	}
 
 	// Default Env to current environment if not set
@@ -179,9 +255,12 @@ func NewClient(options *ClientOptions) *Client {
 		opts.Env = os.Environ()
 	}
 
-	// Check environment variable for CLI path
-	if cliPath := os.Getenv("COPILOT_CLI_PATH"); cliPath != "" {
-		opts.CLIPath = cliPath
+	client.logger = opts.Logger
+	if client.logger == nil {
+		client.logger = slog.Default()
+	}
+	if opts.EventLog != nil {
+		client.eventLog = newEventLog(opts.EventLog, client.logger)
 	}
 
 	client.options = opts
@@ -221,6 +300,37 @@ func parseCliUrl(url string) (string, int) {
 	return host, port
 }
 
+// isWebSocketURL reports whether a CLI URL selects the WebSocket transport
+func isWebSocketURL(cliUrl string) bool {
+	return strings.HasPrefix(cliUrl, "ws://") || strings.HasPrefix(cliUrl, "wss://")
+}
+
+// parseWebSocketURL parses a ws:// or wss:// CLI URL into the URL used for the
+// WebSocket handshake and the host and port to dial. The port defaults to 80 for
+// ws:// and 443 for wss://.
+//
+// Panics if the URL is invalid or the port is out of range.
+func parseWebSocketURL(cliUrl string) (*url.URL, string, int) {
+	u, err := url.Parse(cliUrl)
+	if err != nil || u.Hostname() == "" {
+		panic(fmt.Sprintf("Invalid CLIUrl: %s", cliUrl))
+	}
+
+	portStr := u.Port()
+	if portStr == "" {
+		portStr = "80"
+		if u.Scheme == "wss" {
+			portStr = "443"
+		}
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		panic(fmt.Sprintf("Invalid port in CLIUrl: %s", cliUrl))
+	}
+
+	return u, u.Hostname(), port
+}
+
 // Start starts the CLI server (if not using an external server) and establishes
 // a connection.
 //
@@ -239,33 +349,33 @@ func parseCliUrl(url string) (string, int) {
 //	}
 //	// Now ready to create sessions
 func (c *Client) Start(ctx context.Context) error {
-	if c.state == StateConnected {
+	if c.State() == StateConnected {
 		return nil
 	}
 
-	c.state = StateConnecting
+	c.setState(StateConnecting)
 
 	// Only start CLI server process if not connecting to external server
 	if !c.isExternalServer {
 		if err := c.startCLIServer(ctx); err != nil {
-			c.state = StateError
+			c.setState(StateError)
 			return err
 		}
 	}
 
 	// Connect to the server
 	if err := c.connectToServer(ctx); err != nil {
-		c.state = StateError
+		c.setState(StateError)
 		return err
 	}
 
 	// Verify protocol version compatibility
 	if err := c.verifyProtocolVersion(ctx); err != nil {
-		c.state = StateError
+		c.setState(StateError)
 		return err
 	}
 
-	c.state = StateConnected
+	c.setState(StateConnected)
 	return nil
 }
 
@@ -306,6 +416,7 @@ func (c *Client) Stop() error {
 
 	// Kill CLI process FIRST (this closes stdout and unblocks readLoop) - only if we spawned it
 	if c.process != nil && !c.isExternalServer {
+		c.processExitExpected.Store(true)
 		if err := c.process.Process.Kill(); err != nil {
 			errs = append(errs, fmt.Errorf("failed to kill CLI process: %w", err))
 		}
@@ -331,7 +442,8 @@ func (c *Client) Stop() error {
 	c.modelsCache = nil
 	c.modelsCacheMux.Unlock()
 
-	c.state = StateDisconnected
+	c.setState(StateDisconnected)
+	c.protocolVersion = 0
 	if !c.isExternalServer {
 		c.actualPort = 0
 	}
@@ -369,6 +481,7 @@ func (c *Client) ForceStop() {
 
 	// Kill CLI process (only if we spawned it)
 	if c.process != nil && !c.isExternalServer {
+		c.processExitExpected.Store(true)
 		c.process.Process.Kill() // Ignore errors
 		c.process = nil
 	}
@@ -390,7 +503,8 @@ func (c *Client) ForceStop() {
 	c.modelsCache = nil
 	c.modelsCacheMux.Unlock()
 
-	c.state = StateDisconnected
+	c.setState(StateDisconnected)
+	c.protocolVersion = 0
 	if !c.isExternalServer {
 		c.actualPort = 0
 	}
@@ -403,7 +517,7 @@ func (c *Client) ensureConnected() error {
 	if c.autoStart {
 		return c.Start(context.Background())
 	}
-	return fmt.Errorf("client not connected. Call Start() first")
+	return fmt.Errorf("%w. Call Start() first", ErrNotConnected)
 }
 
 // CreateSession creates a new conversation session with the Copilot CLI.
@@ -433,6 +547,11 @@ func (c *Client) ensureConnected() error {
 //	    },
 //	})
 func (c *Client) CreateSession(ctx context.Context, config *SessionConfig) (*Session, error) {
+	if config != nil {
+		if err := validateMCPServers(config.MCPServers, config.CustomAgents); err != nil {
+			return nil, err
+		}
+	}
 	if err := c.ensureConnected(); err != nil {
 		return nil, err
 	}
@@ -454,6 +573,7 @@ func (c *Client) CreateSession(ctx context.Context, config *SessionConfig) (*Ses
 		req.SkillDirectories = config.SkillDirectories
 		req.DisabledSkills = config.DisabledSkills
 		req.InfiniteSessions = config.InfiniteSessions
+		req.Labels = config.Labels
 
 		if config.Streaming {
 			req.Streaming = Bool(true)
@@ -464,17 +584,12 @@ func (c *Client) CreateSession(ctx context.Context, config *SessionConfig) (*Ses
 		if config.OnUserInputRequest != nil {
 			req.RequestUserInput = Bool(true)
 		}
-		if config.Hooks != nil && (config.Hooks.OnPreToolUse != nil ||
-			config.Hooks.OnPostToolUse != nil ||
-			config.Hooks.OnUserPromptSubmitted != nil ||
-			config.Hooks.OnSessionStart != nil ||
-			config.Hooks.OnSessionEnd != nil ||
-			config.Hooks.OnErrorOccurred != nil) {
+		if config.Hooks.hasHandlers() {
 			req.Hooks = Bool(true)
 		}
 	}
 
-	result, err := c.client.Request("session.create", req)
+	result, err := c.request(ctx, "session.create", req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
@@ -485,11 +600,16 @@ func (c *Client) CreateSession(ctx context.Context, config *SessionConfig) (*Ses
 	}
 
 	session := newSession(response.SessionID, c.client, response.WorkspacePath)
+	session.setEventQueue(c.options.EventQueueSize, c.options.EventOverflow)
+	session.setPanicHandler(c.options.PanicHandler, c.options.RepanicOnHandlerPanic)
+	session.setMetrics(c.options.Metrics)
+	session.logger = c.logger
 
 	if config != nil {
 		session.registerTools(config.Tools)
 		if config.OnPermissionRequest != nil {
 			session.registerPermissionHandler(config.OnPermissionRequest)
+			session.setPermissionTimeout(config.PermissionTimeout, config.PermissionTimeoutAction)
 		}
 		if config.OnUserInputRequest != nil {
 			session.registerUserInputHandler(config.OnUserInputRequest)
@@ -530,6 +650,11 @@ func (c *Client) ResumeSession(ctx context.Context, sessionID string) (*Session,
 //	    Tools: []copilot.Tool{myNewTool},
 //	})
 func (c *Client) ResumeSessionWithOptions(ctx context.Context, sessionID string, config *ResumeSessionConfig) (*Session, error) {
+	if config != nil {
+		if err := validateMCPServers(config.MCPServers, config.CustomAgents); err != nil {
+			return nil, err
+		}
+	}
 	if err := c.ensureConnected(); err != nil {
 		return nil, err
 	}
@@ -553,12 +678,7 @@ func (c *Client) ResumeSessionWithOptions(ctx context.Context, sessionID string,
 		if config.OnUserInputRequest != nil {
 			req.RequestUserInput = Bool(true)
 		}
-		if config.Hooks != nil && (config.Hooks.OnPreToolUse != nil ||
-			config.Hooks.OnPostToolUse != nil ||
-			config.Hooks.OnUserPromptSubmitted != nil ||
-			config.Hooks.OnSessionStart != nil ||
-			config.Hooks.OnSessionEnd != nil ||
-			config.Hooks.OnErrorOccurred != nil) {
+		if config.Hooks.hasHandlers() {
 			req.Hooks = Bool(true)
 		}
 		req.WorkingDirectory = config.WorkingDirectory
@@ -571,9 +691,10 @@ func (c *Client) ResumeSessionWithOptions(ctx context.Context, sessionID string,
 		req.SkillDirectories = config.SkillDirectories
 		req.DisabledSkills = config.DisabledSkills
 		req.InfiniteSessions = config.InfiniteSessions
+		req.Labels = config.Labels
 	}
 
-	result, err := c.client.Request("session.resume", req)
+	result, err := c.request(ctx, "session.resume", req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resume session: %w", err)
 	}
@@ -584,10 +705,15 @@ func (c *Client) ResumeSessionWithOptions(ctx context.Context, sessionID string,
 	}
 
 	session := newSession(response.SessionID, c.client, response.WorkspacePath)
+	session.setEventQueue(c.options.EventQueueSize, c.options.EventOverflow)
+	session.setPanicHandler(c.options.PanicHandler, c.options.RepanicOnHandlerPanic)
+	session.setMetrics(c.options.Metrics)
+	session.logger = c.logger
 	if config != nil {
 		session.registerTools(config.Tools)
 		if config.OnPermissionRequest != nil {
 			session.registerPermissionHandler(config.OnPermissionRequest)
+			session.setPermissionTimeout(config.PermissionTimeout, config.PermissionTimeoutAction)
 		}
 		if config.OnUserInputRequest != nil {
 			session.registerUserInputHandler(config.OnUserInputRequest)
@@ -595,6 +721,12 @@ func (c *Client) ResumeSessionWithOptions(ctx context.Context, sessionID string,
 		if config.Hooks != nil {
 			session.registerHooks(config.Hooks)
 		}
+		if config.OnEvent != nil {
+			session.On(config.OnEvent)
+		}
+		if config.ReplayHistory {
+			session.beginReplay()
+		}
 	} else {
 		session.registerTools(nil)
 	}
@@ -603,6 +735,17 @@ func (c *Client) ResumeSessionWithOptions(ctx context.Context, sessionID string,
 	c.sessions[response.SessionID] = session
 	c.sessionsMux.Unlock()
 
+	if config != nil && config.ReplayHistory {
+		history, err := session.GetMessages(ctx)
+		session.endReplay(history)
+		if err != nil {
+			c.sessionsMux.Lock()
+			delete(c.sessions, response.SessionID)
+			c.sessionsMux.Unlock()
+			return nil, fmt.Errorf("failed to replay session history: %w", err)
+		}
+	}
+
 	return session, nil
 }
 
@@ -621,11 +764,28 @@ func (c *Client) ResumeSessionWithOptions(ctx context.Context, sessionID string,
 //	    fmt.Printf("Session: %s\n", session.SessionID)
 //	}
 func (c *Client) ListSessions(ctx context.Context) ([]SessionMetadata, error) {
+	return c.ListSessionsWithOptions(ctx, nil)
+}
+
+// ListSessionsWithOptions returns metadata about the sessions known to the server that
+// match options. A nil options returns all sessions, like [Client.ListSessions].
+//
+// Example:
+//
+//	// Only this user's sessions
+//	sessions, err := client.ListSessionsWithOptions(ctx, &copilot.ListSessionsOptions{
+//	    Labels: map[string]string{"user": userID},
+//	})
+func (c *Client) ListSessionsWithOptions(ctx context.Context, options *ListSessionsOptions) ([]SessionMetadata, error) {
 	if err := c.ensureConnected(); err != nil {
 		return nil, err
 	}
 
-	result, err := c.client.Request("session.list", listSessionsRequest{})
+	req := listSessionsRequest{}
+	if options != nil {
+		req.Labels = options.Labels
+	}
+	result, err := c.request(ctx, "session.list", req)
 	if err != nil {
 		return nil, err
 	}
@@ -635,7 +795,23 @@ func (c *Client) ListSessions(ctx context.Context) ([]SessionMetadata, error) {
 		return nil, fmt.Errorf("failed to unmarshal sessions response: %w", err)
 	}
 
-	return response.Sessions, nil
+	if options == nil {
+		return response.Sessions, nil
+	}
+	// Filter locally as well, in case the server ignores the filter
+	return slices.DeleteFunc(response.Sessions, func(session SessionMetadata) bool {
+		return !options.matches(session)
+	}), nil
+}
+
+// matches reports whether session has all of the requested labels
+func (o *ListSessionsOptions) matches(session SessionMetadata) bool {
+	for key, value := range o.Labels {
+		if got, ok := session.Labels[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
 }
 
 // DeleteSession permanently deletes a session and all its conversation history.
@@ -653,7 +829,7 @@ func (c *Client) DeleteSession(ctx context.Context, sessionID string) error {
 		return err
 	}
 
-	result, err := c.client.Request("session.delete", deleteSessionRequest{SessionID: sessionID})
+	result, err := c.request(ctx, "session.delete", deleteSessionRequest{SessionID: sessionID})
 	if err != nil {
 		return err
 	}
@@ -668,6 +844,9 @@ func (c *Client) DeleteSession(ctx context.Context, sessionID string) error {
 		if response.Error != nil {
 			errorMsg = *response.Error
 		}
+		if isSessionNotFound(errorMsg) {
+			return fmt.Errorf("failed to delete session %s: %w", sessionID, ErrSessionNotFound)
+		}
 		return fmt.Errorf("failed to delete session %s: %s", sessionID, errorMsg)
 	}
 
@@ -700,11 +879,11 @@ func (c *Client) GetForegroundSessionID(ctx context.Context) (*string, error) {
 				return nil, err
 			}
 		} else {
-			return nil, fmt.Errorf("client not connected. Call Start() first")
+			return nil, fmt.Errorf("%w. Call Start() first", ErrNotConnected)
 		}
 	}
 
-	result, err := c.client.Request("session.getForeground", getForegroundSessionRequest{})
+	result, err := c.request(ctx, "session.getForeground", getForegroundSessionRequest{})
 	if err != nil {
 		return nil, err
 	}
@@ -734,11 +913,11 @@ func (c *Client) SetForegroundSessionID(ctx context.Context, sessionID string) e
 				return err
 			}
 		} else {
-			return fmt.Errorf("client not connected. Call Start() first")
+			return fmt.Errorf("%w. Call Start() first", ErrNotConnected)
 		}
 	}
 
-	result, err := c.client.Request("session.setForeground", setForegroundSessionRequest{SessionID: sessionID})
+	result, err := c.request(ctx, "session.setForeground", setForegroundSessionRequest{SessionID: sessionID})
 	if err != nil {
 		return err
 	}
@@ -860,9 +1039,18 @@ func (c *Client) handleLifecycleEvent(event SessionLifecycleEvent) {
 //	    session, err := client.CreateSession(context.Background(), nil)
 //	}
 func (c *Client) State() ConnectionState {
+	c.stateMux.RLock()
+	defer c.stateMux.RUnlock()
 	return c.state
 }
 
+// setState updates the connection state; the CLI process monitor may do so concurrently
+func (c *Client) setState(state ConnectionState) {
+	c.stateMux.Lock()
+	defer c.stateMux.Unlock()
+	c.state = state
+}
+
 // Ping sends a ping request to the server to verify connectivity.
 //
 // The message parameter is optional and will be echoed back in the response.
@@ -878,10 +1066,10 @@ func (c *Client) State() ConnectionState {
 //	}
 func (c *Client) Ping(ctx context.Context, message string) (*PingResponse, error) {
 	if c.client == nil {
-		return nil, fmt.Errorf("client not connected")
+		return nil, ErrNotConnected
 	}
 
-	result, err := c.client.Request("ping", pingRequest{Message: message})
+	result, err := c.request(ctx, "ping", pingRequest{Message: message})
 	if err != nil {
 		return nil, err
 	}
@@ -896,10 +1084,10 @@ func (c *Client) Ping(ctx context.Context, message string) (*PingResponse, error
 // GetStatus returns CLI status including version and protocol information
 func (c *Client) GetStatus(ctx context.Context) (*GetStatusResponse, error) {
 	if c.client == nil {
-		return nil, fmt.Errorf("client not connected")
+		return nil, ErrNotConnected
 	}
 
-	result, err := c.client.Request("status.get", getStatusRequest{})
+	result, err := c.request(ctx, "status.get", getStatusRequest{})
 	if err != nil {
 		return nil, err
 	}
@@ -914,10 +1102,10 @@ func (c *Client) GetStatus(ctx context.Context) (*GetStatusResponse, error) {
 // GetAuthStatus returns current authentication status
 func (c *Client) GetAuthStatus(ctx context.Context) (*GetAuthStatusResponse, error) {
 	if c.client == nil {
-		return nil, fmt.Errorf("client not connected")
+		return nil, ErrNotConnected
 	}
 
-	result, err := c.client.Request("auth.getStatus", getAuthStatusRequest{})
+	result, err := c.request(ctx, "auth.getStatus", getAuthStatusRequest{})
 	if err != nil {
 		return nil, err
 	}
@@ -929,13 +1117,212 @@ func (c *Client) GetAuthStatus(ctx context.Context) (*GetAuthStatusResponse, err
 	return &response, nil
 }
 
+// resolveGithubToken returns the token to pass to a spawned CLI server, consulting
+// the TokenProvider if one is configured.
+func (c *Client) resolveGithubToken(ctx context.Context) (string, error) {
+	if c.options.TokenProvider == nil {
+		return c.options.GithubToken, nil
+	}
+	token, err := c.options.TokenProvider.GetToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get GitHub token: %w", err)
+	}
+	return token, nil
+}
+
+// RefreshToken fetches a fresh token from the configured TokenProvider and
+// hands it to the running CLI server, without restarting it. Calls made while a
+// refresh is in flight wait for it and share its result instead of starting another.
+//
+// The client calls this automatically when a session reports an authentication
+// error. Call it directly to rotate credentials proactively, e.g. shortly before
+// a short-lived installation token expires.
+//
+// The token is sent with the auth.setToken request, which the SDK assumes the CLI
+// server provides; it is not part of the protocol the SDK was generated from. If the
+// server doesn't implement it, the error matches [ErrUnsupported], and a rotated token
+// only takes effect once the client is restarted with [Client.Stop] and [Client.Start],
+// which starts the CLI with a fresh token from the TokenProvider.
+func (c *Client) RefreshToken(ctx context.Context) error {
+	if c.options.TokenProvider == nil {
+		return fmt.Errorf("no TokenProvider configured")
+	}
+	if c.client == nil {
+		return ErrNotConnected
+	}
+
+	c.tokenRefreshMux.Lock()
+	if call := c.tokenRefresh; call != nil {
+		c.tokenRefreshMux.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &tokenRefresh{done: make(chan struct{})}
+	c.tokenRefresh = call
+	c.tokenRefreshMux.Unlock()
+
+	call.err = c.refreshToken(ctx)
+
+	c.tokenRefreshMux.Lock()
+	c.tokenRefresh = nil
+	c.tokenRefreshMux.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// refreshToken fetches a token and sends it to the CLI server
+func (c *Client) refreshToken(ctx context.Context) error {
+	token, err := c.resolveGithubToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.request(ctx, "auth.setToken", authSetTokenRequest{Token: token}); err != nil {
+		return fmt.Errorf("failed to refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetQuota returns the current quota snapshots for the authenticated account,
+// keyed by quota type (for example "premium_interactions").
+//
+// It sends account.getQuota, which is not part of SDK protocol version 2; the SDK
+// assumes the CLI server provides it. Servers without it return an error matching
+// [ErrUnsupported].
+func (c *Client) GetQuota(ctx context.Context) (map[string]QuotaSnapshot, error) {
+	if c.client == nil {
+		return nil, ErrNotConnected
+	}
+
+	result, err := c.request(ctx, "account.getQuota", getQuotaRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	var response getQuotaResponse
+	if err := json.Unmarshal(result, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quota response: %w", err)
+	}
+	return response.QuotaSnapshots, nil
+}
+
+// Login authenticates the CLI server using the GitHub device-code OAuth flow.
+//
+// The server starts the flow via auth.login and returns a device code, which is
+// passed to options.OnDeviceCode so the caller can show it to the user. Login then
+// polls the authentication status until the user completes the flow, the code
+// expires, or ctx is done.
+//
+// Example:
+//
+//	status, err := client.Login(context.Background(), &copilot.LoginOptions{
+//	    OnDeviceCode: func(code copilot.DeviceCode) {
+//	        fmt.Printf("Open %s and enter %s\n", code.VerificationURI, code.UserCode)
+//	    },
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Printf("Logged in as %s\n", *status.Login)
+func (c *Client) Login(ctx context.Context, options *LoginOptions) (*GetAuthStatusResponse, error) {
+	if err := c.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	var opts LoginOptions
+	if options != nil {
+		opts = *options
+	}
+
+	result, err := c.request(ctx, "auth.login", authLoginRequest{Host: opts.Host})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start login: %w", err)
+	}
+
+	var code DeviceCode
+	if err := json.Unmarshal(result, &code); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal login response: %w", err)
+	}
+
+	if opts.OnDeviceCode != nil {
+		opts.OnDeviceCode(code)
+	}
+
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = time.Duration(code.Interval) * time.Second
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	var expired <-chan time.Time
+	if code.ExpiresIn > 0 {
+		timer := time.NewTimer(time.Duration(code.ExpiresIn) * time.Second)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for login: %w", ctx.Err())
+		case <-expired:
+			return nil, fmt.Errorf("device code expired before login completed")
+		case <-ticker.C:
+			status, err := c.GetAuthStatus(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if status.IsAuthenticated {
+				return status, nil
+			}
+		}
+	}
+}
+
+// Logout signs the CLI server out, clearing its stored credentials.
+//
+// Returns the authentication status after signing out so callers can update
+// account-switching UI without a separate [Client.GetAuthStatus] call.
+//
+// Logout sends auth.logout, a request the SDK assumes the CLI server provides; it is
+// not part of SDK protocol version 2. A server that doesn't implement it returns an
+// error matching [ErrUnsupported].
+//
+// Example:
+//
+//	status, err := client.Logout(context.Background())
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Println("Authenticated:", status.IsAuthenticated)
+func (c *Client) Logout(ctx context.Context) (*GetAuthStatusResponse, error) {
+	if c.client == nil {
+		return nil, ErrNotConnected
+	}
+
+	if _, err := c.request(ctx, "auth.logout", authLogoutRequest{}); err != nil {
+		return nil, fmt.Errorf("failed to log out: %w", err)
+	}
+
+	return c.GetAuthStatus(ctx)
+}
+
 // ListModels returns available models with their metadata.
 //
 // Results are cached after the first successful call to avoid rate limiting.
 // The cache is cleared when the client disconnects.
 func (c *Client) ListModels(ctx context.Context) ([]ModelInfo, error) {
 	if c.client == nil {
-		return nil, fmt.Errorf("client not connected")
+		return nil, ErrNotConnected
 	}
 
 	// Use mutex for locking to prevent race condition with concurrent calls
@@ -951,7 +1338,7 @@ func (c *Client) ListModels(ctx context.Context) ([]ModelInfo, error) {
 	}
 
 	// Cache miss - fetch from backend while holding lock
-	result, err := c.client.Request("models.list", listModelsRequest{})
+	result, err := c.request(ctx, "models.list", listModelsRequest{})
 	if err != nil {
 		return nil, err
 	}
@@ -970,20 +1357,51 @@ func (c *Client) ListModels(ctx context.Context) ([]ModelInfo, error) {
 	return models, nil
 }
 
-// verifyProtocolVersion verifies that the server's protocol version matches the SDK's expected version
+// ProtocolVersion returns the protocol version negotiated with the server.
+//
+// Returns 0 if the client is not connected or the server did not report a version.
+func (c *Client) ProtocolVersion() int {
+	return c.protocolVersion
+}
+
+// verifyProtocolVersion verifies that the server's protocol version is within the range
+// supported by the SDK and records the negotiated version.
 func (c *Client) verifyProtocolVersion(ctx context.Context) error {
-	expectedVersion := GetSdkProtocolVersion()
 	pingResult, err := c.Ping(ctx, "")
 	if err != nil {
 		return err
 	}
 
-	if pingResult.ProtocolVersion == nil {
-		return fmt.Errorf("SDK protocol version mismatch: SDK expects version %d, but server does not report a protocol version. Please update your server to ensure compatibility", expectedVersion)
+	minVersion := c.options.MinProtocolVersion
+	if minVersion == 0 {
+		minVersion = MinSdkProtocolVersion
+	}
+	maxVersion := c.options.MaxProtocolVersion
+	if maxVersion == 0 {
+		maxVersion = GetSdkProtocolVersion()
 	}
 
-	if *pingResult.ProtocolVersion != expectedVersion {
-		return fmt.Errorf("SDK protocol version mismatch: SDK expects version %d, but server reports version %d. Please update your SDK or server to ensure compatibility", expectedVersion, *pingResult.ProtocolVersion)
+	if err := checkProtocolVersion(pingResult.ProtocolVersion, minVersion, maxVersion); err != nil {
+		if !c.options.AllowProtocolMismatch {
+			return err
+		}
+		c.logger.Warn("continuing despite protocol version mismatch", "error", err)
+	}
+
+	if pingResult.ProtocolVersion != nil {
+		c.protocolVersion = *pingResult.ProtocolVersion
+	}
+	return nil
+}
+
+// checkProtocolVersion reports an error if the server version is missing or outside [minVersion, maxVersion].
+func checkProtocolVersion(serverVersion *int, minVersion, maxVersion int) error {
+	if serverVersion == nil {
+		return fmt.Errorf("%w: SDK supports versions %d-%d, but server does not report a protocol version. Please update your server to ensure compatibility", ErrProtocolMismatch, minVersion, maxVersion)
+	}
+
+	if *serverVersion < minVersion || *serverVersion > maxVersion {
+		return fmt.Errorf("%w: SDK supports versions %d-%d, but server reports version %d. Please update your SDK or server to ensure compatibility", ErrProtocolMismatch, minVersion, maxVersion, *serverVersion)
 	}
 
 	return nil
@@ -994,6 +1412,12 @@ func (c *Client) verifyProtocolVersion(ctx context.Context) error {
 // This spawns the CLI server as a subprocess using the configured transport
 // mode (stdio or TCP).
 func (c *Client) startCLIServer(ctx context.Context) error {
+	cli, err := c.ResolveCLI()
+	if err != nil {
+		return err
+	}
+	c.logger.Debug("starting Copilot CLI", "path", cli.Path, "source", cli.Source)
+
 	args := []string{"--headless", "--no-auto-update", "--log-level", c.options.LogLevel}
 
 	// Choose transport mode
@@ -1004,29 +1428,34 @@ func (c *Client) startCLIServer(ctx context.Context) error {
 	}
 
 	// Add auth-related flags
-	if c.options.GithubToken != "" {
+	githubToken, err := c.resolveGithubToken(ctx)
+	if err != nil {
+		return err
+	}
+	if githubToken != "" {
 		args = append(args, "--auth-token-env", "COPILOT_SDK_AUTH_TOKEN")
 	}
-	// Default useLoggedInUser to false when GithubToken is provided
+	// Default useLoggedInUser to false when GithubToken or TokenProvider is provided
 	useLoggedInUser := true
 	if c.options.UseLoggedInUser != nil {
 		useLoggedInUser = *c.options.UseLoggedInUser
-	} else if c.options.GithubToken != "" {
+	} else if githubToken != "" {
 		useLoggedInUser = false
 	}
 	if !useLoggedInUser {
 		args = append(args, "--no-auto-login")
 	}
 
-	// If CLIPath is a .js file, run it with node
+	// If the CLI is a .js file, run it with node
 	// Note we can't rely on the shebang as Windows doesn't support it
-	command := c.options.CLIPath
-	if strings.HasSuffix(c.options.CLIPath, ".js") {
+	command := cli.Path
+	if strings.HasSuffix(cli.Path, ".js") {
 		command = "node"
-		args = append([]string{c.options.CLIPath}, args...)
+		args = append([]string{cli.Path}, args...)
 	}
 
 	c.process = exec.CommandContext(ctx, command, args...)
+	c.processExitExpected = &atomic.Bool{}
 
 	// Set working directory if specified
 	if c.options.Cwd != "" {
@@ -1035,8 +1464,8 @@ func (c *Client) startCLIServer(ctx context.Context) error {
 
 	// Add auth token if needed.
 	c.process.Env = c.options.Env
-	if c.options.GithubToken != "" {
-		c.process.Env = append(c.process.Env, "COPILOT_SDK_AUTH_TOKEN="+c.options.GithubToken)
+	if githubToken != "" {
+		c.process.Env = append(c.process.Env, "COPILOT_SDK_AUTH_TOKEN="+githubToken)
 	}
 
 	if c.useStdio {
@@ -1056,23 +1485,15 @@ func (c *Client) startCLIServer(ctx context.Context) error {
 			return fmt.Errorf("failed to create stderr pipe: %w", err)
 		}
 
-		// Read stderr in background
-		go func() {
-			scanner := bufio.NewScanner(stderr)
-			for scanner.Scan() {
-				// Optionally log stderr
-				// fmt.Fprintf(os.Stderr, "CLI stderr: %s\n", scanner.Text())
-			}
-		}()
-
 		if err := c.process.Start(); err != nil {
 			return fmt.Errorf("failed to start CLI server: %w", err)
 		}
 
 		// Create JSON-RPC client immediately
-		c.client = jsonrpc2.NewClient(stdin, stdout)
+		c.client = jsonrpc2.NewStreamClient(c.newStream(stdout, stdin))
 		c.setupNotificationHandler()
 		c.client.Start()
+		go c.monitorProcess(c.process, stderr, c.client.Done(), c.processExitExpected)
 
 		return nil
 	} else {
@@ -1082,9 +1503,15 @@ func (c *Client) startCLIServer(ctx context.Context) error {
 			return fmt.Errorf("failed to create stdout pipe: %w", err)
 		}
 
+		stderr, err := c.process.StderrPipe()
+		if err != nil {
+			return fmt.Errorf("failed to create stderr pipe: %w", err)
+		}
+
 		if err := c.process.Start(); err != nil {
 			return fmt.Errorf("failed to start CLI server: %w", err)
 		}
+		go c.monitorProcess(c.process, stderr, nil, c.processExitExpected)
 
 		// Wait for port announcement
 		scanner := bufio.NewScanner(stdout)
@@ -1112,6 +1539,75 @@ func (c *Client) startCLIServer(ctx context.Context) error {
 	}
 }
 
+// stderrTailLines is the number of trailing stderr lines kept for ProcessExitEvent.
+const stderrTailLines = 20
+
+// monitorProcess drains the CLI process's stderr, waits for the process to exit,
+// and reports unexpected exits to the OnProcessExit handler.
+//
+// If stdoutDone is non-nil, waiting for the process is deferred until it is closed,
+// since reaping the process closes the stdout pipe still being read by the JSON-RPC client.
+func (c *Client) monitorProcess(process *exec.Cmd, stderr io.Reader, stdoutDone <-chan struct{}, exitExpected *atomic.Bool) {
+	// Keep only the last stderrTailLines lines
+	var tail []string
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		tail = append(tail, scanner.Text())
+		if len(tail) > stderrTailLines {
+			tail = tail[1:]
+		}
+	}
+
+	if stdoutDone != nil {
+		<-stdoutDone
+	}
+
+	waitErr := process.Wait()
+	if exitExpected.Load() {
+		return
+	}
+
+	c.setState(StateError)
+
+	event := ProcessExitEvent{
+		ExitCode: -1,
+		Stderr:   tail,
+	}
+	if process.ProcessState != nil {
+		event.ExitCode = process.ProcessState.ExitCode()
+	}
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		event.Err = waitErr
+	}
+
+	if handler := c.options.OnProcessExit; handler != nil {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					c.handleProcessExitPanic(handler, r)
+				}
+			}()
+			handler(event)
+		}()
+	}
+}
+
+// handleProcessExitPanic reports a panic recovered from the OnProcessExit handler
+// through PanicHandler, like panics in session event handlers. It is never re-raised,
+// since it happens on the client's own goroutine.
+func (c *Client) handleProcessExitPanic(handler ProcessExitHandler, value any) {
+	if c.options.PanicHandler == nil {
+		c.logger.Error("process exit handler panicked", "panic", value, "stack", string(debug.Stack()))
+		return
+	}
+	c.options.PanicHandler(HandlerPanic{
+		HandlerName: runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name(),
+		Value:       value,
+		Stack:       debug.Stack(),
+	})
+}
+
 // connectToServer establishes a connection to the server.
 func (c *Client) connectToServer(ctx context.Context) error {
 	if c.useStdio {
@@ -1119,6 +1615,14 @@ func (c *Client) connectToServer(ctx context.Context) error {
 		return nil
 	}
 
+	if c.options.Conn != nil {
+		c.conn = c.options.Conn
+		c.client = jsonrpc2.NewStreamClient(c.newStream(c.conn, c.conn))
+		c.setupNotificationHandler()
+		c.client.Start()
+		return nil
+	}
+
 	// Connect via TCP
 	return c.connectViaTcp(ctx)
 }
@@ -1131,38 +1635,108 @@ func (c *Client) connectViaTcp(ctx context.Context) error {
 
 	// Create TCP connection that cancels on context done or after 10 seconds
 	address := net.JoinHostPort(c.actualHost, fmt.Sprintf("%d", c.actualPort))
-	dialer := net.Dialer{
-		Timeout: 10 * time.Second,
+	dial := c.options.Dial
+	if dial == nil {
+		dialer := net.Dialer{
+			Timeout: 10 * time.Second,
+		}
+		dial = dialer.DialContext
 	}
-	conn, err := dialer.DialContext(ctx, "tcp", address)
+	conn, err := dial(ctx, "tcp", address)
 	if err != nil {
 		return fmt.Errorf("failed to connect to CLI server at %s: %w", address, err)
 	}
 
+	if c.webSocketURL != nil {
+		return c.connectViaWebSocket(ctx, conn)
+	}
+
 	c.conn = conn
 
 	// Create JSON-RPC client with the connection
-	c.client = jsonrpc2.NewClient(conn, conn)
+	c.client = jsonrpc2.NewStreamClient(c.newStream(conn, conn))
 	c.setupNotificationHandler()
 	c.client.Start()
 
 	return nil
 }
 
+// newStream frames JSON-RPC messages over r and w as selected by ClientOptions.Framing
+func (c *Client) newStream(r io.ReadCloser, w io.Writer) jsonrpc2.Stream {
+	if c.options.Framing == FramingNDJSON {
+		return jsonrpc2.NewNDJSONStream(r, w, c.options.MaxMessageSize)
+	}
+	return jsonrpc2.NewHeaderStream(r, w, c.options.MaxMessageSize)
+}
+
+// connectViaWebSocket upgrades a TCP connection to the CLI server to a WebSocket,
+// negotiating TLS first for wss:// URLs.
+func (c *Client) connectViaWebSocket(ctx context.Context, conn net.Conn) error {
+	if c.webSocketURL.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: c.webSocketURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to establish TLS with CLI server at %s: %w", c.webSocketURL.Host, err)
+		}
+		conn = tlsConn
+	}
+
+	stream, err := jsonrpc2.NewWebSocketStream(ctx, conn, c.webSocketURL, c.options.MaxMessageSize)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to CLI server at %s: %w", c.webSocketURL, err)
+	}
+
+	c.conn = conn
+	c.client = jsonrpc2.NewStreamClient(stream)
+	c.setupNotificationHandler()
+	c.client.Start()
+
+	return nil
+}
+
+// request sends a request to the CLI server, converting error responses to [*RPCError]
+func (c *Client) request(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	result, err := c.client.Request(ctx, method, params)
+	return result, wrapRPCError(err)
+}
+
 // setupNotificationHandler configures handlers for session events, tool calls, and permission requests.
 func (c *Client) setupNotificationHandler() {
+	c.client.SetLogger(c.logger)
+	if c.options.Trace != nil {
+		c.client.SetTraceFunc(c.options.Trace)
+	}
+	if onDropped := c.options.OnMessageDropped; onDropped != nil {
+		c.client.SetDroppedMessageHandler(onDropped)
+	}
+	if interval := c.options.HeartbeatInterval; interval > 0 {
+		timeout := c.options.HeartbeatTimeout
+		if timeout <= 0 {
+			timeout = interval
+		}
+		c.client.SetHeartbeat("ping", pingRequest{}, interval, timeout)
+	}
 	c.client.SetRequestHandler("session.event", jsonrpc2.NotificationHandlerFor(c.handleSessionEvent))
 	c.client.SetRequestHandler("session.lifecycle", jsonrpc2.NotificationHandlerFor(c.handleLifecycleEvent))
 	c.client.SetRequestHandler("tool.call", jsonrpc2.RequestHandlerFor(c.handleToolCallRequest))
 	c.client.SetRequestHandler("permission.request", jsonrpc2.RequestHandlerFor(c.handlePermissionRequest))
 	c.client.SetRequestHandler("userInput.request", jsonrpc2.RequestHandlerFor(c.handleUserInputRequest))
 	c.client.SetRequestHandler("hooks.invoke", jsonrpc2.RequestHandlerFor(c.handleHooksInvoke))
+	c.setupCustomHandlers()
 }
 
 func (c *Client) handleSessionEvent(req sessionEventRequest) {
 	if req.SessionID == "" {
 		return
 	}
+	if c.eventLog != nil {
+		c.eventLog.record(req.SessionID, req.Event)
+	}
+	if c.options.Metrics != nil {
+		recordEventMetrics(c.options.Metrics, req.SessionID, req.Event)
+	}
+
 	// Dispatch to session
 	c.sessionsMux.Lock()
 	session, ok := c.sessions[req.SessionID]
@@ -1171,10 +1745,23 @@ func (c *Client) handleSessionEvent(req sessionEventRequest) {
 	if ok {
 		session.dispatchEvent(req.Event)
 	}
+
+	// Rotate credentials when the server reports an authentication failure
+	if req.Event.Type == SessionError && req.Event.Data.ErrorType != nil && *req.Event.Data.ErrorType == "authentication" &&
+		c.options.TokenProvider != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), tokenRefreshTimeout)
+			defer cancel()
+			// Persistent auth failures resurface on the next request, so only log the error
+			if err := c.RefreshToken(ctx); err != nil {
+				c.logger.Warn("failed to refresh token after authentication error", "session", req.SessionID, "error", err)
+			}
+		}()
+	}
 }
 
 // handleToolCallRequest handles a tool call request from the CLI server.
-func (c *Client) handleToolCallRequest(req toolCallRequest) (*toolCallResponse, *jsonrpc2.Error) {
+func (c *Client) handleToolCallRequest(ctx context.Context, req toolCallRequest) (*toolCallResponse, *jsonrpc2.Error) {
 	if req.SessionID == "" || req.ToolCallID == "" || req.ToolName == "" {
 		return nil, &jsonrpc2.Error{Code: -32602, Message: "invalid tool call payload"}
 	}
@@ -1191,12 +1778,15 @@ func (c *Client) handleToolCallRequest(req toolCallRequest) (*toolCallResponse,
 		return &toolCallResponse{Result: buildUnsupportedToolResult(req.ToolName)}, nil
 	}
 
-	result := c.executeToolCall(req.SessionID, req.ToolCallID, req.ToolName, req.Arguments, handler)
+	ctx, cancel := session.handlerContext(ctx)
+	defer cancel()
+	result := c.executeToolCall(ctx, req.SessionID, req.ToolCallID, req.ToolName, req.Arguments, handler)
 	return &toolCallResponse{Result: result}, nil
 }
 
 // executeToolCall executes a tool handler and returns the result.
 func (c *Client) executeToolCall(
+	ctx context.Context,
 	sessionID, toolCallID, toolName string,
 	arguments any,
 	handler ToolHandler,
@@ -1208,15 +1798,34 @@ func (c *Client) executeToolCall(
 		Arguments:  arguments,
 	}
 
+	// Deferred first so it sees the result set by panic recovery
+	if metrics := c.options.Metrics; metrics != nil {
+		start := time.Now()
+		defer func() {
+			recordToolMetrics(metrics, toolName, time.Since(start), result)
+		}()
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
 			result = buildFailedToolResult(fmt.Sprintf("tool panic: %v", r))
 		}
 	}()
 
+	ctx = context.WithValue(ctx, toolProgressKey{}, func(progress ToolProgress) error {
+		c.sessionsMux.Lock()
+		session, ok := c.sessions[sessionID]
+		c.sessionsMux.Unlock()
+		if !ok {
+			return ErrSessionNotFound
+		}
+		session.dispatchToolProgress(toolCallID, progress)
+		return nil
+	})
+
 	if handler != nil {
 		var err error
-		result, err = handler(invocation)
+		result, err = handler(ctx, invocation)
 		if err != nil {
 			result = buildFailedToolResult(err.Error())
 		}
@@ -1225,8 +1834,39 @@ func (c *Client) executeToolCall(
 	return result
 }
 
+// toolProgressKey is the context key for the running tool call's progress reporter
+type toolProgressKey struct{}
+
+// ReportToolProgress delivers an intermediate status update for the tool call running
+// with ctx to the session's event handlers, as ephemeral tool.execution_progress and
+// tool.execution_partial_result events. ctx must be the context passed to the tool
+// handler.
+//
+// The updates stay in the SDK: the CLI protocol has no request for reporting tool
+// progress, so the CLI and the model don't see them.
+//
+// Example:
+//
+//	for i, file := range files {
+//	    copilot.ReportToolProgress(ctx, copilot.ToolProgress{
+//	        Message: fmt.Sprintf("Scanning %s", file),
+//	        Percent: copilot.Float64(float64(i) / float64(len(files)) * 100),
+//	    })
+//	    scan(ctx, file)
+//	}
+func ReportToolProgress(ctx context.Context, progress ToolProgress) error {
+	report, ok := ctx.Value(toolProgressKey{}).(func(ToolProgress) error)
+	if !ok {
+		return fmt.Errorf("no tool call is associated with this context")
+	}
+	if err := report(progress); err != nil {
+		return fmt.Errorf("failed to report tool progress: %w", err)
+	}
+	return nil
+}
+
 // handlePermissionRequest handles a permission request from the CLI server.
-func (c *Client) handlePermissionRequest(req permissionRequestRequest) (*permissionRequestResponse, *jsonrpc2.Error) {
+func (c *Client) handlePermissionRequest(ctx context.Context, req permissionRequestRequest) (*permissionRequestResponse, *jsonrpc2.Error) {
 	if req.SessionID == "" {
 		return nil, &jsonrpc2.Error{Code: -32602, Message: "invalid permission request payload"}
 	}
@@ -1238,21 +1878,20 @@ func (c *Client) handlePermissionRequest(req permissionRequestRequest) (*permiss
 		return nil, &jsonrpc2.Error{Code: -32602, Message: fmt.Sprintf("unknown session %s", req.SessionID)}
 	}
 
-	result, err := session.handlePermissionRequest(req.Request)
+	result, err := session.handlePermissionRequest(ctx, req.Request)
 	if err != nil {
 		// Return denial on error
-		return &permissionRequestResponse{
-			Result: PermissionRequestResult{
-				Kind: "denied-no-approval-rule-and-could-not-request-from-user",
-			},
-		}, nil
+		result = PermissionRequestResult{Kind: "denied-no-approval-rule-and-could-not-request-from-user"}
+	}
+	if c.options.Metrics != nil && result.Kind != "approved" {
+		c.options.Metrics.Add(MetricPermissionDenials, 1, map[string]string{"permission.kind": req.Request.Kind, "result": result.Kind})
 	}
 
 	return &permissionRequestResponse{Result: result}, nil
 }
 
 // handleUserInputRequest handles a user input request from the CLI server.
-func (c *Client) handleUserInputRequest(req userInputRequest) (*userInputResponse, *jsonrpc2.Error) {
+func (c *Client) handleUserInputRequest(ctx context.Context, req userInputRequest) (*userInputResponse, *jsonrpc2.Error) {
 	if req.SessionID == "" || req.Question == "" {
 		return nil, &jsonrpc2.Error{Code: -32602, Message: "invalid user input request payload"}
 	}
@@ -1264,7 +1903,7 @@ func (c *Client) handleUserInputRequest(req userInputRequest) (*userInputRespons
 		return nil, &jsonrpc2.Error{Code: -32602, Message: fmt.Sprintf("unknown session %s", req.SessionID)}
 	}
 
-	response, err := session.handleUserInputRequest(UserInputRequest{
+	response, err := session.handleUserInputRequest(ctx, UserInputRequest{
 		Question:      req.Question,
 		Choices:       req.Choices,
 		AllowFreeform: req.AllowFreeform,
@@ -1277,7 +1916,7 @@ func (c *Client) handleUserInputRequest(req userInputRequest) (*userInputRespons
 }
 
 // handleHooksInvoke handles a hooks invocation from the CLI server.
-func (c *Client) handleHooksInvoke(req hooksInvokeRequest) (map[string]any, *jsonrpc2.Error) {
+func (c *Client) handleHooksInvoke(_ context.Context, req hooksInvokeRequest) (map[string]any, *jsonrpc2.Error) {
 	if req.SessionID == "" || req.Type == "" {
 		return nil, &jsonrpc2.Error{Code: -32602, Message: "invalid hooks invoke payload"}
 	}
@@ -1311,6 +1950,16 @@ func buildFailedToolResult(internalError string) ToolResult {
 	}
 }
 
+// buildTimedOutToolResult creates a failure ToolResult for a tool that exceeded its timeout.
+func buildTimedOutToolResult(toolName string, timeout time.Duration) ToolResult {
+	return ToolResult{
+		TextResultForLLM: fmt.Sprintf("Tool '%s' did not complete within %s and was cancelled.", toolName, timeout),
+		ResultType:       "failure",
+		Error:            fmt.Sprintf("tool '%s' timed out after %s", toolName, timeout),
+		ToolTelemetry:    map[string]any{},
+	}
+}
+
 // buildUnsupportedToolResult creates a failure ToolResult for an unsupported tool.
 func buildUnsupportedToolResult(toolName string) ToolResult {
 	return ToolResult{
@@ -1,11 +1,26 @@
 package copilot
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
 )
 
 // This file is for unit tests. Where relevant, prefer to add e2e tests in e2e/*.test.go instead
@@ -31,7 +46,7 @@ func TestClient_HandleToolCallRequest(t *testing.T) {
 			ToolName:   "missing_tool",
 			Arguments:  map[string]any{},
 		}
-		response, _ := client.handleToolCallRequest(params)
+		response, _ := client.handleToolCallRequest(t.Context(), params)
 
 		if response.Result.ResultType != "failure" {
 			t.Errorf("Expected resultType to be 'failure', got %q", response.Result.ResultType)
@@ -176,6 +191,36 @@ func TestClient_URLParsing(t *testing.T) {
 		})
 	})
 
+	t.Run("should reject an unknown Framing", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for unknown Framing")
+			}
+		}()
+
+		NewClient(&ClientOptions{Framing: "xml"})
+	})
+
+	t.Run("should parse WebSocket URLs", func(t *testing.T) {
+		client := NewClient(&ClientOptions{
+			CLIUrl: "wss://copilot.example.com/rpc",
+		})
+
+		if client.actualHost != "copilot.example.com" || client.actualPort != 443 {
+			t.Errorf("Expected copilot.example.com:443, got %s:%d", client.actualHost, client.actualPort)
+		}
+		if client.webSocketURL == nil || client.webSocketURL.Path != "/rpc" {
+			t.Errorf("Expected WebSocket URL with path /rpc, got %v", client.webSocketURL)
+		}
+
+		client = NewClient(&ClientOptions{
+			CLIUrl: "ws://127.0.0.1:9000",
+		})
+		if client.actualHost != "127.0.0.1" || client.actualPort != 9000 || client.webSocketURL == nil {
+			t.Errorf("Expected ws://127.0.0.1:9000, got %s:%d", client.actualHost, client.actualPort)
+		}
+	})
+
 	t.Run("should throw error when CLIUrl is used with UseStdio", func(t *testing.T) {
 		defer func() {
 			if r := recover(); r == nil {
@@ -377,6 +422,628 @@ func TestClient_EnvOptions(t *testing.T) {
 	})
 }
 
+func TestClient_ProtocolVersion(t *testing.T) {
+	version := func(v int) *int { return &v }
+
+	t.Run("accepts versions within the supported range", func(t *testing.T) {
+		for _, v := range []int{2, 3, 4} {
+			if err := checkProtocolVersion(version(v), 2, 4); err != nil {
+				t.Errorf("Expected version %d to be accepted, got %v", v, err)
+			}
+		}
+	})
+
+	t.Run("rejects versions outside the supported range", func(t *testing.T) {
+		for _, v := range []int{1, 5} {
+			err := checkProtocolVersion(version(v), 2, 4)
+			if err == nil {
+				t.Fatalf("Expected version %d to be rejected", v)
+			}
+			matched, _ := regexp.MatchString("SDK supports versions 2-4", err.Error())
+			if !matched {
+				t.Errorf("Expected error to mention supported range, got %v", err)
+			}
+			if !errors.Is(err, ErrProtocolMismatch) {
+				t.Errorf("Expected ErrProtocolMismatch, got %v", err)
+			}
+		}
+	})
+
+	t.Run("rejects a missing server version", func(t *testing.T) {
+		if err := checkProtocolVersion(nil, 2, 2); !errors.Is(err, ErrProtocolMismatch) {
+			t.Errorf("Expected ErrProtocolMismatch, got %v", err)
+		}
+	})
+
+	t.Run("should store protocol range options", func(t *testing.T) {
+		client := NewClient(&ClientOptions{
+			MinProtocolVersion:    2,
+			MaxProtocolVersion:    5,
+			AllowProtocolMismatch: true,
+		})
+
+		if client.options.MinProtocolVersion != 2 || client.options.MaxProtocolVersion != 5 {
+			t.Errorf("Expected protocol range 2-5, got %d-%d", client.options.MinProtocolVersion, client.options.MaxProtocolVersion)
+		}
+		if !client.options.AllowProtocolMismatch {
+			t.Error("Expected AllowProtocolMismatch to be true")
+		}
+		if client.ProtocolVersion() != 0 {
+			t.Errorf("Expected no negotiated version before Start, got %d", client.ProtocolVersion())
+		}
+	})
+}
+
+func TestClient_ProcessExit(t *testing.T) {
+	startHelper := func(t *testing.T, exitCode int) (*exec.Cmd, io.Reader) {
+		t.Helper()
+		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+		cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1", fmt.Sprintf("HELPER_EXIT_CODE=%d", exitCode))
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			t.Fatalf("Failed to create stderr pipe: %v", err)
+		}
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Failed to start helper process: %v", err)
+		}
+		return cmd, stderr
+	}
+
+	t.Run("reports exit code and stderr tail on unexpected exit", func(t *testing.T) {
+		events := make(chan ProcessExitEvent, 1)
+		client := NewClient(&ClientOptions{
+			OnProcessExit: func(event ProcessExitEvent) { events <- event },
+		})
+
+		cmd, stderr := startHelper(t, 3)
+		client.monitorProcess(cmd, stderr, nil, &atomic.Bool{})
+
+		event := <-events
+		if event.ExitCode != 3 {
+			t.Errorf("Expected exit code 3, got %d", event.ExitCode)
+		}
+		if len(event.Stderr) != stderrTailLines {
+			t.Fatalf("Expected %d stderr lines, got %d", stderrTailLines, len(event.Stderr))
+		}
+		if last := event.Stderr[len(event.Stderr)-1]; last != "line 29" {
+			t.Errorf("Expected last stderr line to be 'line 29', got %q", last)
+		}
+		if client.State() != StateError {
+			t.Errorf("Expected state to be %q, got %q", StateError, client.State())
+		}
+	})
+
+	t.Run("does not report an expected exit", func(t *testing.T) {
+		var called bool
+		client := NewClient(&ClientOptions{
+			OnProcessExit: func(event ProcessExitEvent) { called = true },
+		})
+
+		exitExpected := &atomic.Bool{}
+		exitExpected.Store(true)
+		cmd, stderr := startHelper(t, 0)
+		client.monitorProcess(cmd, stderr, nil, exitExpected)
+
+		if called {
+			t.Error("Expected OnProcessExit not to be called")
+		}
+	})
+
+	t.Run("reports a panic in the handler", func(t *testing.T) {
+		var reported HandlerPanic
+		client := NewClient(&ClientOptions{
+			OnProcessExit: func(event ProcessExitEvent) { panic("boom") },
+			PanicHandler:  func(p HandlerPanic) { reported = p },
+		})
+
+		cmd, stderr := startHelper(t, 1)
+		client.monitorProcess(cmd, stderr, nil, &atomic.Bool{})
+
+		if reported.Value != "boom" || len(reported.Stack) == 0 {
+			t.Errorf("Expected the panic to be reported, got %+v", reported)
+		}
+	})
+}
+
+// TestHelperProcess is not a real test; it is spawned as a fake CLI process by other tests.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(os.Stderr, "line %d\n", i)
+	}
+	var code int
+	fmt.Sscanf(os.Getenv("HELPER_EXIT_CODE"), "%d", &code)
+	os.Exit(code)
+}
+
+func TestClient_CustomTransport(t *testing.T) {
+	t.Run("should start over an injected connection", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, nil)
+
+		client := NewClient(&ClientOptions{Conn: clientConn})
+		t.Cleanup(func() { client.ForceStop() })
+
+		if !client.isExternalServer {
+			t.Error("Expected isExternalServer to be true when Conn is provided")
+		}
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+		if client.ProtocolVersion() != SdkProtocolVersion {
+			t.Errorf("Expected negotiated version %d, got %d", SdkProtocolVersion, client.ProtocolVersion())
+		}
+	})
+
+	t.Run("should use custom dial function", func(t *testing.T) {
+		var dialedAddr string
+		client := NewClient(&ClientOptions{
+			CLIUrl: "example.internal:4321",
+			Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dialedAddr = addr
+				clientConn, serverConn := net.Pipe()
+				go serveFakeCLI(serverConn, nil)
+				return clientConn, nil
+			},
+		})
+		t.Cleanup(func() { client.ForceStop() })
+
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+		if dialedAddr != "example.internal:4321" {
+			t.Errorf("Expected dial to example.internal:4321, got %q", dialedAddr)
+		}
+	})
+
+	t.Run("should trace raw messages in both directions", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, nil)
+
+		var mu sync.Mutex
+		var traced []string
+		client := NewClient(&ClientOptions{
+			Conn: clientConn,
+			Trace: func(direction TraceDirection, payload []byte) {
+				mu.Lock()
+				defer mu.Unlock()
+				traced = append(traced, string(direction)+" "+string(payload))
+			},
+		})
+		t.Cleanup(func() { client.ForceStop() })
+
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(traced) != 2 {
+			t.Fatalf("Expected the ping request and response to be traced, got %q", traced)
+		}
+		if !strings.HasPrefix(traced[0], `send {"jsonrpc":"2.0"`) || !strings.Contains(traced[0], `"method":"ping"`) {
+			t.Errorf("Unexpected sent message %q", traced[0])
+		}
+		if !strings.HasPrefix(traced[1], "receive ") || !strings.Contains(traced[1], `"pong"`) {
+			t.Errorf("Unexpected received message %q", traced[1])
+		}
+	})
+
+	t.Run("should send heartbeat pings", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, nil)
+
+		pings := make(chan struct{}, 10)
+		client := NewClient(&ClientOptions{
+			Conn:              clientConn,
+			HeartbeatInterval: 5 * time.Millisecond,
+			Trace: func(direction TraceDirection, payload []byte) {
+				if direction == TraceSend && strings.Contains(string(payload), `"method":"ping"`) {
+					select {
+					case pings <- struct{}{}:
+					default:
+					}
+				}
+			},
+		})
+		t.Cleanup(func() { client.ForceStop() })
+
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+		// The first ping verifies the protocol version; later ones are heartbeats
+		for range 3 {
+			select {
+			case <-pings:
+			case <-time.After(time.Second):
+				t.Fatal("Expected periodic heartbeat pings")
+			}
+		}
+		if client.State() != StateConnected {
+			t.Errorf("Expected the client to stay connected, got %q", client.State())
+		}
+	})
+
+	t.Run("should throw error when Conn is used with CLIUrl", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for mutually exclusive options")
+			} else {
+				matched, _ := regexp.MatchString("Conn is mutually exclusive", r.(string))
+				if !matched {
+					t.Errorf("Expected panic message to contain 'Conn is mutually exclusive', got: %v", r)
+				}
+			}
+		}()
+
+		clientConn, _ := net.Pipe()
+		NewClient(&ClientOptions{
+			CLIUrl: "localhost:8080",
+			Conn:   clientConn,
+		})
+	})
+}
+
+func TestClient_Login(t *testing.T) {
+	t.Run("reports the device code and waits for authentication", func(t *testing.T) {
+		var statusCalls atomic.Int32
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			switch method {
+			case "auth.login":
+				return map[string]any{"userCode": "ABCD-1234", "verificationUri": "https://github.com/login/device", "interval": 5}
+			case "auth.getStatus":
+				if statusCalls.Add(1) < 3 {
+					return map[string]any{"isAuthenticated": false}
+				}
+				return map[string]any{"isAuthenticated": true, "login": "octocat"}
+			}
+			return nil
+		})
+
+		client := NewClient(&ClientOptions{Conn: clientConn})
+		t.Cleanup(func() { client.ForceStop() })
+
+		var code DeviceCode
+		status, err := client.Login(t.Context(), &LoginOptions{
+			OnDeviceCode: func(c DeviceCode) { code = c },
+			PollInterval: time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		if code.UserCode != "ABCD-1234" || code.VerificationURI != "https://github.com/login/device" {
+			t.Errorf("Unexpected device code: %+v", code)
+		}
+		if !status.IsAuthenticated || status.Login == nil || *status.Login != "octocat" {
+			t.Errorf("Expected authenticated status for octocat, got %+v", status)
+		}
+		if statusCalls.Load() != 3 {
+			t.Errorf("Expected 3 status polls, got %d", statusCalls.Load())
+		}
+	})
+}
+
+func TestClient_Logout(t *testing.T) {
+	t.Run("signs out and returns the new auth status", func(t *testing.T) {
+		var loggedOut atomic.Bool
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			switch method {
+			case "auth.logout":
+				loggedOut.Store(true)
+				return map[string]any{}
+			case "auth.getStatus":
+				return map[string]any{"isAuthenticated": !loggedOut.Load()}
+			}
+			return nil
+		})
+
+		client := NewClient(&ClientOptions{Conn: clientConn})
+		t.Cleanup(func() { client.ForceStop() })
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+
+		status, err := client.Logout(t.Context())
+		if err != nil {
+			t.Fatalf("Logout failed: %v", err)
+		}
+		if status.IsAuthenticated {
+			t.Error("Expected to be signed out")
+		}
+	})
+
+	t.Run("reports a server without auth.logout as ErrUnsupported", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32601, Message: "Method not found"}
+		})
+
+		client := NewClient(&ClientOptions{Conn: clientConn})
+		t.Cleanup(func() { client.ForceStop() })
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+
+		if _, err := client.Logout(t.Context()); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected ErrUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestClient_SessionLabels(t *testing.T) {
+	t.Run("sends labels on create and filters sessions by label", func(t *testing.T) {
+		var created createSessionRequest
+		var listed listSessionsRequest
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			switch method {
+			case "session.create":
+				json.Unmarshal(params, &created)
+				return map[string]any{"sessionId": "s1"}
+			case "session.list":
+				json.Unmarshal(params, &listed)
+				// Ignore the filter, as an older server would
+				return map[string]any{"sessions": []map[string]any{
+					{"sessionId": "s1", "labels": map[string]string{"user": "alice", "project": "api"}},
+					{"sessionId": "s2", "labels": map[string]string{"user": "bob"}},
+					{"sessionId": "s3"},
+				}}
+			}
+			return nil
+		})
+
+		client := NewClient(&ClientOptions{Conn: clientConn})
+		t.Cleanup(func() { client.ForceStop() })
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+
+		labels := map[string]string{"user": "alice", "project": "api"}
+		if _, err := client.CreateSession(t.Context(), &SessionConfig{Labels: labels}); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if created.Labels["user"] != "alice" || created.Labels["project"] != "api" {
+			t.Errorf("Expected labels in create request, got %v", created.Labels)
+		}
+
+		sessions, err := client.ListSessionsWithOptions(t.Context(), &ListSessionsOptions{Labels: map[string]string{"user": "alice"}})
+		if err != nil {
+			t.Fatalf("ListSessionsWithOptions failed: %v", err)
+		}
+		if listed.Labels["user"] != "alice" {
+			t.Errorf("Expected label filter in list request, got %v", listed.Labels)
+		}
+		if len(sessions) != 1 || sessions[0].SessionID != "s1" {
+			t.Errorf("Expected only s1, got %+v", sessions)
+		}
+
+		all, err := client.ListSessions(t.Context())
+		if err != nil {
+			t.Fatalf("ListSessions failed: %v", err)
+		}
+		if len(all) != 3 {
+			t.Errorf("Expected all 3 sessions, got %d", len(all))
+		}
+	})
+}
+
+func TestClient_ResumeReplayHistory(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+		switch method {
+		case "session.resume":
+			return map[string]any{"sessionId": "s1"}
+		case "session.getMessages":
+			return map[string]any{"events": []map[string]any{
+				{"id": "e1", "type": "user.message", "data": map[string]any{"content": "hi"}},
+				{"id": "e2", "type": "assistant.message", "data": map[string]any{"content": "hello"}},
+			}}
+		}
+		return nil
+	})
+
+	client := NewClient(&ClientOptions{Conn: clientConn})
+	t.Cleanup(func() { client.ForceStop() })
+	if err := client.Start(t.Context()); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+
+	var replayed []string
+	session, err := client.ResumeSessionWithOptions(t.Context(), "s1", &ResumeSessionConfig{
+		ReplayHistory: true,
+		OnEvent: func(event SessionEvent) {
+			replayed = append(replayed, event.ID)
+		},
+	})
+	if err != nil {
+		t.Fatalf("ResumeSessionWithOptions failed: %v", err)
+	}
+	if !slices.Equal(replayed, []string{"e1", "e2"}) {
+		t.Errorf("Expected history to be replayed, got %v", replayed)
+	}
+	if session.IsReplaying() {
+		t.Error("Expected replay to be over")
+	}
+}
+
+func TestClient_TokenProvider(t *testing.T) {
+	t.Run("pushes a fresh token after an authentication error", func(t *testing.T) {
+		var tokenCalls atomic.Int32
+		tokens := make(chan string, 1)
+		client := NewClient(&ClientOptions{
+			TokenProvider: TokenProviderFunc(func(ctx context.Context) (string, error) {
+				return fmt.Sprintf("ghs_token_%d", tokenCalls.Add(1)), nil
+			}),
+		})
+
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			if method == "auth.setToken" {
+				var req authSetTokenRequest
+				json.Unmarshal(params, &req)
+				tokens <- req.Token
+			}
+			return map[string]any{}
+		})
+		client.client = jsonrpc2.NewClient(clientConn, clientConn)
+		client.client.Start()
+		t.Cleanup(func() { client.client.Stop() })
+
+		errorType := "authentication"
+		client.handleSessionEvent(sessionEventRequest{
+			SessionID: "session-1",
+			Event:     SessionEvent{Type: SessionError, Data: Data{ErrorType: &errorType}},
+		})
+
+		select {
+		case token := <-tokens:
+			if token != "ghs_token_1" {
+				t.Errorf("Expected token 'ghs_token_1', got %q", token)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for auth.setToken")
+		}
+	})
+
+	t.Run("shares a refresh already in flight", func(t *testing.T) {
+		var tokenCalls atomic.Int32
+		entered, release := make(chan struct{}), make(chan struct{})
+		client := NewClient(&ClientOptions{
+			TokenProvider: TokenProviderFunc(func(ctx context.Context) (string, error) {
+				tokenCalls.Add(1)
+				close(entered)
+				<-release
+				return "ghs_token", nil
+			}),
+		})
+
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			return map[string]any{}
+		})
+		client.client = jsonrpc2.NewClient(clientConn, clientConn)
+		client.client.Start()
+		t.Cleanup(func() { client.client.Stop() })
+
+		first := make(chan error, 1)
+		go func() { first <- client.RefreshToken(t.Context()) }()
+		<-entered
+
+		// A second caller waits for the first refresh rather than starting its own
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		if err := client.RefreshToken(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the waiting caller to stop with its context, got %v", err)
+		}
+		close(release)
+
+		if err := <-first; err != nil {
+			t.Errorf("RefreshToken failed: %v", err)
+		}
+		if tokenCalls.Load() != 1 {
+			t.Errorf("Expected one token fetch, got %d", tokenCalls.Load())
+		}
+	})
+
+	t.Run("reports a server without auth.setToken as ErrUnsupported", func(t *testing.T) {
+		client := NewClient(&ClientOptions{
+			TokenProvider: TokenProviderFunc(func(ctx context.Context) (string, error) { return "ghs_token", nil }),
+		})
+
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32601, Message: "Method not found"}
+		})
+		client.client = jsonrpc2.NewClient(clientConn, clientConn)
+		client.client.Start()
+		t.Cleanup(func() { client.client.Stop() })
+
+		if err := client.RefreshToken(t.Context()); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected ErrUnsupported, got %v", err)
+		}
+	})
+
+	t.Run("should throw error when TokenProvider is used with GithubToken", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for mutually exclusive options")
+			}
+		}()
+
+		NewClient(&ClientOptions{
+			GithubToken:   "gho_test_token",
+			TokenProvider: TokenProviderFunc(func(ctx context.Context) (string, error) { return "", nil }),
+		})
+	})
+}
+
+// serveFakeCLI serves a minimal CLI server on conn for unit tests.
+// It answers ping with the SDK protocol version; other methods are passed to handle,
+// whose return value is sent as the result (or as the error if it is a *fakeCLIError).
+func serveFakeCLI(conn io.ReadWriteCloser, handle func(method string, params json.RawMessage) any) {
+	defer conn.Close()
+	var writeMu sync.Mutex
+	reader := bufio.NewReader(conn)
+	for {
+		var contentLength int
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line == "\r\n" {
+				break
+			}
+			fmt.Sscanf(line, "Content-Length: %d", &contentLength)
+		}
+		body := make([]byte, contentLength)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		var request struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil || len(request.ID) == 0 {
+			continue
+		}
+
+		// Handle requests concurrently so a blocking handler doesn't stall the connection
+		go func() {
+			response := map[string]any{"jsonrpc": "2.0", "id": request.ID}
+			var result any
+			if request.Method == "ping" {
+				result = map[string]any{"message": "pong", "timestamp": 0, "protocolVersion": SdkProtocolVersion}
+			} else if handle != nil {
+				result = handle(request.Method, request.Params)
+			}
+			if rpcErr, ok := result.(*fakeCLIError); ok {
+				response["error"] = rpcErr
+			} else {
+				response["result"] = result
+			}
+
+			data, _ := json.Marshal(response)
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			fmt.Fprintf(conn, "Content-Length: %d\r\n\r\n%s", len(data), data)
+		}()
+	}
+}
+
+// fakeCLIError is returned from a serveFakeCLI handler to respond with a JSON-RPC error.
+type fakeCLIError struct {
+	Code    int            `json:"code"`
+	Message string         `json:"message"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
 func findCLIPathForTest() string {
 	abs, _ := filepath.Abs("../nodejs/node_modules/@github/copilot/index.js")
 	if fileExistsForTest(abs) {
@@ -389,3 +1056,41 @@ func fileExistsForTest(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+func TestReportToolProgress(t *testing.T) {
+	t.Run("delivers progress to the session's event handlers", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		client := &Client{sessions: map[string]*Session{"session-1": session}}
+		var events []SessionEvent
+		session.On(func(event SessionEvent) { events = append(events, event) })
+
+		result := client.executeToolCall(t.Context(), "session-1", "call-1", "build", nil,
+			func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+				err := ReportToolProgress(ctx, ToolProgress{Message: "Compiling", Percent: Float64(50), Output: "ok pkg/a\n"})
+				return ToolResult{ResultType: "success"}, err
+			})
+		if result.ResultType != "success" {
+			t.Fatalf("Expected success, got %+v", result)
+		}
+
+		if len(events) != 2 || events[0].Type != ToolExecutionProgress || events[1].Type != ToolExecutionPartialResult {
+			t.Fatalf("Expected progress and partial result events, got %+v", events)
+		}
+		progress, partial := events[0].Data, events[1].Data
+		if *progress.ToolCallID != "call-1" || *progress.ProgressMessage != "Compiling (50%)" {
+			t.Errorf("Unexpected progress event data: %+v", progress)
+		}
+		if *partial.ToolCallID != "call-1" || *partial.PartialOutput != "ok pkg/a\n" {
+			t.Errorf("Unexpected partial result event data: %+v", partial)
+		}
+		if events[0].Ephemeral == nil || !*events[0].Ephemeral {
+			t.Error("Expected progress events to be ephemeral")
+		}
+	})
+
+	t.Run("returns an error outside a tool call", func(t *testing.T) {
+		if err := ReportToolProgress(t.Context(), ToolProgress{Message: "x"}); err == nil {
+			t.Error("Expected error without a tool call context")
+		}
+	})
+}
@@ -0,0 +1,52 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// defaultCLISources is the lookup order used when ClientOptions.CLISources is empty
+var defaultCLISources = []CLISource{CLISourceOption, CLISourceEnv, CLISourcePath}
+
+// ResolveCLI finds the CLI executable [Client.Start] would run, trying each of
+// ClientOptions.CLISources in order, and reports which source it came from. Returns
+// an error matching [ErrCLINotFound] when no source yields an executable.
+//
+// Example:
+//
+//	cli, err := client.ResolveCLI()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	log.Printf("using Copilot CLI %s (from %s)", cli.Path, cli.Source)
+func (c *Client) ResolveCLI() (CLIResolution, error) {
+	sources := c.options.CLISources
+	if len(sources) == 0 {
+		sources = defaultCLISources
+	}
+
+	for _, source := range sources {
+		var path string
+		switch source {
+		case CLISourceOption:
+			path = c.options.CLIPath
+		case CLISourceEnv:
+			path = os.Getenv("COPILOT_CLI_PATH")
+		case CLISourcePath:
+			if found, err := exec.LookPath("copilot"); err == nil {
+				path = found
+			}
+		default:
+			return CLIResolution{}, fmt.Errorf("unknown CLI source %q", source)
+		}
+		if path != "" {
+			return CLIResolution{Path: path, Source: source}, nil
+		}
+	}
+	return CLIResolution{}, fmt.Errorf("%w: tried %v", ErrCLINotFound, sources)
+}
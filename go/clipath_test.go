@@ -0,0 +1,75 @@
+package copilot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClient_ResolveCLI(t *testing.T) {
+	// pathWithCLI puts an executable named copilot on PATH and returns its path
+	pathWithCLI := func(t *testing.T) string {
+		dir := t.TempDir()
+		cli := filepath.Join(dir, "copilot")
+		if err := os.WriteFile(cli, []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PATH", dir)
+		return cli
+	}
+
+	t.Run("prefers CLIPath over the environment and PATH", func(t *testing.T) {
+		pathWithCLI(t)
+		t.Setenv("COPILOT_CLI_PATH", "/env/copilot")
+		client := NewClient(&ClientOptions{CLIPath: "/option/copilot"})
+
+		cli, err := client.ResolveCLI()
+		if err != nil || cli.Path != "/option/copilot" || cli.Source != CLISourceOption {
+			t.Errorf("Expected the CLIPath option, got %+v, %v", cli, err)
+		}
+	})
+
+	t.Run("falls back to COPILOT_CLI_PATH, then PATH", func(t *testing.T) {
+		onPath := pathWithCLI(t)
+		t.Setenv("COPILOT_CLI_PATH", "/env/copilot")
+		client := NewClient(nil)
+
+		cli, err := client.ResolveCLI()
+		if err != nil || cli.Path != "/env/copilot" || cli.Source != CLISourceEnv {
+			t.Errorf("Expected COPILOT_CLI_PATH, got %+v, %v", cli, err)
+		}
+
+		t.Setenv("COPILOT_CLI_PATH", "")
+		cli, err = client.ResolveCLI()
+		if err != nil || cli.Path != onPath || cli.Source != CLISourcePath {
+			t.Errorf("Expected the CLI on PATH, got %+v, %v", cli, err)
+		}
+	})
+
+	t.Run("follows a custom source order", func(t *testing.T) {
+		t.Setenv("COPILOT_CLI_PATH", "/env/copilot")
+		client := NewClient(&ClientOptions{
+			CLIPath:    "/option/copilot",
+			CLISources: []CLISource{CLISourceEnv, CLISourceOption},
+		})
+
+		cli, err := client.ResolveCLI()
+		if err != nil || cli.Source != CLISourceEnv {
+			t.Errorf("Expected COPILOT_CLI_PATH first, got %+v, %v", cli, err)
+		}
+	})
+
+	t.Run("returns ErrCLINotFound when no source has the CLI", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		t.Setenv("COPILOT_CLI_PATH", "")
+		client := NewClient(nil)
+
+		if _, err := client.ResolveCLI(); !errors.Is(err, ErrCLINotFound) {
+			t.Errorf("Expected ErrCLINotFound, got %v", err)
+		}
+		if err := client.Start(t.Context()); !errors.Is(err, ErrCLINotFound) {
+			t.Errorf("Expected Start to fail with ErrCLINotFound, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// ImageBlockFromFile reads an image from disk and returns it as an inline image content block.
+// The media type is detected from the file contents.
+//
+// Example:
+//
+//	img, err := copilot.ImageBlockFromFile("./screenshot.png")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	session.Send(ctx, copilot.MessageOptions{
+//	    Prompt:  "What's wrong with this layout?",
+//	    Content: []copilot.ContentBlock{img},
+//	})
+func ImageBlockFromFile(path string) (ContentBlock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ContentBlock{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	return ImageBlockFromBytes(data, "")
+}
+
+// ImageBlockFromBytes returns an inline image content block for data. If mimeType is empty,
+// it is detected from the data. An error is returned if the data is not an image.
+func ImageBlockFromBytes(data []byte, mimeType string) (ContentBlock, error) {
+	if len(data) == 0 {
+		return ContentBlock{}, fmt.Errorf("image data is empty")
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return ContentBlock{}, fmt.Errorf("unsupported image media type %q", mimeType)
+	}
+	return ImageBlock(data, mimeType), nil
+}
+
+// AttachmentFromImageFile returns a file attachment for the image at path, checking
+// that the file is an image. The path is made absolute, since the CLI may run in a
+// different working directory.
+//
+// Example:
+//
+//	att, err := copilot.AttachmentFromImageFile("./screenshot.png")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	session.Send(ctx, copilot.MessageOptions{
+//	    Prompt:      "What's wrong with this layout?",
+//	    Attachments: []copilot.Attachment{att},
+//	})
+func AttachmentFromImageFile(path string) (Attachment, error) {
+	if _, err := ImageBlockFromFile(path); err != nil {
+		return Attachment{}, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to resolve image path: %w", err)
+	}
+	return Attachment{Type: File, Path: &abs, DisplayName: filepath.Base(abs)}, nil
+}
+
+// AttachmentFromImageBytes writes the image in data to a temporary file and returns
+// a file attachment for it. If mimeType is empty, it is detected from the data. The
+// caller should remove the file at the attachment's Path once the message is sent.
+func AttachmentFromImageBytes(data []byte, mimeType string) (Attachment, error) {
+	block, err := ImageBlockFromBytes(data, mimeType)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	ext := ""
+	if exts, _ := mime.ExtensionsByType(block.MimeType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	f, err := os.CreateTemp("", "copilot-image-*"+ext)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to create image file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return Attachment{}, fmt.Errorf("failed to write image file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return Attachment{}, fmt.Errorf("failed to write image file: %w", err)
+	}
+
+	path := f.Name()
+	return Attachment{Type: File, Path: &path, DisplayName: filepath.Base(path)}, nil
+}
+
+// ContentBlockFromReader reads r to completion and returns a content block for it, so content
+// generated on the fly does not need a temporary file. Images become image blocks; other
+// content becomes a resource block identified by name, carried as text when it is valid UTF-8.
+//
+// Example:
+//
+//	var diff bytes.Buffer
+//	cmd := exec.Command("git", "diff")
+//	cmd.Stdout = &diff
+//	cmd.Run()
+//	block, err := copilot.ContentBlockFromReader("changes.diff", &diff)
+func ContentBlockFromReader(name string, r io.Reader) (ContentBlock, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ContentBlock{}, fmt.Errorf("failed to read content: %w", err)
+	}
+	if len(data) == 0 {
+		return ContentBlock{}, fmt.Errorf("content is empty")
+	}
+
+	mimeType := http.DetectContentType(data)
+	if strings.HasPrefix(mimeType, "image/") {
+		return ImageBlock(data, mimeType), nil
+	}
+	if utf8.Valid(data) {
+		return ResourceBlock(name, string(data)), nil
+	}
+	return ContentBlock{
+		Type:     ContentBlockResource,
+		Name:     name,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		MimeType: mimeType,
+	}, nil
+}
+
+// ValidateContent checks the image blocks in content against the model's vision limits:
+// the number of images, each image's size, and its media type.
+//
+// Example:
+//
+//	models, _ := client.ListModels(ctx)
+//	for _, m := range models {
+//	    if m.ID == "gpt-4.1" && m.Capabilities.Limits.Vision != nil {
+//	        if err := m.Capabilities.Limits.Vision.ValidateContent(content); err != nil {
+//	            log.Fatal(err)
+//	        }
+//	    }
+//	}
+func (l *ModelVisionLimits) ValidateContent(content []ContentBlock) error {
+	images := 0
+	for i, block := range content {
+		if block.Type != ContentBlockImage {
+			continue
+		}
+		images++
+
+		if len(l.SupportedMediaTypes) > 0 && !slices.Contains(l.SupportedMediaTypes, block.MimeType) {
+			return fmt.Errorf("content block %d: media type %q is not supported by the model", i, block.MimeType)
+		}
+		if l.MaxPromptImageSize > 0 {
+			data, err := base64.StdEncoding.DecodeString(block.Data)
+			if err != nil {
+				return fmt.Errorf("content block %d: invalid image data: %w", i, err)
+			}
+			if size := len(data); size > l.MaxPromptImageSize {
+				return fmt.Errorf("content block %d: image is %d bytes, exceeding the model limit of %d", i, size, l.MaxPromptImageSize)
+			}
+		}
+	}
+	if l.MaxPromptImages > 0 && images > l.MaxPromptImages {
+		return fmt.Errorf("message has %d images, exceeding the model limit of %d", images, l.MaxPromptImages)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package copilot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader is the smallest prefix http.DetectContentType recognizes as image/png
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+func TestImageBlockFromBytes(t *testing.T) {
+	t.Run("should detect the media type", func(t *testing.T) {
+		block, err := ImageBlockFromBytes(pngHeader, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if block.Type != ContentBlockImage || block.MimeType != "image/png" {
+			t.Errorf("Expected image/png block, got %+v", block)
+		}
+	})
+
+	t.Run("should reject non-image data", func(t *testing.T) {
+		if _, err := ImageBlockFromBytes([]byte("hello world"), ""); err == nil {
+			t.Error("Expected error for text data")
+		}
+	})
+
+	t.Run("should reject empty data", func(t *testing.T) {
+		if _, err := ImageBlockFromBytes(nil, "image/png"); err == nil {
+			t.Error("Expected error for empty data")
+		}
+	})
+}
+
+func TestImageBlockFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.png")
+	if err := os.WriteFile(path, pngHeader, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ImageBlockFromFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if block.MimeType != "image/png" {
+		t.Errorf("Expected image/png, got %q", block.MimeType)
+	}
+
+	if _, err := ImageBlockFromFile(filepath.Join(t.TempDir(), "missing.png")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestAttachmentFromImage(t *testing.T) {
+	t.Run("should attach an image file by absolute path", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "image.png"), pngHeader, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		t.Chdir(dir)
+
+		att, err := AttachmentFromImageFile("image.png")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if att.Type != File || att.Path == nil || !filepath.IsAbs(*att.Path) || att.DisplayName != "image.png" {
+			t.Errorf("Unexpected attachment: %+v", att)
+		}
+
+		os.WriteFile("notes.txt", []byte("hello world"), 0o600)
+		if _, err := AttachmentFromImageFile("notes.txt"); err == nil {
+			t.Error("Expected error for a non-image file")
+		}
+	})
+
+	t.Run("should write image bytes to a temporary file", func(t *testing.T) {
+		att, err := AttachmentFromImageBytes(pngHeader, "")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer os.Remove(*att.Path)
+
+		if filepath.Ext(*att.Path) != ".png" {
+			t.Errorf("Expected a .png file, got %q", *att.Path)
+		}
+		if data, _ := os.ReadFile(*att.Path); !bytes.Equal(data, pngHeader) {
+			t.Errorf("Expected the image data to be written, got %q", data)
+		}
+
+		if _, err := AttachmentFromImageBytes([]byte("hello world"), ""); err == nil {
+			t.Error("Expected error for non-image data")
+		}
+	})
+}
+
+func TestContentBlockFromReader(t *testing.T) {
+	t.Run("should read text as a resource", func(t *testing.T) {
+		block, err := ContentBlockFromReader("changes.diff", strings.NewReader("-old\n+new\n"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if block.Type != ContentBlockResource || block.Name != "changes.diff" || block.Text != "-old\n+new\n" {
+			t.Errorf("Unexpected block: %+v", block)
+		}
+	})
+
+	t.Run("should read images as image blocks", func(t *testing.T) {
+		block, err := ContentBlockFromReader("snapshot.png", bytes.NewReader(pngHeader))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if block.Type != ContentBlockImage || block.MimeType != "image/png" {
+			t.Errorf("Unexpected block: %+v", block)
+		}
+	})
+
+	t.Run("should base64 encode binary content", func(t *testing.T) {
+		block, err := ContentBlockFromReader("blob.bin", bytes.NewReader([]byte{0xff, 0xfe, 0x00, 0x01}))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if block.Type != ContentBlockResource || block.Data != "//4AAQ==" || block.Text != "" {
+			t.Errorf("Unexpected block: %+v", block)
+		}
+		if err := block.validate(); err != nil {
+			t.Errorf("Expected valid block, got %v", err)
+		}
+	})
+
+	t.Run("should reject empty content", func(t *testing.T) {
+		if _, err := ContentBlockFromReader("empty.txt", strings.NewReader("")); err == nil {
+			t.Error("Expected error for empty content")
+		}
+	})
+}
+
+func TestModelVisionLimits_ValidateContent(t *testing.T) {
+	image := ImageBlock(make([]byte, 100), "image/png")
+
+	tests := []struct {
+		name    string
+		limits  ModelVisionLimits
+		content []ContentBlock
+		wantErr string
+	}{
+		{"within limits", ModelVisionLimits{SupportedMediaTypes: []string{"image/png"}, MaxPromptImages: 2, MaxPromptImageSize: 100}, []ContentBlock{TextBlock("hi"), image, image}, ""},
+		{"unsupported media type", ModelVisionLimits{SupportedMediaTypes: []string{"image/jpeg"}}, []ContentBlock{image}, "not supported"},
+		{"image too large", ModelVisionLimits{MaxPromptImageSize: 99}, []ContentBlock{image}, "exceeding the model limit of 99"},
+		{"too many images", ModelVisionLimits{MaxPromptImages: 1}, []ContentBlock{image, image}, "2 images"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.ValidateContent(tt.content)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilottest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	copilot "github.com/github/copilot-sdk/go"
+)
+
+// FinalAssistantMessage waits for the session's current turn to end and returns its
+// last assistant message. A turn that already ended is found in the session history.
+// Returns an error if the turn ends with a session.error event or ctx is done.
+func FinalAssistantMessage(ctx context.Context, session *copilot.Session) (*copilot.SessionEvent, error) {
+	result := make(chan *copilot.SessionEvent, 1)
+	errCh := make(chan error, 1)
+
+	// Subscribe to future events
+	var mu sync.Mutex
+	var finalAssistantMessage *copilot.SessionEvent
+	unsubscribe := session.On(func(event copilot.SessionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch event.Type {
+		case copilot.AssistantMessage:
+			finalAssistantMessage = &event
+		case copilot.SessionIdle:
+			if finalAssistantMessage != nil {
+				trySend(result, finalAssistantMessage)
+			}
+		case copilot.SessionError:
+			trySend(errCh, sessionError(event))
+		}
+	})
+	defer unsubscribe()
+
+	// Also check existing messages in case the response already arrived
+	go func() {
+		existing, err := existingFinalResponse(ctx, session)
+		if err != nil {
+			trySend(errCh, err)
+		} else if existing != nil {
+			trySend(result, existing)
+		}
+	}()
+
+	select {
+	case msg := <-result:
+		return msg, nil
+	case err := <-errCh:
+		return nil, err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for assistant message: %w", ctx.Err())
+	}
+}
+
+// NextEventOfType waits for the session's next event of eventType. Returns an error if
+// a session.error event arrives first or ctx is done.
+func NextEventOfType(ctx context.Context, session *copilot.Session, eventType copilot.SessionEventType) (*copilot.SessionEvent, error) {
+	watcher := WatchEvents(session)
+	defer watcher.Stop()
+	return watcher.Next(ctx, eventType)
+}
+
+// EventWatcher collects a session's events from the moment it is created, so a test can
+// start watching before sending a message and assert on the events afterwards without
+// missing any.
+//
+// Example:
+//
+//	events := copilottest.WatchEvents(session)
+//	defer events.Stop()
+//	session.Send(ctx, copilot.MessageOptions{Prompt: "Run the tests"})
+//	events.ExpectSequence(t, ctx, copilot.ToolExecutionStart, copilot.ToolExecutionComplete, copilot.AssistantMessage)
+//	events.WaitForIdle(t, ctx)
+type EventWatcher struct {
+	mu          sync.Mutex
+	events      []copilot.SessionEvent
+	next        int           // index of the first event not yet consumed
+	changed     chan struct{} // signalled when an event arrives
+	unsubscribe func()
+}
+
+// WatchEvents starts collecting session's events
+func WatchEvents(session *copilot.Session) *EventWatcher {
+	w := &EventWatcher{changed: make(chan struct{}, 1)}
+	w.unsubscribe = session.On(func(event copilot.SessionEvent) {
+		w.mu.Lock()
+		w.events = append(w.events, event)
+		w.mu.Unlock()
+		trySend(w.changed, struct{}{})
+	})
+	return w
+}
+
+// Stop stops collecting events
+func (w *EventWatcher) Stop() {
+	w.unsubscribe()
+}
+
+// Events returns every event collected so far
+func (w *EventWatcher) Events() []copilot.SessionEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]copilot.SessionEvent(nil), w.events...)
+}
+
+// Next returns the next event of eventType, skipping events of other types. Returns an
+// error if a session.error event comes first or ctx is done.
+func (w *EventWatcher) Next(ctx context.Context, eventType copilot.SessionEventType) (*copilot.SessionEvent, error) {
+	for {
+		w.mu.Lock()
+		for w.next < len(w.events) {
+			event := w.events[w.next]
+			w.next++
+			if event.Type == eventType {
+				w.mu.Unlock()
+				return &event, nil
+			}
+			if event.Type == copilot.SessionError {
+				w.mu.Unlock()
+				return nil, sessionError(event)
+			}
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-w.changed:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for %s: %w", eventType, ctx.Err())
+		}
+	}
+}
+
+// ExpectSequence fails the test unless events of the given types arrive in this order,
+// possibly with other events between them, before ctx is done. It returns the matching
+// events.
+func (w *EventWatcher) ExpectSequence(t testing.TB, ctx context.Context, types ...copilot.SessionEventType) []copilot.SessionEvent {
+	t.Helper()
+	matched := make([]copilot.SessionEvent, 0, len(types))
+	for i, eventType := range types {
+		event, err := w.Next(ctx, eventType)
+		if err != nil {
+			t.Fatalf("expected event %d of %v to be %s: %v", i+1, types, eventType, err)
+		}
+		matched = append(matched, *event)
+	}
+	return matched
+}
+
+// WaitForIdle fails the test unless a session.idle event arrives before a session.error
+// event or ctx being done
+func (w *EventWatcher) WaitForIdle(t testing.TB, ctx context.Context) {
+	t.Helper()
+	if _, err := w.Next(ctx, copilot.SessionIdle); err != nil {
+		t.Fatalf("session did not become idle: %v", err)
+	}
+}
+
+// existingFinalResponse returns the last assistant message of the session's latest turn
+// from its history, if that turn has ended
+func existingFinalResponse(ctx context.Context, session *copilot.Session) (*copilot.SessionEvent, error) {
+	messages, err := session.GetMessages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find last user message
+	finalUserMessageIndex := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Type == copilot.UserMessage {
+			finalUserMessageIndex = i
+			break
+		}
+	}
+
+	var currentTurnMessages []copilot.SessionEvent
+	if finalUserMessageIndex < 0 {
+		currentTurnMessages = messages
+	} else {
+		currentTurnMessages = messages[finalUserMessageIndex:]
+	}
+
+	// Check for errors
+	for _, msg := range currentTurnMessages {
+		if msg.Type == copilot.SessionError {
+			return nil, sessionError(msg)
+		}
+	}
+
+	// Find session.idle and get last assistant.message before it
+	for i, msg := range currentTurnMessages {
+		if msg.Type != copilot.SessionIdle {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if currentTurnMessages[j].Type == copilot.AssistantMessage {
+				return &currentTurnMessages[j], nil
+			}
+		}
+		break
+	}
+	return nil, nil
+}
+
+// sessionError converts a session.error event into an error
+func sessionError(event copilot.SessionEvent) error {
+	msg := "session error"
+	if event.Data.Message != nil {
+		msg = *event.Data.Message
+	}
+	return errors.New(msg)
+}
+
+// trySend sends value on ch unless ch is full
+func trySend[T any](ch chan T, value T) {
+	select {
+	case ch <- value:
+	default:
+	}
+}
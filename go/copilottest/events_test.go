@@ -0,0 +1,64 @@
+package copilottest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	copilot "github.com/github/copilot-sdk/go"
+)
+
+func TestEventHelpers(t *testing.T) {
+	newSession := func(t *testing.T, replies ...Reply) *copilot.Session {
+		server := NewServer()
+		server.Reply(replies...)
+		session, err := server.NewClient(t, nil).CreateSession(t.Context(), nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		return session
+	}
+
+	t.Run("FinalAssistantMessage finds a turn that already ended", func(t *testing.T) {
+		session := newSession(t, Reply{Message: "done"})
+		events := WatchEvents(session)
+		defer events.Stop()
+		session.Send(t.Context(), copilot.MessageOptions{Prompt: "go"})
+		events.WaitForIdle(t, t.Context())
+
+		message, err := FinalAssistantMessage(t.Context(), session)
+		if err != nil || *message.Data.Content != "done" {
+			t.Errorf("Expected the final message, got %+v, %v", message, err)
+		}
+	})
+
+	t.Run("ExpectSequence matches events in order", func(t *testing.T) {
+		session := newSession(t, Reply{ToolCalls: []ToolCall{{Name: "missing"}}, Message: "done"})
+		events := WatchEvents(session)
+		defer events.Stop()
+		session.Send(t.Context(), copilot.MessageOptions{Prompt: "go"})
+
+		matched := events.ExpectSequence(t, t.Context(), copilot.UserMessage, copilot.ToolExecutionComplete, copilot.AssistantMessage)
+		if *matched[2].Data.Content != "done" {
+			t.Errorf("Unexpected message %+v", matched[2])
+		}
+		events.WaitForIdle(t, t.Context())
+	})
+
+	t.Run("Next returns session errors and honors ctx", func(t *testing.T) {
+		session := newSession(t)
+		events := WatchEvents(session)
+		defer events.Stop()
+		session.Send(t.Context(), copilot.MessageOptions{Prompt: "unscripted"})
+
+		if _, err := events.Next(t.Context(), copilot.SessionIdle); err == nil {
+			t.Error("Expected the session error")
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := NextEventOfType(ctx, session, copilot.AssistantMessage); err == nil {
+			t.Error("Expected a timeout")
+		}
+	})
+}
@@ -0,0 +1,217 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilottest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// HTTPCapture is a reverse proxy that records the model API traffic of a CLI pointed
+// at it, so tests can assert on the exact requests a configuration produces: system
+// messages, tool lists, and request bodies. Start the CLI with [HTTPCapture.Env] in
+// ClientOptions.Env.
+//
+// Example:
+//
+//	capture, err := copilottest.NewHTTPCapture("https://api.githubcopilot.com")
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	defer capture.Close()
+//	client := copilot.NewClient(&copilot.ClientOptions{Env: append(os.Environ(), capture.Env()...)})
+//	// ... run a turn ...
+//	exchanges := capture.Exchanges()
+//	if !strings.Contains(exchanges[0].SystemMessage(), "You are a release assistant") {
+//	    t.Error("custom system message not sent")
+//	}
+type HTTPCapture struct {
+	server    *httptest.Server
+	mu        sync.Mutex
+	exchanges []*HTTPExchange
+}
+
+// exchangeKey is the request context key of the exchange being recorded
+type exchangeKey struct{}
+
+// NewHTTPCapture starts a proxy that forwards requests to target, the model API base URL
+func NewHTTPCapture(target string) (*HTTPCapture, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse target URL: %w", err)
+	}
+
+	c := &HTTPCapture{}
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(targetURL)
+			// Let the transport negotiate compression so captured bodies are plain
+			r.Out.Header.Del("Accept-Encoding")
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			exchange, _ := resp.Request.Context().Value(exchangeKey{}).(*HTTPExchange)
+			if exchange == nil {
+				return nil
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			if err != nil {
+				return fmt.Errorf("failed to read response body: %w", err)
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			exchange.StatusCode = resp.StatusCode
+			exchange.ResponseBody = body
+			var response ChatCompletionResponse
+			if json.Unmarshal(body, &response) == nil {
+				exchange.Response = &response
+			}
+			return nil
+		},
+	}
+
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		exchange := &HTTPExchange{Method: r.Method, Path: r.URL.Path, RequestBody: body}
+		json.Unmarshal(body, &exchange.Request)
+		c.mu.Lock()
+		c.exchanges = append(c.exchanges, exchange)
+		c.mu.Unlock()
+
+		proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), exchangeKey{}, exchange)))
+	}))
+	return c, nil
+}
+
+// URL returns the proxy's base URL
+func (c *HTTPCapture) URL() string {
+	return c.server.URL
+}
+
+// Env returns the environment variables that point the CLI at the proxy
+func (c *HTTPCapture) Env() []string {
+	return []string{"COPILOT_API_URL=" + c.server.URL}
+}
+
+// Exchanges returns the requests proxied so far, with their responses once received
+func (c *HTTPCapture) Exchanges() []HTTPExchange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exchanges := make([]HTTPExchange, len(c.exchanges))
+	for i, exchange := range c.exchanges {
+		exchanges[i] = *exchange
+	}
+	return exchanges
+}
+
+// Close stops the proxy
+func (c *HTTPCapture) Close() {
+	c.server.Close()
+}
+
+// HTTPExchange is a captured model API request and its response
+type HTTPExchange struct {
+	Request  ChatCompletionRequest   `json:"request"`
+	Response *ChatCompletionResponse `json:"response,omitempty"`
+
+	// Method, Path, and RequestBody describe the request as sent
+	Method      string `json:"-"`
+	Path        string `json:"-"`
+	RequestBody []byte `json:"-"`
+	// StatusCode and ResponseBody describe the response; Response is nil when the
+	// body isn't a JSON chat completion, such as a streamed one
+	StatusCode   int    `json:"-"`
+	ResponseBody []byte `json:"-"`
+}
+
+// SystemMessage returns the content of the request's system messages, joined by newlines
+func (e HTTPExchange) SystemMessage() string {
+	var content []string
+	for _, message := range e.Request.Messages {
+		if message.Role == "system" {
+			content = append(content, message.Content)
+		}
+	}
+	return strings.Join(content, "\n")
+}
+
+// ToolNames returns the names of the tools offered to the model
+func (e HTTPExchange) ToolNames() []string {
+	names := make([]string, 0, len(e.Request.Tools))
+	for _, tool := range e.Request.Tools {
+		names = append(names, tool.Function.Name)
+	}
+	return names
+}
+
+// ChatCompletionRequest represents an OpenAI chat completion request.
+type ChatCompletionRequest struct {
+	Model    string                  `json:"model"`
+	Messages []ChatCompletionMessage `json:"messages"`
+	Tools    []ChatCompletionTool    `json:"tools,omitempty"`
+}
+
+// ChatCompletionMessage represents a message in the chat completion request.
+type ChatCompletionMessage struct {
+	Role       string               `json:"role"`
+	Content    string               `json:"content,omitempty"`
+	ToolCallID string               `json:"tool_call_id,omitempty"`
+	ToolCalls  []ChatCompletionCall `json:"tool_calls,omitempty"`
+}
+
+// ChatCompletionCall represents a tool call in an assistant message.
+type ChatCompletionCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall represents the function details in a tool call.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ChatCompletionTool represents a tool in the chat completion request.
+type ChatCompletionTool struct {
+	Type     string                     `json:"type"`
+	Function ChatCompletionToolFunction `json:"function"`
+}
+
+// ChatCompletionToolFunction represents a function tool.
+type ChatCompletionToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// ChatCompletionResponse represents an OpenAI chat completion response.
+type ChatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+}
+
+// ChatCompletionChoice represents a choice in the response.
+type ChatCompletionChoice struct {
+	Index        int                   `json:"index"`
+	Message      ChatCompletionMessage `json:"message"`
+	FinishReason string                `json:"finish_reason"`
+}
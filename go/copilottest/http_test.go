@@ -0,0 +1,59 @@
+package copilottest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHTTPCapture(t *testing.T) {
+	t.Run("records requests and responses passing through", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/chat/completions" {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, `{"id":"c1","model":"gpt-5","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`)
+		}))
+		defer upstream.Close()
+
+		capture, err := NewHTTPCapture(upstream.URL)
+		if err != nil {
+			t.Fatalf("NewHTTPCapture failed: %v", err)
+		}
+		defer capture.Close()
+		if env := capture.Env(); len(env) != 1 || env[0] != "COPILOT_API_URL="+capture.URL() {
+			t.Errorf("Unexpected env %v", env)
+		}
+
+		body := `{"model":"gpt-5","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"hello"}],` +
+			`"tools":[{"type":"function","function":{"name":"get_weather"}}]}`
+		resp, err := http.Post(capture.URL()+"/chat/completions", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		reply, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(reply), `"content":"hi"`) {
+			t.Errorf("Expected the upstream response, got %s", reply)
+		}
+
+		exchanges := capture.Exchanges()
+		if len(exchanges) != 1 {
+			t.Fatalf("Expected 1 exchange, got %d", len(exchanges))
+		}
+		exchange := exchanges[0]
+		if exchange.Method != http.MethodPost || exchange.Path != "/chat/completions" || string(exchange.RequestBody) != body {
+			t.Errorf("Unexpected request %s %s %s", exchange.Method, exchange.Path, exchange.RequestBody)
+		}
+		if exchange.SystemMessage() != "Be brief." || !slices.Equal(exchange.ToolNames(), []string{"get_weather"}) {
+			t.Errorf("Unexpected system message %q or tools %v", exchange.SystemMessage(), exchange.ToolNames())
+		}
+		if exchange.StatusCode != http.StatusOK || exchange.Response == nil || exchange.Response.Choices[0].Message.Content != "hi" {
+			t.Errorf("Unexpected response %d %+v", exchange.StatusCode, exchange.Response)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilottest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	copilot "github.com/github/copilot-sdk/go"
+	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
+)
+
+// recordedMessage is one line of a recording
+type recordedMessage struct {
+	Direction copilot.TraceDirection `json:"direction"`
+	Message   json.RawMessage        `json:"message"`
+}
+
+// Recorder writes every JSON-RPC message a client exchanges with the CLI server to a
+// recording, one JSON object per line, for a [Replayer] to serve back later.
+//
+// Example:
+//
+//	file, _ := os.Create("testdata/weather.jsonl")
+//	defer file.Close()
+//	recorder := copilottest.NewRecorder(file)
+//	client := copilot.NewClient(&copilot.ClientOptions{Trace: recorder.Trace})
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns a Recorder that writes to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Trace records a message; pass it as [copilot.ClientOptions.Trace]
+func (r *Recorder) Trace(direction copilot.TraceDirection, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	message := recordedMessage{Direction: direction, Message: append(json.RawMessage(nil), data...)}
+	if err := r.enc.Encode(message); err != nil {
+		r.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+}
+
+// Err returns the first error writing the recording, if any
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Replayer serves a recording made by a [Recorder] back to a client, so tests of an
+// SDK integration run without the CLI or network. Each message the client sends must
+// match the method of the next recorded one; the recorded replies are then sent with
+// request IDs rewritten to the client's.
+//
+// Example:
+//
+//	file, _ := os.Open("testdata/weather.jsonl")
+//	defer file.Close()
+//	replayer, err := copilottest.NewReplayer(file)
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	client := replayer.NewClient(t, nil)
+type Replayer struct {
+	messages []recordedMessage
+	mu       sync.Mutex
+	err      error
+}
+
+// NewReplayer reads a recording from r
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	var messages []recordedMessage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, jsonrpc2.DefaultMaxMessageSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var message recordedMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			return nil, fmt.Errorf("failed to parse recording line %d: %w", line, err)
+		}
+		messages = append(messages, message)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return &Replayer{messages: messages}, nil
+}
+
+// NewClient returns a started client that is served the recording in memory. options
+// may be nil; its connection settings are replaced. When the test ends the client is
+// stopped and the test fails if the client strayed from the recording.
+func (p *Replayer) NewClient(t testing.TB, options *copilot.ClientOptions) *copilot.Client {
+	t.Helper()
+	client, stream := newPipeClient(options)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.play(stream)
+	}()
+	t.Cleanup(func() {
+		stream.Close()
+		<-done
+		if err := p.Err(); err != nil {
+			t.Errorf("replay failed: %v", err)
+		}
+	})
+	startClient(t, client)
+	return client
+}
+
+// Err returns the first difference between the client's messages and the recording
+func (p *Replayer) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *Replayer) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// messageHeader is the part of a JSON-RPC message the replayer matches on
+type messageHeader struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+// play walks the recording, reading the client's message for each recorded send and
+// writing each recorded receive, until the recording ends or the stream fails
+func (p *Replayer) play(stream jsonrpc2.Stream) {
+	defer stream.Close()
+	ids := make(map[string]json.RawMessage) // recorded request ID -> the client's ID
+
+	for i, recorded := range p.messages {
+		var want messageHeader
+		if err := json.Unmarshal(recorded.Message, &want); err != nil {
+			p.fail(fmt.Errorf("invalid recorded message %d: %w", i+1, err))
+			return
+		}
+
+		switch recorded.Direction {
+		case copilot.TraceSend:
+			data, err := stream.Read()
+			if err != nil {
+				return
+			}
+			var got messageHeader
+			if err := json.Unmarshal(data, &got); err != nil {
+				p.fail(fmt.Errorf("invalid message from client: %w", err))
+				return
+			}
+			if got.Method != want.Method {
+				p.fail(fmt.Errorf("message %d: client sent %q, recording has %q", i+1, describe(got), describe(want)))
+				return
+			}
+			if want.Method != "" && len(want.ID) > 0 {
+				ids[string(want.ID)] = got.ID
+			}
+
+		case copilot.TraceReceive:
+			data := []byte(recorded.Message)
+			if id, ok := ids[string(want.ID)]; ok && want.Method == "" {
+				rewritten, err := withID(recorded.Message, id)
+				if err != nil {
+					p.fail(fmt.Errorf("invalid recorded message %d: %w", i+1, err))
+					return
+				}
+				data = rewritten
+			}
+			if err := stream.Write(data); err != nil {
+				return
+			}
+
+		default:
+			p.fail(fmt.Errorf("recorded message %d has unknown direction %q", i+1, recorded.Direction))
+			return
+		}
+	}
+
+	// Fail any call the client makes past the end of the recording
+	if data, err := stream.Read(); err == nil {
+		var got messageHeader
+		json.Unmarshal(data, &got)
+		p.fail(fmt.Errorf("client sent %q after the end of the recording", describe(got)))
+	}
+}
+
+// describe names a message by its method, or as a response
+func describe(header messageHeader) string {
+	if header.Method == "" {
+		return "response"
+	}
+	return header.Method
+}
+
+// withID returns message with its id replaced
+func withID(message json.RawMessage, id json.RawMessage) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return nil, err
+	}
+	fields["id"] = id
+	return json.Marshal(fields)
+}
@@ -0,0 +1,92 @@
+package copilottest
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	copilot "github.com/github/copilot-sdk/go"
+)
+
+func TestRecordReplay(t *testing.T) {
+	type weatherParams struct {
+		City string `json:"city"`
+	}
+	weatherTool := copilot.DefineTool("get_weather", "Get the weather", func(params weatherParams, inv copilot.ToolInvocation) (string, error) {
+		return "sunny in " + params.City, nil
+	})
+
+	// record runs a turn against a Server and returns the recording
+	record := func(t *testing.T) []byte {
+		server := NewServer()
+		server.Reply(Reply{
+			ToolCalls: []ToolCall{{Name: "get_weather", Arguments: map[string]any{"city": "Oslo"}}},
+			Message:   "It's sunny in Oslo.",
+		})
+		var recording bytes.Buffer
+		recorder := NewRecorder(&recording)
+		client := server.NewClient(t, &copilot.ClientOptions{Trace: recorder.Trace})
+		session, err := client.CreateSession(t.Context(), &copilot.SessionConfig{Tools: []copilot.Tool{weatherTool}})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if _, err := session.RunTurn(t.Context(), copilot.MessageOptions{Prompt: "Weather in Oslo?"}); err != nil {
+			t.Fatalf("RunTurn failed: %v", err)
+		}
+		client.ForceStop()
+		if err := recorder.Err(); err != nil {
+			t.Fatalf("Recording failed: %v", err)
+		}
+		return recording.Bytes()
+	}
+
+	t.Run("replays a recorded run", func(t *testing.T) {
+		replayer, err := NewReplayer(bytes.NewReader(record(t)))
+		if err != nil {
+			t.Fatalf("NewReplayer failed: %v", err)
+		}
+		client := replayer.NewClient(t, nil)
+		session, err := client.CreateSession(t.Context(), &copilot.SessionConfig{Tools: []copilot.Tool{weatherTool}})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+
+		turn, err := session.RunTurn(t.Context(), copilot.MessageOptions{Prompt: "Weather in Oslo?"})
+		if err != nil {
+			t.Fatalf("RunTurn failed: %v", err)
+		}
+		if turn.Message == nil || *turn.Message.Data.Content != "It's sunny in Oslo." {
+			t.Errorf("Unexpected message %+v", turn.Message)
+		}
+		if len(turn.ToolCalls) != 1 || turn.ToolCalls[0].Output != "sunny in Oslo" {
+			t.Errorf("Unexpected tool calls %+v", turn.ToolCalls)
+		}
+	})
+
+	t.Run("reports a client that strays from the recording", func(t *testing.T) {
+		replayer, err := NewReplayer(bytes.NewReader(record(t)))
+		if err != nil {
+			t.Fatalf("NewReplayer failed: %v", err)
+		}
+		client, stream := newPipeClient(nil)
+		go replayer.play(stream)
+		defer client.ForceStop()
+		if err := client.Start(context.Background()); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+
+		if _, err := client.ListSessions(t.Context()); err == nil {
+			t.Error("Expected ListSessions to fail")
+		}
+		if err := replayer.Err(); err == nil || !strings.Contains(err.Error(), `client sent "session.list", recording has "session.create"`) {
+			t.Errorf("Unexpected replay error %v", err)
+		}
+	})
+
+	t.Run("rejects a malformed recording", func(t *testing.T) {
+		if _, err := NewReplayer(strings.NewReader("{\"direction\":\"send\"}\nnot json\n")); err == nil {
+			t.Error("Expected an error for a malformed line")
+		}
+	})
+}
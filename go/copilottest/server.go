@@ -0,0 +1,486 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+// Package copilottest provides an in-memory stand-in for the Copilot CLI server, so
+// applications built on the SDK can unit test their agent logic without spawning the CLI.
+//
+// Example:
+//
+//	server := copilottest.NewServer()
+//	server.Reply(copilottest.Reply{
+//	    ToolCalls: []copilottest.ToolCall{{Name: "get_weather", Arguments: map[string]any{"city": "Oslo"}}},
+//	    Message:   "It's sunny in Oslo.",
+//	})
+//	client := server.NewClient(t, nil)
+//	session, _ := client.CreateSession(ctx, &copilot.SessionConfig{Tools: []copilot.Tool{weatherTool}})
+//	turn, _ := session.RunTurn(ctx, copilot.MessageOptions{Prompt: "What's the weather in Oslo?"})
+package copilottest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	copilot "github.com/github/copilot-sdk/go"
+	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
+)
+
+// Reply is the assistant's scripted response to one prompt
+type Reply struct {
+	// ToolCalls are run, in order, through the session's tools before the final message
+	ToolCalls []ToolCall
+	// Message is the assistant's final message
+	Message string
+	// Error, if set, ends the turn with a session.error event carrying this message
+	// instead of an assistant message
+	Error string
+}
+
+// ToolCall is a tool invocation the scripted assistant makes
+type ToolCall struct {
+	Name      string
+	Arguments any
+	// Permission, if set, is the permission request (its "kind" and kind-specific
+	// fields) sent to the session's permission handler before the tool runs. The call
+	// fails unless it is approved.
+	Permission map[string]any
+}
+
+// Server is a Copilot CLI server implemented in Go. It answers the calls a
+// [copilot.Client] makes, replies to each prompt with the next scripted [Reply], runs
+// scripted tool calls through the session's permission handler and tools, and emits the
+// events a real turn would. Clients connect in memory with [Server.NewClient] or over
+// TCP with [Server.Serve]. A Server is safe for concurrent use and can serve several
+// clients.
+type Server struct {
+	mu       sync.Mutex
+	replies  []Reply
+	onPrompt func(prompt string) Reply
+	sessions map[string]*session
+	nextID   int
+}
+
+// session is the server's state for one session
+type session struct {
+	id                string
+	rpc               *jsonrpc2.Client // guarded by Server.mu
+	created           time.Time
+	requestPermission bool                   // whether the client handles permission requests; guarded by Server.mu
+	events            []copilot.SessionEvent // guarded by Server.mu
+	prompts           []string               // guarded by Server.mu
+	turnMu            sync.Mutex             // serializes turns
+	sendMu            sync.Mutex             // keeps events in order on the wire
+}
+
+// NewServer returns a Server with no scripted replies
+func NewServer() *Server {
+	return &Server{sessions: make(map[string]*session)}
+}
+
+// Reply queues replies for the next prompts, in order, across all sessions
+func (s *Server) Reply(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// OnPrompt sets a function that computes the reply to prompts once the queued replies
+// run out. Without one, such prompts end with a session.error event.
+func (s *Server) OnPrompt(handler func(prompt string) Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onPrompt = handler
+}
+
+// Prompts returns the prompts sent to the session so far
+func (s *Server) Prompts(sessionID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[sessionID]; ok {
+		return append([]string(nil), sess.prompts...)
+	}
+	return nil
+}
+
+// NewClient returns a client connected to the server in memory and started. options
+// may be nil; its connection settings are replaced. The client is stopped when the
+// test ends.
+func (s *Server) NewClient(t testing.TB, options *copilot.ClientOptions) *copilot.Client {
+	t.Helper()
+	client, stream := newPipeClient(options)
+	rpc := s.serve(stream)
+	t.Cleanup(rpc.Stop)
+	startClient(t, client)
+	return client
+}
+
+// Serve answers clients that connect to l, such as a client whose ClientOptions.CLIUrl
+// is l.Addr().String(), until l is closed. Connections use Content-Length framing.
+//
+// Example:
+//
+//	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+//	defer listener.Close()
+//	go server.Serve(listener)
+//	client := copilot.NewClient(&copilot.ClientOptions{CLIUrl: listener.Addr().String()})
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		s.serve(jsonrpc2.NewHeaderStream(conn, conn, 0))
+	}
+}
+
+// newPipeClient creates a client connected in memory to the returned stream, framed
+// as options asks
+func newPipeClient(options *copilot.ClientOptions) (*copilot.Client, jsonrpc2.Stream) {
+	var opts copilot.ClientOptions
+	if options != nil {
+		opts = *options
+	}
+	clientConn, serverConn := net.Pipe()
+	opts.Conn = clientConn
+	opts.CLIUrl, opts.CLIPath, opts.UseStdio, opts.Dial = "", "", nil, nil
+
+	var stream jsonrpc2.Stream
+	if opts.Framing == copilot.FramingNDJSON {
+		stream = jsonrpc2.NewNDJSONStream(serverConn, serverConn, 0)
+	} else {
+		stream = jsonrpc2.NewHeaderStream(serverConn, serverConn, 0)
+	}
+	return copilot.NewClient(&opts), stream
+}
+
+// startClient starts client and stops it when the test ends
+func startClient(t testing.TB, client *copilot.Client) {
+	t.Helper()
+	if err := client.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start client: %v", err)
+	}
+	t.Cleanup(client.ForceStop)
+}
+
+// Emit sends event to the session's client and adds it to the session's history. An
+// empty ID or zero Timestamp is filled in.
+func (s *Server) Emit(sessionID string, event copilot.SessionEvent) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown session %s", sessionID)
+	}
+	return s.emit(sess, event)
+}
+
+// emit sends event to the session's client and records it
+func (s *Server) emit(sess *session, event copilot.SessionEvent) error {
+	sess.sendMu.Lock()
+	defer sess.sendMu.Unlock()
+
+	s.mu.Lock()
+	if event.ID == "" {
+		event.ID = s.newID("event")
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	sess.events = append(sess.events, event)
+	rpc := sess.rpc
+	s.mu.Unlock()
+
+	return rpc.Notify("session.event", sessionEventNotification{SessionID: sess.id, Event: event})
+}
+
+// newID returns a unique ID with prefix; s.mu must be held
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", prefix, s.nextID)
+}
+
+// serve answers the client on the other end of stream
+func (s *Server) serve(stream jsonrpc2.Stream) *jsonrpc2.Client {
+	rpc := jsonrpc2.NewStreamClient(stream)
+	rpc.SetRequestHandler("ping", jsonrpc2.RequestHandlerFor(func(ctx context.Context, req pingRequest) (map[string]any, *jsonrpc2.Error) {
+		return map[string]any{"message": req.Message, "timestamp": time.Now().UnixMilli(), "protocolVersion": copilot.SdkProtocolVersion}, nil
+	}))
+	rpc.SetRequestHandler("status.get", jsonrpc2.RequestHandlerFor(func(ctx context.Context, req struct{}) (map[string]any, *jsonrpc2.Error) {
+		return map[string]any{"version": "copilottest", "protocolVersion": copilot.SdkProtocolVersion}, nil
+	}))
+	rpc.SetRequestHandler("session.create", jsonrpc2.RequestHandlerFor(func(ctx context.Context, req sessionRequest) (sessionResponse, *jsonrpc2.Error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		id := req.SessionID
+		if id == "" {
+			id = s.newID("session")
+		}
+		if _, ok := s.sessions[id]; ok {
+			return sessionResponse{}, &jsonrpc2.Error{Code: -32602, Message: fmt.Sprintf("session %s already exists", id)}
+		}
+		s.sessions[id] = &session{id: id, rpc: rpc, created: time.Now(), requestPermission: req.RequestPermission}
+		return sessionResponse{SessionID: id}, nil
+	}))
+	rpc.SetRequestHandler("session.resume", jsonrpc2.RequestHandlerFor(func(ctx context.Context, req sessionRequest) (sessionResponse, *jsonrpc2.Error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		sess, ok := s.sessions[req.SessionID]
+		if !ok {
+			return sessionResponse{}, &jsonrpc2.Error{Code: -32602, Message: fmt.Sprintf("Session not found: %s", req.SessionID)}
+		}
+		sess.rpc = rpc
+		sess.requestPermission = req.RequestPermission
+		return sessionResponse{SessionID: sess.id}, nil
+	}))
+	rpc.SetRequestHandler("session.list", jsonrpc2.RequestHandlerFor(func(ctx context.Context, req struct{}) (map[string]any, *jsonrpc2.Error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		sessions := []copilot.SessionMetadata{}
+		for _, sess := range s.sessions {
+			modified := sess.created
+			if n := len(sess.events); n > 0 {
+				modified = sess.events[n-1].Timestamp
+			}
+			sessions = append(sessions, copilot.SessionMetadata{
+				SessionID:    sess.id,
+				StartTime:    sess.created.Format(time.RFC3339),
+				ModifiedTime: modified.Format(time.RFC3339),
+			})
+		}
+		return map[string]any{"sessions": sessions}, nil
+	}))
+	rpc.SetRequestHandler("session.delete", s.sessionHandler(func(sess *session, params json.RawMessage) (any, *jsonrpc2.Error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.sessions, sess.id)
+		return map[string]any{"success": true}, nil
+	}))
+	rpc.SetRequestHandler("session.send", s.sessionHandler(func(sess *session, params json.RawMessage) (any, *jsonrpc2.Error) {
+		var req sendRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, &jsonrpc2.Error{Code: -32602, Message: err.Error()}
+		}
+		s.mu.Lock()
+		sess.prompts = append(sess.prompts, req.Prompt)
+		messageID := s.newID("message")
+		s.mu.Unlock()
+		go s.runTurn(sess, req.Prompt, messageID)
+		return map[string]string{"messageId": messageID}, nil
+	}))
+	rpc.SetRequestHandler("session.getMessages", s.sessionHandler(func(sess *session, params json.RawMessage) (any, *jsonrpc2.Error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return map[string]any{"events": append([]copilot.SessionEvent{}, sess.events...)}, nil
+	}))
+	for _, method := range []string{"session.destroy", "session.abort", "session.updateTools", "session.updateHooks"} {
+		rpc.SetRequestHandler(method, s.sessionHandler(func(sess *session, params json.RawMessage) (any, *jsonrpc2.Error) {
+			return map[string]any{}, nil
+		}))
+	}
+	rpc.Start()
+	return rpc
+}
+
+// sessionHandler adapts handler to a request handler that looks up the session named
+// by the request's sessionId
+func (s *Server) sessionHandler(handler func(sess *session, params json.RawMessage) (any, *jsonrpc2.Error)) jsonrpc2.RequestHandler {
+	return func(ctx context.Context, params json.RawMessage) (json.RawMessage, *jsonrpc2.Error) {
+		var req struct {
+			SessionID string `json:"sessionId"`
+		}
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, &jsonrpc2.Error{Code: -32602, Message: err.Error()}
+		}
+		s.mu.Lock()
+		sess, ok := s.sessions[req.SessionID]
+		s.mu.Unlock()
+		if !ok {
+			return nil, &jsonrpc2.Error{Code: -32602, Message: fmt.Sprintf("Session not found: %s", req.SessionID)}
+		}
+
+		result, rpcErr := handler(sess, params)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, &jsonrpc2.Error{Code: -32603, Message: err.Error()}
+		}
+		return data, nil
+	}
+}
+
+// nextReply returns the reply to prompt
+func (s *Server) nextReply(prompt string) Reply {
+	s.mu.Lock()
+	if len(s.replies) > 0 {
+		reply := s.replies[0]
+		s.replies = s.replies[1:]
+		s.mu.Unlock()
+		return reply
+	}
+	onPrompt := s.onPrompt
+	s.mu.Unlock()
+
+	if onPrompt != nil {
+		return onPrompt(prompt)
+	}
+	return Reply{Error: fmt.Sprintf("copilottest: no reply scripted for prompt %q", prompt)}
+}
+
+// runTurn plays the reply to prompt as the events of one turn
+func (s *Server) runTurn(sess *session, prompt, messageID string) {
+	sess.turnMu.Lock()
+	defer sess.turnMu.Unlock()
+
+	reply := s.nextReply(prompt)
+	s.emit(sess, copilot.SessionEvent{Type: copilot.UserMessage, Data: copilot.Data{Content: &prompt}})
+	s.emit(sess, copilot.SessionEvent{Type: copilot.AssistantTurnStart})
+
+	for _, call := range reply.ToolCalls {
+		s.mu.Lock()
+		toolCallID := s.newID("call")
+		s.mu.Unlock()
+		toolName := call.Name
+		s.emit(sess, copilot.SessionEvent{Type: copilot.ToolExecutionStart, Data: copilot.Data{
+			ToolCallID: &toolCallID,
+			ToolName:   &toolName,
+			Arguments:  call.Arguments,
+		}})
+
+		success, output := false, ""
+		if approved, kind := s.requestPermission(sess, toolCallID, call); !approved {
+			output = fmt.Sprintf("Permission denied: %s", kind)
+		} else {
+			success, output = s.callTool(sess, toolCallID, call)
+		}
+		s.emit(sess, copilot.SessionEvent{Type: copilot.ToolExecutionComplete, Data: copilot.Data{
+			ToolCallID: &toolCallID,
+			Success:    &success,
+			Result:     &copilot.Result{Content: output},
+		}})
+	}
+
+	if reply.Error != "" {
+		s.emit(sess, copilot.SessionEvent{Type: copilot.SessionError, Data: copilot.Data{Message: &reply.Error}})
+		return
+	}
+	s.emit(sess, copilot.SessionEvent{Type: copilot.AssistantMessage, Data: copilot.Data{
+		Content:   &reply.Message,
+		MessageID: &messageID,
+	}})
+	s.emit(sess, copilot.SessionEvent{Type: copilot.AssistantTurnEnd})
+	s.emit(sess, copilot.SessionEvent{Type: copilot.SessionIdle})
+}
+
+// requestPermission asks the client to approve call's permission request, if it has
+// one, and returns whether it was approved and the result kind
+func (s *Server) requestPermission(sess *session, toolCallID string, call ToolCall) (bool, string) {
+	if call.Permission == nil {
+		return true, ""
+	}
+	s.mu.Lock()
+	rpc, requestPermission := sess.rpc, sess.requestPermission
+	s.mu.Unlock()
+	if !requestPermission {
+		return false, "denied-no-approval-rule-and-could-not-request-from-user"
+	}
+
+	request := map[string]any{"toolCallId": toolCallID}
+	for key, value := range call.Permission {
+		request[key] = value
+	}
+	result, err := rpc.Request(context.Background(), "permission.request", permissionRequest{
+		SessionID: sess.id,
+		Request:   request,
+	})
+	if err != nil {
+		return false, err.Error()
+	}
+	var response permissionResponse
+	if err := json.Unmarshal(result, &response); err != nil {
+		return false, fmt.Sprintf("invalid permission result: %v", err)
+	}
+	return response.Result.Kind == "approved", response.Result.Kind
+}
+
+// callTool runs call through the client's tool handler and returns whether it
+// succeeded and its output
+func (s *Server) callTool(sess *session, toolCallID string, call ToolCall) (bool, string) {
+	s.mu.Lock()
+	rpc := sess.rpc
+	s.mu.Unlock()
+	result, err := rpc.Request(context.Background(), "tool.call", toolCallRequest{
+		SessionID:  sess.id,
+		ToolCallID: toolCallID,
+		ToolName:   call.Name,
+		Arguments:  call.Arguments,
+	})
+	if err != nil {
+		return false, err.Error()
+	}
+	var response toolCallResponse
+	if err := json.Unmarshal(result, &response); err != nil {
+		return false, fmt.Sprintf("invalid tool result: %v", err)
+	}
+	output := response.Result.TextResultForLLM
+	if output == "" {
+		output = response.Result.Error
+	}
+	return response.Result.ResultType == "success", output
+}
+
+// Wire formats of the calls the server answers and makes
+
+type pingRequest struct {
+	Message string `json:"message"`
+}
+
+type sessionRequest struct {
+	SessionID         string `json:"sessionId"`
+	RequestPermission bool   `json:"requestPermission"`
+}
+
+type sessionResponse struct {
+	SessionID     string `json:"sessionId"`
+	WorkspacePath string `json:"workspacePath"`
+}
+
+type sendRequest struct {
+	SessionID string `json:"sessionId"`
+	Prompt    string `json:"prompt"`
+}
+
+type sessionEventNotification struct {
+	SessionID string               `json:"sessionId"`
+	Event     copilot.SessionEvent `json:"event"`
+}
+
+type toolCallRequest struct {
+	SessionID  string `json:"sessionId"`
+	ToolCallID string `json:"toolCallId"`
+	ToolName   string `json:"toolName"`
+	Arguments  any    `json:"arguments"`
+}
+
+type toolCallResponse struct {
+	Result copilot.ToolResult `json:"result"`
+}
+
+type permissionRequest struct {
+	SessionID string         `json:"sessionId"`
+	Request   map[string]any `json:"permissionRequest"`
+}
+
+type permissionResponse struct {
+	Result copilot.PermissionRequestResult `json:"result"`
+}
@@ -0,0 +1,187 @@
+package copilottest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	copilot "github.com/github/copilot-sdk/go"
+)
+
+func TestServer(t *testing.T) {
+	type weatherParams struct {
+		City string `json:"city"`
+	}
+	weatherTool := copilot.DefineTool("get_weather", "Get the weather", func(params weatherParams, inv copilot.ToolInvocation) (string, error) {
+		return "sunny in " + params.City, nil
+	})
+
+	t.Run("plays scripted replies and runs tool calls through session tools", func(t *testing.T) {
+		server := NewServer()
+		server.Reply(Reply{
+			ToolCalls: []ToolCall{{Name: "get_weather", Arguments: map[string]any{"city": "Oslo"}}},
+			Message:   "It's sunny in Oslo.",
+		})
+		client := server.NewClient(t, nil)
+		session, err := client.CreateSession(t.Context(), &copilot.SessionConfig{Tools: []copilot.Tool{weatherTool}})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+
+		turn, err := session.RunTurn(t.Context(), copilot.MessageOptions{Prompt: "Weather in Oslo?"})
+		if err != nil {
+			t.Fatalf("RunTurn failed: %v", err)
+		}
+		if turn.Message == nil || *turn.Message.Data.Content != "It's sunny in Oslo." {
+			t.Errorf("Unexpected message %+v", turn.Message)
+		}
+		if len(turn.ToolCalls) != 1 || !turn.ToolCalls[0].Success || turn.ToolCalls[0].Output != "sunny in Oslo" {
+			t.Errorf("Unexpected tool calls %+v", turn.ToolCalls)
+		}
+		if prompts := server.Prompts(session.SessionID); len(prompts) != 1 || prompts[0] != "Weather in Oslo?" {
+			t.Errorf("Unexpected prompts %v", prompts)
+		}
+
+		history, err := session.GetMessages(t.Context())
+		if err != nil || len(history) != len(turn.Events) {
+			t.Errorf("Expected the turn's %d events in history, got %d, %v", len(turn.Events), len(history), err)
+		}
+	})
+
+	t.Run("reports a failing tool and computes replies with OnPrompt", func(t *testing.T) {
+		server := NewServer()
+		server.OnPrompt(func(prompt string) Reply {
+			return Reply{ToolCalls: []ToolCall{{Name: "missing_tool"}}, Message: "echo: " + prompt}
+		})
+		client := server.NewClient(t, nil)
+		session, err := client.CreateSession(t.Context(), nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+
+		turn, err := session.RunTurn(t.Context(), copilot.MessageOptions{Prompt: "hi"})
+		if err != nil {
+			t.Fatalf("RunTurn failed: %v", err)
+		}
+		if *turn.Message.Data.Content != "echo: hi" {
+			t.Errorf("Unexpected message %q", *turn.Message.Data.Content)
+		}
+		if len(turn.ToolCalls) != 1 || turn.ToolCalls[0].Success {
+			t.Errorf("Expected a failed tool call, got %+v", turn.ToolCalls)
+		}
+	})
+
+	t.Run("ends unscripted turns with a session error", func(t *testing.T) {
+		server := NewServer()
+		client := server.NewClient(t, nil)
+		session, err := client.CreateSession(t.Context(), nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+
+		if _, err := session.SendAndWait(t.Context(), copilot.MessageOptions{Prompt: "hi"}); err == nil {
+			t.Error("Expected an error for an unscripted prompt")
+		}
+	})
+
+	t.Run("emits custom events", func(t *testing.T) {
+		server := NewServer()
+		client := server.NewClient(t, nil)
+		session, err := client.CreateSession(t.Context(), &copilot.SessionConfig{SessionID: "s1"})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		events := session.Events(t.Context(), 1)
+
+		intent := "Planning"
+		if err := server.Emit("s1", copilot.SessionEvent{Type: copilot.AssistantIntent, Data: copilot.Data{Intent: &intent}}); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+		event := <-events
+		if event.Type != copilot.AssistantIntent || event.ID == "" || *event.Data.Intent != "Planning" {
+			t.Errorf("Unexpected event %+v", event)
+		}
+		if err := server.Emit("unknown", copilot.SessionEvent{Type: copilot.SessionIdle}); err == nil {
+			t.Error("Expected an error for an unknown session")
+		}
+	})
+
+	t.Run("asks the permission handler before running a tool", func(t *testing.T) {
+		server := NewServer()
+		call := ToolCall{Name: "get_weather", Arguments: map[string]any{"city": "Oslo"}, Permission: map[string]any{"kind": "url", "url": "https://weather.example"}}
+		server.Reply(Reply{ToolCalls: []ToolCall{call}, Message: "done"}, Reply{ToolCalls: []ToolCall{call}, Message: "done"})
+		client := server.NewClient(t, nil)
+
+		var requests []copilot.PermissionRequest
+		approve := true
+		session, err := client.CreateSession(t.Context(), &copilot.SessionConfig{
+			Tools: []copilot.Tool{weatherTool},
+			OnPermissionRequest: func(ctx context.Context, request copilot.PermissionRequest, inv copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
+				requests = append(requests, request)
+				if approve {
+					return copilot.PermissionRequestResult{Kind: "approved"}, nil
+				}
+				return copilot.PermissionRequestResult{Kind: "denied-interactively-by-user"}, nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+
+		turn, err := session.RunTurn(t.Context(), copilot.MessageOptions{Prompt: "approved"})
+		if err != nil {
+			t.Fatalf("RunTurn failed: %v", err)
+		}
+		if !turn.ToolCalls[0].Success {
+			t.Errorf("Expected the approved tool to run, got %+v", turn.ToolCalls[0])
+		}
+		if url, ok := requests[0].URL(); !ok || url.URL != "https://weather.example" || requests[0].ToolCallID != turn.ToolCalls[0].ToolCallID {
+			t.Errorf("Unexpected permission request %+v", requests[0])
+		}
+
+		approve = false
+		turn, err = session.RunTurn(t.Context(), copilot.MessageOptions{Prompt: "denied"})
+		if err != nil {
+			t.Fatalf("RunTurn failed: %v", err)
+		}
+		if turn.ToolCalls[0].Success || turn.ToolCalls[0].Output != "Permission denied: denied-interactively-by-user" {
+			t.Errorf("Expected the denied tool not to run, got %+v", turn.ToolCalls[0])
+		}
+	})
+
+	t.Run("serves clients over TCP", func(t *testing.T) {
+		server := NewServer()
+		server.Reply(Reply{Message: "hello"})
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen failed: %v", err)
+		}
+		defer listener.Close()
+		go server.Serve(listener)
+
+		client := copilot.NewClient(&copilot.ClientOptions{CLIUrl: listener.Addr().String()})
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		defer client.ForceStop()
+		session, err := client.CreateSession(t.Context(), nil)
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		message, err := session.SendAndWait(t.Context(), copilot.MessageOptions{Prompt: "hi"})
+		if err != nil || *message.Data.Content != "hello" {
+			t.Fatalf("Expected the scripted reply, got %+v, %v", message, err)
+		}
+
+		sessions, err := client.ListSessions(t.Context())
+		if err != nil || len(sessions) != 1 || sessions[0].SessionID != session.SessionID {
+			t.Errorf("Expected the session to be listed, got %+v, %v", sessions, err)
+		}
+		if err := client.DeleteSession(t.Context(), session.SessionID); err != nil {
+			t.Errorf("DeleteSession failed: %v", err)
+		}
+		if sessions, _ := client.ListSessions(t.Context()); len(sessions) != 0 {
+			t.Errorf("Expected no sessions after delete, got %+v", sessions)
+		}
+	})
+}
@@ -5,6 +5,7 @@
 package copilot
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -39,20 +40,66 @@ func DefineTool[T any, U any](name, description string, handler func(T, ToolInvo
 	}
 }
 
+// NewTool creates a Tool whose parameter schema is generated from T's struct tags and
+// whose handler receives the arguments decoded into T. Unlike [DefineTool], the handler
+// receives the invocation's context, which is cancelled when the session is aborted or
+// destroyed, and returns a ToolResult directly.
+//
+// Example:
+//
+//	type SearchParams struct {
+//	    Query string `json:"query" jsonschema:"text to search for"`
+//	    Limit int    `json:"limit,omitempty" jsonschema:"maximum number of results"`
+//	}
+//
+//	tool := copilot.NewTool("search_docs", "Search the documentation",
+//	    func(ctx context.Context, params SearchParams) (copilot.ToolResult, error) {
+//	        hits, err := index.Search(ctx, params.Query, params.Limit)
+//	        if err != nil {
+//	            return copilot.ToolFailure(err), nil
+//	        }
+//	        return copilot.ToolSuccessJSON(hits)
+//	    })
+func NewTool[T any](name, description string, handler func(context.Context, T) (ToolResult, error)) Tool {
+	var zero T
+	schema := generateSchemaForType(reflect.TypeOf(zero))
+
+	return Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  schema,
+		Handler: func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+			params, err := decodeToolArguments[T](inv.Arguments)
+			if err != nil {
+				return ToolResult{}, err
+			}
+			return handler(ctx, params)
+		},
+	}
+}
+
+// decodeToolArguments converts invocation arguments into T via a JSON round-trip.
+// Arguments is already map[string]any from JSON-RPC parsing.
+func decodeToolArguments[T any](arguments any) (T, error) {
+	var params T
+
+	jsonBytes, err := json.Marshal(arguments)
+	if err != nil {
+		return params, fmt.Errorf("failed to marshal arguments: %w", err)
+	}
+
+	if err := json.Unmarshal(jsonBytes, &params); err != nil {
+		return params, fmt.Errorf("failed to unmarshal arguments into %T: %w", params, err)
+	}
+	return params, nil
+}
+
 // createTypedHandler wraps a typed handler function into the standard ToolHandler signature.
 func createTypedHandler[T any, U any](handler func(T, ToolInvocation) (U, error)) ToolHandler {
-	return func(inv ToolInvocation) (ToolResult, error) {
-		var params T
-
-		// Convert arguments to typed struct via JSON round-trip
-		// Arguments is already map[string]any from JSON-RPC parsing
-		jsonBytes, err := json.Marshal(inv.Arguments)
+	return func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+		params, err := decodeToolArguments[T](inv.Arguments)
 		if err != nil {
-			return ToolResult{}, fmt.Errorf("failed to marshal arguments: %w", err)
-		}
-
-		if err := json.Unmarshal(jsonBytes, &params); err != nil {
-			return ToolResult{}, fmt.Errorf("failed to unmarshal arguments into %T: %w", params, err)
+			return ToolResult{}, err
 		}
 
 		result, err := handler(params, inv)
@@ -64,14 +111,56 @@ func createTypedHandler[T any, U any](handler func(T, ToolInvocation) (U, error)
 	}
 }
 
+// ToolSuccess returns a successful ToolResult with text as the result for the model.
+func ToolSuccess(text string) ToolResult {
+	return ToolResult{
+		TextResultForLLM: text,
+		ResultType:       "success",
+		ToolTelemetry:    map[string]any{},
+	}
+}
+
+// ToolSuccessJSON returns a successful ToolResult with v serialized as JSON for the model.
+// It returns an error if v cannot be serialized, so handlers can return it directly.
+//
+// Example:
+//
+//	func(ctx context.Context, params LookupParams) (copilot.ToolResult, error) {
+//	    user, err := db.FindUser(ctx, params.ID)
+//	    if err != nil {
+//	        return copilot.ToolFailure(err), nil
+//	    }
+//	    return copilot.ToolSuccessJSON(user)
+//	}
+func ToolSuccessJSON(v any) (ToolResult, error) {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return ToolResult{}, fmt.Errorf("failed to serialize result: %w", err)
+	}
+	return ToolSuccess(string(jsonBytes)), nil
+}
+
+// ToolFailure returns a failed ToolResult that tells the model what went wrong.
+// Unlike returning an error from a handler, which hides the details from the model,
+// err's message is included in the result for the model. A nil err yields a generic
+// failure.
+func ToolFailure(err error) ToolResult {
+	if err == nil {
+		return buildFailedToolResult("unknown error")
+	}
+	return ToolResult{
+		TextResultForLLM: fmt.Sprintf("Invoking this tool produced an error: %v", err),
+		ResultType:       "failure",
+		Error:            err.Error(),
+		ToolTelemetry:    map[string]any{},
+	}
+}
+
 // normalizeResult converts any value to a ToolResult.
 // Strings pass through directly, ToolResult passes through, other types are JSON-serialized.
 func normalizeResult(result any) (ToolResult, error) {
 	if result == nil {
-		return ToolResult{
-			TextResultForLLM: "",
-			ResultType:       "success",
-		}, nil
+		return ToolSuccess(""), nil
 	}
 
 	// ToolResult passes through directly
@@ -81,22 +170,11 @@ func normalizeResult(result any) (ToolResult, error) {
 
 	// Strings pass through directly
 	if str, ok := result.(string); ok {
-		return ToolResult{
-			TextResultForLLM: str,
-			ResultType:       "success",
-		}, nil
+		return ToolSuccess(str), nil
 	}
 
 	// Everything else gets JSON-serialized
-	jsonBytes, err := json.Marshal(result)
-	if err != nil {
-		return ToolResult{}, fmt.Errorf("failed to serialize result: %w", err)
-	}
-
-	return ToolResult{
-		TextResultForLLM: string(jsonBytes),
-		ResultType:       "success",
-	}, nil
+	return ToolSuccessJSON(result)
 }
 
 // generateSchemaForType generates a JSON schema map from a Go type using reflection.
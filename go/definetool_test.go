@@ -1,8 +1,10 @@
 package copilot
 
 import (
+	"context"
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -83,7 +85,7 @@ func TestDefineTool(t *testing.T) {
 			},
 		}
 
-		_, err := tool.Handler(inv)
+		_, err := tool.Handler(t.Context(), inv)
 		if err != nil {
 			t.Fatalf("Handler returned error: %v", err)
 		}
@@ -113,7 +115,7 @@ func TestDefineTool(t *testing.T) {
 			Arguments:  map[string]any{},
 		}
 
-		tool.Handler(inv)
+		tool.Handler(t.Context(), inv)
 
 		if receivedInv.SessionID != "session-123" {
 			t.Errorf("Expected SessionID 'session-123', got %q", receivedInv.SessionID)
@@ -135,7 +137,7 @@ func TestDefineTool(t *testing.T) {
 			Arguments: map[string]any{},
 		}
 
-		_, err := tool.Handler(inv)
+		_, err := tool.Handler(t.Context(), inv)
 		if err == nil {
 			t.Fatal("Expected error, got nil")
 		}
@@ -145,6 +147,107 @@ func TestDefineTool(t *testing.T) {
 	})
 }
 
+func TestNewTool(t *testing.T) {
+	type Params struct {
+		Query string `json:"query" jsonschema:"text to search for"`
+		Limit int    `json:"limit,omitempty"`
+	}
+
+	t.Run("generates schema from the parameter type", func(t *testing.T) {
+		tool := NewTool("search", "Search", func(ctx context.Context, params Params) (ToolResult, error) {
+			return ToolResult{}, nil
+		})
+
+		props, ok := tool.Parameters["properties"].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected properties to be map, got %T", tool.Parameters["properties"])
+		}
+		if _, ok := props["query"]; !ok {
+			t.Error("Expected 'query' property in schema")
+		}
+		if _, ok := props["limit"]; !ok {
+			t.Error("Expected 'limit' property in schema")
+		}
+	})
+
+	t.Run("decodes arguments and passes the context", func(t *testing.T) {
+		type ctxKey struct{}
+		var received Params
+		var receivedValue any
+		tool := NewTool("search", "Search", func(ctx context.Context, params Params) (ToolResult, error) {
+			received = params
+			receivedValue = ctx.Value(ctxKey{})
+			return ToolResult{TextResultForLLM: "found", ResultType: "success"}, nil
+		})
+
+		ctx := context.WithValue(t.Context(), ctxKey{}, "marker")
+		result, err := tool.Handler(ctx, ToolInvocation{Arguments: map[string]any{"query": "go", "limit": float64(3)}})
+		if err != nil {
+			t.Fatalf("Handler failed: %v", err)
+		}
+		if received.Query != "go" || received.Limit != 3 {
+			t.Errorf("Unexpected params: %+v", received)
+		}
+		if receivedValue != "marker" {
+			t.Error("Expected handler to receive the invocation context")
+		}
+		if result.TextResultForLLM != "found" {
+			t.Errorf("Expected handler result to pass through, got %+v", result)
+		}
+	})
+
+	t.Run("returns an error for mismatched arguments", func(t *testing.T) {
+		tool := NewTool("search", "Search", func(ctx context.Context, params Params) (ToolResult, error) {
+			t.Error("Handler should not be called")
+			return ToolResult{}, nil
+		})
+
+		if _, err := tool.Handler(t.Context(), ToolInvocation{Arguments: map[string]any{"query": 42}}); err == nil {
+			t.Error("Expected error for invalid arguments")
+		}
+	})
+}
+
+func TestToolResultConstructors(t *testing.T) {
+	t.Run("ToolSuccess", func(t *testing.T) {
+		result := ToolSuccess("done")
+		if result.TextResultForLLM != "done" || result.ResultType != "success" || result.ToolTelemetry == nil {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("ToolSuccessJSON", func(t *testing.T) {
+		result, err := ToolSuccessJSON(map[string]int{"count": 2})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.TextResultForLLM != `{"count":2}` || result.ResultType != "success" {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+
+		if _, err := ToolSuccessJSON(make(chan int)); err == nil {
+			t.Error("Expected error for unserializable value")
+		}
+	})
+
+	t.Run("ToolFailure", func(t *testing.T) {
+		result := ToolFailure(errors.New("record not found"))
+		if result.ResultType != "failure" || result.Error != "record not found" || result.ToolTelemetry == nil {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+		if !strings.Contains(result.TextResultForLLM, "record not found") {
+			t.Errorf("Expected error message for the model, got %q", result.TextResultForLLM)
+		}
+	})
+
+	t.Run("ToolFailure with nil error", func(t *testing.T) {
+		result := ToolFailure(nil)
+		if result.ResultType != "failure" || result.Error == "" || result.TextResultForLLM == "" {
+			t.Errorf("Expected a generic failure result, got %+v", result)
+		}
+	})
+}
+
 func TestNormalizeResult(t *testing.T) {
 	t.Run("nil returns empty success result", func(t *testing.T) {
 		result, err := normalizeResult(nil)
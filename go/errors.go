@@ -0,0 +1,133 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
+)
+
+// Errors returned by the SDK. Use errors.Is to test for them, since they are
+// usually wrapped with more context.
+var (
+	// ErrNotConnected is returned when a call needs a connection to the CLI server
+	// and the client is not connected
+	ErrNotConnected = errors.New("client not connected")
+	// ErrSessionNotFound is returned when the server does not know the session
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionDestroyed is returned by session methods called after [Session.Destroy]
+	ErrSessionDestroyed = errors.New("session destroyed")
+	// ErrProtocolMismatch is returned by [Client.Start] when the server's protocol
+	// version is outside the range the SDK supports
+	ErrProtocolMismatch = errors.New("SDK protocol version mismatch")
+	// ErrTimeout is returned when a request's context deadline passes before the
+	// server responds. Such errors also match context.DeadlineExceeded.
+	ErrTimeout = jsonrpc2.ErrTimeout
+	// ErrConnectionLost is returned when the connection to the CLI server drops while
+	// a request is waiting for its response
+	ErrConnectionLost = jsonrpc2.ErrConnectionLost
+	// ErrUnauthorized is returned when the server rejects a request because the
+	// client is not authenticated or its token is invalid
+	ErrUnauthorized = errors.New("not authorized")
+	// ErrRateLimited is returned when the server rejects a request because of rate
+	// limiting; see [RPCError.RetryAfter]
+	ErrRateLimited = errors.New("rate limited")
+	// ErrCLINotFound is returned by [Client.ResolveCLI] and [Client.Start] when none of
+	// the configured CLI sources yields an executable
+	ErrCLINotFound = errors.New("Copilot CLI not found")
+	// ErrUnsupported is returned when the CLI server doesn't implement a request,
+	// e.g. because it is older than the SDK or the request is one the SDK assumes
+	ErrUnsupported = errors.New("not supported by the CLI server")
+)
+
+// RPCError is an error response from the CLI server. Well-known failures also match
+// [ErrSessionNotFound], [ErrUnauthorized], [ErrRateLimited], or [ErrUnsupported] with
+// errors.Is.
+//
+// Example:
+//
+//	var rpcErr *copilot.RPCError
+//	if errors.Is(err, copilot.ErrRateLimited) && errors.As(err, &rpcErr) {
+//	    time.Sleep(rpcErr.RetryAfter)
+//	}
+type RPCError struct {
+	// Code is the JSON-RPC error code
+	Code    int
+	Message string
+	// Data is the additional information the server attached to the error, if any
+	Data map[string]any
+	// RetryAfter is how long the server asked callers to wait before retrying, taken
+	// from a rate-limit error's "retryAfter" data in seconds. Zero if not given.
+	RetryAfter time.Duration
+	kind       error // the sentinel the error matches, if any
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("JSON-RPC Error %d: %s", e.Code, e.Message)
+}
+
+func (e *RPCError) Unwrap() error {
+	return e.kind
+}
+
+// methodNotFoundCode is the JSON-RPC error code for an unknown method
+const methodNotFoundCode = -32601
+
+// wrapRPCError converts JSON-RPC error responses into an [*RPCError], classifying
+// well-known failures so they match the SDK's sentinel errors
+func wrapRPCError(err error) error {
+	var jsonErr *jsonrpc2.Error
+	if !errors.As(err, &jsonErr) {
+		return err
+	}
+
+	rpcErr := &RPCError{Code: jsonErr.Code, Message: jsonErr.Message, Data: jsonErr.Data}
+	if jsonErr.Code == methodNotFoundCode {
+		rpcErr.kind = ErrUnsupported
+	} else {
+		rpcErr.kind = classifyRPCError(jsonErr.Message, rpcErr.Data)
+	}
+	if rpcErr.kind == ErrRateLimited {
+		if seconds, ok := rpcErr.Data["retryAfter"].(float64); ok && seconds > 0 {
+			rpcErr.RetryAfter = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	return rpcErr
+}
+
+// classifyRPCError returns the sentinel matching an error response, recognized from
+// the "errorType" in its data, as used by session.error events, or else its message
+func classifyRPCError(message string, data map[string]any) error {
+	errorType, _ := data["errorType"].(string)
+	switch strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(errorType)) {
+	case "sessionnotfound":
+		return ErrSessionNotFound
+	case "authentication", "unauthorized":
+		return ErrUnauthorized
+	case "ratelimit", "ratelimited":
+		return ErrRateLimited
+	}
+
+	if isSessionNotFound(message) {
+		return ErrSessionNotFound
+	}
+	message = strings.ToLower(message)
+	switch {
+	case strings.Contains(message, "not authenticated") || strings.Contains(message, "unauthorized"):
+		return ErrUnauthorized
+	case strings.Contains(message, "rate limit"):
+		return ErrRateLimited
+	}
+	return nil
+}
+
+func isSessionNotFound(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "session") && strings.Contains(message, "not found")
+}
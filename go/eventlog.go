@@ -0,0 +1,88 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
+)
+
+// EventLogEntry is one line of the event log written to [ClientOptions.EventLog]
+type EventLogEntry struct {
+	SessionID string `json:"sessionId"`
+	// ReceivedAt is when the client received the event; Event.Timestamp is when the
+	// server emitted it
+	ReceivedAt time.Time    `json:"receivedAt"`
+	Event      SessionEvent `json:"event"`
+}
+
+// eventLog appends session events to a writer as JSON lines
+type eventLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	logger  *slog.Logger
+	failing bool // whether the last write failed, so a broken writer is logged once
+}
+
+func newEventLog(w io.Writer, logger *slog.Logger) *eventLog {
+	return &eventLog{w: w, logger: logger}
+}
+
+// record appends event to the log. Write errors are logged rather than returned so a
+// failing log never blocks event delivery.
+func (l *eventLog) record(sessionID string, event SessionEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Not a json.Encoder, which would fail every write after the first error
+	line, err := json.Marshal(EventLogEntry{SessionID: sessionID, ReceivedAt: time.Now(), Event: event})
+	if err == nil {
+		_, err = l.w.Write(append(line, '\n'))
+	}
+	if err != nil && !l.failing {
+		l.logger.Warn("failed to write event log", "error", err)
+	}
+	l.failing = err != nil
+}
+
+// ReadEventLog reads the entries of an event log written to [ClientOptions.EventLog],
+// e.g. to replay or analyze a session offline.
+//
+// Example:
+//
+//	f, _ := os.Open("events.jsonl")
+//	defer f.Close()
+//	entries, err := copilot.ReadEventLog(f)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, entry := range entries {
+//	    fmt.Println(entry.SessionID, entry.Event.Type)
+//	}
+func ReadEventLog(r io.Reader) ([]EventLogEntry, error) {
+	var entries []EventLogEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, jsonrpc2.DefaultMaxMessageSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry EventLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse event log line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+	return entries, nil
+}
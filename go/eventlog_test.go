@@ -0,0 +1,74 @@
+package copilot
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestEventLog(t *testing.T) {
+	t.Run("records every session event as a JSON line", func(t *testing.T) {
+		var log bytes.Buffer
+		client := NewClient(&ClientOptions{EventLog: &log})
+		session := newSession("session-1", nil, "")
+		client.sessions["session-1"] = session
+
+		content := "hello"
+		client.handleSessionEvent(sessionEventRequest{SessionID: "session-1", Event: SessionEvent{ID: "e1", Type: UserMessage, Data: Data{Content: &content}}})
+		client.handleSessionEvent(sessionEventRequest{SessionID: "session-2", Event: SessionEvent{ID: "e2", Type: SessionIdle}})
+
+		if lines := strings.Count(log.String(), "\n"); lines != 2 {
+			t.Fatalf("Expected 2 lines, got %d: %s", lines, log.String())
+		}
+		entries, err := ReadEventLog(&log)
+		if err != nil {
+			t.Fatalf("ReadEventLog failed: %v", err)
+		}
+		if entries[0].SessionID != "session-1" || entries[0].Event.ID != "e1" || *entries[0].Event.Data.Content != "hello" || entries[0].ReceivedAt.IsZero() {
+			t.Errorf("Unexpected first entry %+v", entries[0])
+		}
+		if entries[1].SessionID != "session-2" || entries[1].Event.Type != SessionIdle {
+			t.Errorf("Unexpected second entry %+v", entries[1])
+		}
+	})
+
+	t.Run("logs write failures once until the writer recovers", func(t *testing.T) {
+		var logged bytes.Buffer
+		w := &failingWriter{fail: true}
+		log := newEventLog(w, slog.New(slog.NewTextHandler(&logged, nil)))
+
+		log.record("session-1", SessionEvent{ID: "e1"})
+		log.record("session-1", SessionEvent{ID: "e2"})
+		if n := strings.Count(logged.String(), "failed to write event log"); n != 1 {
+			t.Fatalf("Expected one warning while failing, got %d: %s", n, logged.String())
+		}
+
+		w.fail = false
+		log.record("session-1", SessionEvent{ID: "e3"})
+		w.fail = true
+		log.record("session-1", SessionEvent{ID: "e4"})
+		if n := strings.Count(logged.String(), "failed to write event log"); n != 2 {
+			t.Errorf("Expected a new warning after recovering, got %d", n)
+		}
+	})
+
+	t.Run("reports malformed lines", func(t *testing.T) {
+		if _, err := ReadEventLog(strings.NewReader("{}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("Expected an error for line 2, got %v", err)
+		}
+	})
+}
+
+// failingWriter fails writes while fail is set
+type failingWriter struct {
+	fail bool
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("disk full")
+	}
+	return len(p), nil
+}
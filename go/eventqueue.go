@@ -0,0 +1,98 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// eventQueue delivers a session's events to its handlers on a separate goroutine, so
+// slow handlers don't stall the connection. The goroutine runs only while events are
+// queued.
+type eventQueue struct {
+	mu       sync.Mutex
+	notFull  *sync.Cond
+	events   []SessionEvent
+	size     int
+	overflow EventOverflowPolicy
+	dropped  int
+	running  bool
+	deliver  func(SessionEvent)
+}
+
+func newEventQueue(size int, overflow EventOverflowPolicy, deliver func(SessionEvent)) *eventQueue {
+	q := &eventQueue{size: size, overflow: overflow, deliver: deliver}
+	q.notFull = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues event for delivery, applying the overflow policy if the queue is full.
+// session.idle and session.error are never dropped, since waiters such as
+// [Session.SendAndWait] depend on them; they may push the queue past its size.
+func (q *eventQueue) push(event SessionEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	critical := event.Type == SessionIdle || event.Type == SessionError
+	for len(q.events) >= q.size {
+		if q.overflow == EventOverflowDrop || q.overflow == EventOverflowError {
+			if critical {
+				break
+			}
+			q.dropped++
+			return
+		}
+		q.notFull.Wait()
+	}
+	q.events = append(q.events, event)
+
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+}
+
+func (q *eventQueue) run() {
+	for {
+		q.mu.Lock()
+		dropped := 0
+		if q.overflow == EventOverflowError {
+			dropped, q.dropped = q.dropped, 0
+		}
+		if len(q.events) == 0 {
+			if dropped > 0 {
+				// Report drops that no later event will carry before going idle
+				q.mu.Unlock()
+				q.deliver(eventQueueOverflowEvent(dropped))
+				continue
+			}
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		event := q.events[0]
+		q.events = q.events[1:]
+		q.notFull.Signal()
+		q.mu.Unlock()
+
+		if dropped > 0 {
+			q.deliver(eventQueueOverflowEvent(dropped))
+		}
+		q.deliver(event)
+	}
+}
+
+// eventQueueOverflowEvent reports events dropped under EventOverflowError
+func eventQueueOverflowEvent(dropped int) SessionEvent {
+	errorType := "eventQueueOverflow"
+	message := fmt.Sprintf("session event queue full: %d events dropped", dropped)
+	return SessionEvent{
+		Type:      SessionError,
+		Timestamp: time.Now(),
+		Data:      Data{ErrorType: &errorType, Message: &message},
+	}
+}
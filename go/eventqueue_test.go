@@ -0,0 +1,152 @@
+package copilot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSession_EventQueue(t *testing.T) {
+	// blockedSession returns a session with a queued handler that blocks until release
+	// is closed, and the events it received
+	blockedSession := func(t *testing.T, size int, overflow EventOverflowPolicy) (*Session, chan struct{}, func() []SessionEvent) {
+		session := newSession("session-1", nil, "")
+		session.setEventQueue(size, overflow)
+
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var got []SessionEvent
+		session.On(func(event SessionEvent) {
+			<-release
+			mu.Lock()
+			got = append(got, event)
+			mu.Unlock()
+		})
+		received := func() []SessionEvent {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]SessionEvent(nil), got...)
+		}
+		return session, release, received
+	}
+
+	waitFor := func(t *testing.T, received func() []SessionEvent, n int) []SessionEvent {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if got := received(); len(got) >= n {
+				return got
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for %d events, got %d", n, len(received()))
+		return nil
+	}
+
+	t.Run("does not block dispatch on a slow handler", func(t *testing.T) {
+		session, release, received := blockedSession(t, 10, EventOverflowBlock)
+
+		for _, id := range []string{"e1", "e2", "e3"} {
+			session.dispatchEvent(SessionEvent{ID: id, Type: AssistantMessage})
+		}
+		close(release)
+
+		got := waitFor(t, received, 3)
+		if got[0].ID != "e1" || got[1].ID != "e2" || got[2].ID != "e3" {
+			t.Errorf("Expected events in order, got %v", got)
+		}
+	})
+
+	t.Run("drops events when the queue is full", func(t *testing.T) {
+		session, release, received := blockedSession(t, 1, EventOverflowDrop)
+
+		// e1 is being handled, e2 fills the queue, e3 is dropped
+		session.dispatchEvent(SessionEvent{ID: "e1"})
+		waitForQueueEmpty(t, session)
+		session.dispatchEvent(SessionEvent{ID: "e2"})
+		session.dispatchEvent(SessionEvent{ID: "e3"})
+		close(release)
+
+		waitFor(t, received, 2)
+		time.Sleep(10 * time.Millisecond)
+		if got := received(); len(got) != 2 || got[1].ID != "e2" {
+			t.Errorf("Expected e1 and e2 only, got %v", got)
+		}
+	})
+
+	t.Run("reports dropped events as a session error", func(t *testing.T) {
+		session, release, received := blockedSession(t, 1, EventOverflowError)
+
+		session.dispatchEvent(SessionEvent{ID: "e1"})
+		waitForQueueEmpty(t, session)
+		session.dispatchEvent(SessionEvent{ID: "e2"})
+		session.dispatchEvent(SessionEvent{ID: "e3"})
+		session.dispatchEvent(SessionEvent{ID: "e4"})
+		close(release)
+
+		got := waitFor(t, received, 3)
+		overflow := got[1]
+		if overflow.Type != SessionError || overflow.Data.ErrorType == nil || *overflow.Data.ErrorType != "eventQueueOverflow" {
+			t.Fatalf("Expected an overflow error event, got %+v", overflow)
+		}
+		if *overflow.Data.Message != "session event queue full: 2 events dropped" {
+			t.Errorf("Unexpected message %q", *overflow.Data.Message)
+		}
+		if got[2].ID != "e2" {
+			t.Errorf("Expected e2 after the error event, got %v", got[2].ID)
+		}
+	})
+
+	t.Run("never drops session.idle or session.error", func(t *testing.T) {
+		session, release, received := blockedSession(t, 1, EventOverflowDrop)
+
+		session.dispatchEvent(SessionEvent{ID: "e1"})
+		waitForQueueEmpty(t, session)
+		session.dispatchEvent(SessionEvent{ID: "e2"})
+		session.dispatchEvent(SessionEvent{ID: "e3"})
+		session.dispatchEvent(SessionEvent{ID: "err", Type: SessionError})
+		session.dispatchEvent(SessionEvent{ID: "idle", Type: SessionIdle})
+		close(release)
+
+		got := waitFor(t, received, 4)
+		if got[1].ID != "e2" || got[2].ID != "err" || got[3].ID != "idle" {
+			t.Errorf("Expected e1, e2, err, idle, got %v", got)
+		}
+	})
+
+	t.Run("reports drops when the queue drains", func(t *testing.T) {
+		var mu sync.Mutex
+		var got []SessionEvent
+		q := newEventQueue(1, EventOverflowError, func(event SessionEvent) {
+			mu.Lock()
+			got = append(got, event)
+			mu.Unlock()
+		})
+
+		// Simulate a drop that happened after the last queued event was taken
+		q.mu.Lock()
+		q.dropped = 3
+		q.running = true
+		q.mu.Unlock()
+		q.run()
+
+		if len(got) != 1 || got[0].Type != SessionError || *got[0].Data.Message != "session event queue full: 3 events dropped" {
+			t.Errorf("Expected an overflow error event, got %+v", got)
+		}
+	})
+}
+
+// waitForQueueEmpty waits until the session's event queue has handed all events to the handler
+func waitForQueueEmpty(t *testing.T, session *Session) {
+	t.Helper()
+	for range 5000 {
+		session.eventQueue.mu.Lock()
+		empty := len(session.eventQueue.events) == 0
+		session.eventQueue.mu.Unlock()
+		if empty {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for the event queue to drain")
+}
@@ -0,0 +1,60 @@
+package copilot_test
+
+import (
+	"sync"
+	"testing"
+
+	copilot "github.com/github/copilot-sdk/go"
+	"github.com/github/copilot-sdk/go/copilottest"
+)
+
+// These tests run the SDK against copilottest's Go implementation of the CLI server,
+// so they need neither Node nor the CLI.
+func TestClient_FakeCLI(t *testing.T) {
+	t.Run("runs a turn and replays its history on resume", func(t *testing.T) {
+		server := copilottest.NewServer()
+		server.Reply(copilottest.Reply{
+			ToolCalls: []copilottest.ToolCall{{Name: "add", Arguments: map[string]any{"a": 2, "b": 3}}},
+			Message:   "2 + 3 = 5",
+		})
+		type addParams struct {
+			A int `json:"a"`
+			B int `json:"b"`
+		}
+		add := copilot.DefineTool("add", "Add two numbers", func(params addParams, inv copilot.ToolInvocation) (int, error) {
+			return params.A + params.B, nil
+		})
+
+		client := server.NewClient(t, nil)
+		session, err := client.CreateSession(t.Context(), &copilot.SessionConfig{Tools: []copilot.Tool{add}})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		turn, err := session.RunTurn(t.Context(), copilot.MessageOptions{Prompt: "What is 2 + 3?"})
+		if err != nil {
+			t.Fatalf("RunTurn failed: %v", err)
+		}
+		if len(turn.ToolCalls) != 1 || turn.ToolCalls[0].Output != "5" {
+			t.Errorf("Expected the tool to return 5, got %+v", turn.ToolCalls)
+		}
+
+		var mu sync.Mutex
+		var replayed []copilot.SessionEvent
+		_, err = client.ResumeSessionWithOptions(t.Context(), session.SessionID, &copilot.ResumeSessionConfig{
+			ReplayHistory: true,
+			OnEvent: func(event copilot.SessionEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				replayed = append(replayed, event)
+			},
+		})
+		if err != nil {
+			t.Fatalf("ResumeSession failed: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(replayed) != len(turn.Events) || replayed[len(replayed)-1].Type != copilot.SessionIdle {
+			t.Errorf("Expected the turn's %d events to be replayed, got %d", len(turn.Events), len(replayed))
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"context"
 	"sync"
 	"testing"
 
@@ -20,7 +21,7 @@ func TestAskUser(t *testing.T) {
 		var mu sync.Mutex
 
 		session, err := client.CreateSession(t.Context(), &copilot.SessionConfig{
-			OnUserInputRequest: func(request copilot.UserInputRequest, invocation copilot.UserInputInvocation) (copilot.UserInputResponse, error) {
+			OnUserInputRequest: func(_ context.Context, request copilot.UserInputRequest, invocation copilot.UserInputInvocation) (copilot.UserInputResponse, error) {
 				mu.Lock()
 				userInputRequests = append(userInputRequests, request)
 				mu.Unlock()
@@ -80,7 +81,7 @@ func TestAskUser(t *testing.T) {
 		var mu sync.Mutex
 
 		session, err := client.CreateSession(t.Context(), &copilot.SessionConfig{
-			OnUserInputRequest: func(request copilot.UserInputRequest, invocation copilot.UserInputInvocation) (copilot.UserInputResponse, error) {
+			OnUserInputRequest: func(_ context.Context, request copilot.UserInputRequest, invocation copilot.UserInputInvocation) (copilot.UserInputResponse, error) {
 				mu.Lock()
 				userInputRequests = append(userInputRequests, request)
 				mu.Unlock()
@@ -135,7 +136,7 @@ func TestAskUser(t *testing.T) {
 		freeformAnswer := "This is my custom freeform answer that was not in the choices"
 
 		session, err := client.CreateSession(t.Context(), &copilot.SessionConfig{
-			OnUserInputRequest: func(request copilot.UserInputRequest, invocation copilot.UserInputInvocation) (copilot.UserInputResponse, error) {
+			OnUserInputRequest: func(_ context.Context, request copilot.UserInputRequest, invocation copilot.UserInputInvocation) (copilot.UserInputResponse, error) {
 				mu.Lock()
 				userInputRequests = append(userInputRequests, request)
 				mu.Unlock()
@@ -17,11 +17,10 @@ func TestMCPServers(t *testing.T) {
 		ctx.ConfigureForTest(t)
 
 		mcpServers := map[string]copilot.MCPServerConfig{
-			"test-server": {
-				"type":    "local",
-				"command": "echo",
-				"args":    []string{"hello"},
-				"tools":   []string{"*"},
+			"test-server": copilot.MCPLocalServerConfig{
+				Command: "echo",
+				Args:    []string{"hello"},
+				Tools:   []string{"*"},
 			},
 		}
 
@@ -73,11 +72,10 @@ func TestMCPServers(t *testing.T) {
 
 		// Resume with MCP servers
 		mcpServers := map[string]copilot.MCPServerConfig{
-			"test-server": {
-				"type":    "local",
-				"command": "echo",
-				"args":    []string{"hello"},
-				"tools":   []string{"*"},
+			"test-server": copilot.MCPLocalServerConfig{
+				Command: "echo",
+				Args:    []string{"hello"},
+				Tools:   []string{"*"},
 			},
 		}
 
@@ -108,17 +106,15 @@ func TestMCPServers(t *testing.T) {
 		ctx.ConfigureForTest(t)
 
 		mcpServers := map[string]copilot.MCPServerConfig{
-			"server1": {
-				"type":    "local",
-				"command": "echo",
-				"args":    []string{"server1"},
-				"tools":   []string{"*"},
+			"server1": copilot.MCPLocalServerConfig{
+				Command: "echo",
+				Args:    []string{"server1"},
+				Tools:   []string{"*"},
 			},
-			"server2": {
-				"type":    "local",
-				"command": "echo",
-				"args":    []string{"server2"},
-				"tools":   []string{"*"},
+			"server2": copilot.MCPLocalServerConfig{
+				Command: "echo",
+				Args:    []string{"server2"},
+				Tools:   []string{"*"},
 			},
 		}
 
@@ -274,11 +270,10 @@ func TestCustomAgents(t *testing.T) {
 				Description: "An agent with its own MCP servers",
 				Prompt:      "You are an agent with MCP servers.",
 				MCPServers: map[string]copilot.MCPServerConfig{
-					"agent-server": {
-						"type":    "local",
-						"command": "echo",
-						"args":    []string{"agent-mcp"},
-						"tools":   []string{"*"},
+					"agent-server": copilot.MCPLocalServerConfig{
+						Command: "echo",
+						Args:    []string{"agent-mcp"},
+						Tools:   []string{"*"},
 					},
 				},
 			},
@@ -344,11 +339,10 @@ func TestCombinedConfiguration(t *testing.T) {
 		ctx.ConfigureForTest(t)
 
 		mcpServers := map[string]copilot.MCPServerConfig{
-			"shared-server": {
-				"type":    "local",
-				"command": "echo",
-				"args":    []string{"shared"},
-				"tools":   []string{"*"},
+			"shared-server": copilot.MCPLocalServerConfig{
+				Command: "echo",
+				Args:    []string{"shared"},
+				Tools:   []string{"*"},
 			},
 		}
 
@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -22,7 +23,7 @@ func TestPermissions(t *testing.T) {
 		var permissionRequests []copilot.PermissionRequest
 		var mu sync.Mutex
 
-		onPermissionRequest := func(request copilot.PermissionRequest, invocation copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
+		onPermissionRequest := func(_ context.Context, request copilot.PermissionRequest, invocation copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
 			mu.Lock()
 			permissionRequests = append(permissionRequests, request)
 			mu.Unlock()
@@ -77,7 +78,7 @@ func TestPermissions(t *testing.T) {
 		var permissionRequests []copilot.PermissionRequest
 		var mu sync.Mutex
 
-		onPermissionRequest := func(request copilot.PermissionRequest, invocation copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
+		onPermissionRequest := func(_ context.Context, request copilot.PermissionRequest, invocation copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
 			mu.Lock()
 			permissionRequests = append(permissionRequests, request)
 			mu.Unlock()
@@ -116,7 +117,7 @@ func TestPermissions(t *testing.T) {
 	t.Run("deny permission", func(t *testing.T) {
 		ctx.ConfigureForTest(t)
 
-		onPermissionRequest := func(request copilot.PermissionRequest, invocation copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
+		onPermissionRequest := func(_ context.Context, request copilot.PermissionRequest, invocation copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
 			return copilot.PermissionRequestResult{Kind: "denied-interactively-by-user"}, nil
 		}
 
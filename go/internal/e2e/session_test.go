@@ -1,6 +1,7 @@
 package e2e
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"testing"
@@ -274,7 +275,7 @@ func TestSession(t *testing.T) {
 						},
 						"required": []string{"key"},
 					},
-					Handler: func(invocation copilot.ToolInvocation) (copilot.ToolResult, error) {
+					Handler: func(_ context.Context, invocation copilot.ToolInvocation) (copilot.ToolResult, error) {
 						args, _ := invocation.Arguments.(map[string]any)
 						key, _ := args["key"].(string)
 						if key == "ALPHA" {
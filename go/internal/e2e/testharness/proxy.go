@@ -11,6 +11,8 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+
+	"github.com/github/copilot-sdk/go/copilottest"
 )
 
 // CapiProxy manages a child process that acts as a replaying proxy to AI endpoints.
@@ -157,69 +159,22 @@ func (p *CapiProxy) GetExchanges() ([]ParsedHttpExchange, error) {
 	return exchanges, nil
 }
 
-// ParsedHttpExchange represents a captured HTTP exchange.
-type ParsedHttpExchange struct {
-	Request  ChatCompletionRequest   `json:"request"`
-	Response *ChatCompletionResponse `json:"response,omitempty"`
-}
-
-// ChatCompletionRequest represents an OpenAI chat completion request.
-type ChatCompletionRequest struct {
-	Model    string                  `json:"model"`
-	Messages []ChatCompletionMessage `json:"messages"`
-	Tools    []ChatCompletionTool    `json:"tools,omitempty"`
-}
-
-// ChatCompletionMessage represents a message in the chat completion request.
-type ChatCompletionMessage struct {
-	Role       string     `json:"role"`
-	Content    string     `json:"content,omitempty"`
-	ToolCallID string     `json:"tool_call_id,omitempty"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-}
-
-// ToolCall represents a tool call in an assistant message.
-type ToolCall struct {
-	ID       string       `json:"id"`
-	Type     string       `json:"type"`
-	Function FunctionCall `json:"function"`
-}
-
-// FunctionCall represents the function details in a tool call.
-type FunctionCall struct {
-	Name      string `json:"name"`
-	Arguments string `json:"arguments"`
-}
+// Captured exchange types, shared with the public copilottest package
+type (
+	ParsedHttpExchange         = copilottest.HTTPExchange
+	ChatCompletionRequest      = copilottest.ChatCompletionRequest
+	ChatCompletionMessage      = copilottest.ChatCompletionMessage
+	ToolCall                   = copilottest.ChatCompletionCall
+	FunctionCall               = copilottest.FunctionCall
+	ChatCompletionTool         = copilottest.ChatCompletionTool
+	ChatCompletionToolFunction = copilottest.ChatCompletionToolFunction
+	ChatCompletionResponse     = copilottest.ChatCompletionResponse
+	ChatCompletionChoice       = copilottest.ChatCompletionChoice
+)
 
 // Message is an alias for ChatCompletionMessage for test convenience.
 type Message = ChatCompletionMessage
 
-// ChatCompletionTool represents a tool in the chat completion request.
-type ChatCompletionTool struct {
-	Type     string                     `json:"type"`
-	Function ChatCompletionToolFunction `json:"function"`
-}
-
-// ChatCompletionToolFunction represents a function tool.
-type ChatCompletionToolFunction struct {
-	Name        string `json:"name"`
-	Description string `json:"description,omitempty"`
-}
-
-// ChatCompletionResponse represents an OpenAI chat completion response.
-type ChatCompletionResponse struct {
-	ID      string                 `json:"id"`
-	Model   string                 `json:"model"`
-	Choices []ChatCompletionChoice `json:"choices"`
-}
-
-// ChatCompletionChoice represents a choice in the response.
-type ChatCompletionChoice struct {
-	Index        int                   `json:"index"`
-	Message      ChatCompletionMessage `json:"message"`
-	FinishReason string                `json:"finish_reason"`
-}
-
 // URL returns the proxy URL, or empty if not started.
 func (p *CapiProxy) URL() string {
 	p.mu.Lock()
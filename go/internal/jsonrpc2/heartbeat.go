@@ -0,0 +1,50 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// heartbeat describes the liveness request configured by SetHeartbeat
+type heartbeat struct {
+	method   string
+	params   any
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// SetHeartbeat makes the client send a request for method every interval and treat
+// the peer as dead if it doesn't answer within timeout. The stream is then closed, so
+// pending requests fail promptly with ErrConnectionLost instead of waiting forever, or
+// the connection is redialed if SetRedial was called. Any response, including an
+// error, counts as an answer. It must be called before Start.
+func (c *Client) SetHeartbeat(method string, params any, interval, timeout time.Duration) {
+	c.heartbeat = &heartbeat{method: method, params: params, interval: interval, timeout: timeout}
+}
+
+// heartbeatLoop pings the peer until the client closes
+func (c *Client) heartbeatLoop() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.heartbeat.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.stopChan:
+			return
+		case <-c.closedChan:
+			return
+		}
+
+		conn := c.currentConn()
+		ctx, cancel := context.WithTimeout(context.Background(), c.heartbeat.timeout)
+		_, err := c.Request(ctx, c.heartbeat.method, c.heartbeat.params)
+		cancel()
+		if errors.Is(err, ErrTimeout) && c.running.Load() {
+			// The peer is unresponsive; closing the stream fails the read loop
+			conn.stream.Close()
+		}
+	}
+}
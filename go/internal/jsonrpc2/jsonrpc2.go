@@ -1,13 +1,17 @@
 package jsonrpc2
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"reflect"
 	"sync"
+	"sync/atomic"
 )
 
 // Error represents a JSON-RPC error response
@@ -21,6 +25,10 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("JSON-RPC Error %d: %s", e.Code, e.Message)
 }
 
+// ErrTimeout is returned by Client.Request when the request's context deadline
+// passes before a response arrives
+var ErrTimeout = errors.New("request timed out")
+
 // Request represents a JSON-RPC 2.0 request
 type Request struct {
 	JSONRPC string          `json:"jsonrpc"`
@@ -29,8 +37,31 @@ type Request struct {
 	Params  json.RawMessage `json:"params"`
 }
 
+// IsCall reports whether the request expects a response. A null ID, which some
+// peers send on notifications, doesn't.
 func (r *Request) IsCall() bool {
-	return len(r.ID) > 0
+	_, ok := idKey(r.ID)
+	return ok
+}
+
+// idKey returns the key correlating a request ID with its response or cancellation.
+// String and numeric IDs are accepted, so a peer echoing the ID "7" as 7 still
+// matches; ok is false for a missing or null ID.
+func idKey(id json.RawMessage) (key string, ok bool) {
+	var v any
+	d := json.NewDecoder(bytes.NewReader(id))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return "", false
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	default:
+		return "", false
+	}
 }
 
 // Response represents a JSON-RPC 2.0 response
@@ -44,57 +75,199 @@ type Response struct {
 // NotificationHandler handles incoming notifications
 type NotificationHandler func(method string, params json.RawMessage)
 
-// RequestHandler handles incoming server requests and returns a result or error
-type RequestHandler func(params json.RawMessage) (json.RawMessage, *Error)
+// RequestHandler handles incoming server requests and returns a result or error.
+// ctx is cancelled when the server cancels the request or the connection closes.
+type RequestHandler func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error)
+
+// cancelRequestMethod is the notification sent to cancel an in-flight request
+const cancelRequestMethod = "$/cancelRequest"
+
+// cancelParams are the params of a $/cancelRequest notification
+type cancelParams struct {
+	ID json.RawMessage `json:"id"`
+}
+
+// TraceDirection tells whether a traced message was sent or received
+type TraceDirection string
+
+const (
+	// TraceSend marks a message sent to the peer
+	TraceSend TraceDirection = "send"
+	// TraceReceive marks a message received from the peer
+	TraceReceive TraceDirection = "receive"
+)
+
+// TraceFunc receives every raw message sent or received, without framing. It must
+// not retain or modify payload.
+type TraceFunc func(direction TraceDirection, payload []byte)
+
+// Interceptor transforms or observes a raw message between the Client and its Stream,
+// e.g. to compress or encrypt messages or to collect metrics. It returns the message
+// to use in data's place, or an error to drop the message. It must not retain data.
+type Interceptor func(direction TraceDirection, data []byte) ([]byte, error)
+
+// MessageKind classifies a JSON-RPC message
+type MessageKind string
+
+const (
+	// MessageRequest is a call expecting a response
+	MessageRequest MessageKind = "request"
+	// MessageResponse answers a request
+	MessageResponse MessageKind = "response"
+	// MessageNotification is a call without a response
+	MessageNotification MessageKind = "notification"
+)
+
+// KindOf reports whether data is a request, response, or notification, so an
+// Interceptor can treat them differently. It returns "" if data isn't a JSON-RPC message.
+func KindOf(data []byte) MessageKind {
+	var message struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return ""
+	}
+	_, hasID := idKey(message.ID)
+	switch {
+	case message.Method != "" && hasID:
+		return MessageRequest
+	case message.Method != "":
+		return MessageNotification
+	case len(message.ID) > 0:
+		return MessageResponse
+	}
+	return ""
+}
 
-// Client is a minimal JSON-RPC 2.0 client for stdio transport
+// Client is a minimal JSON-RPC 2.0 client over a framed message stream
 type Client struct {
-	stdin           io.WriteCloser
-	stdout          io.ReadCloser
+	conn            *connection // guarded by mu; replaced when the stream is redialed
+	redial          func(ctx context.Context) (Stream, error)
+	retryMethods    map[string]bool
+	heartbeat       *heartbeat
+	trace           TraceFunc
+	interceptors    []Interceptor
+	onDropped       func(err error)
+	logger          *slog.Logger
+	writeMu         sync.Mutex // serializes stream writes, separately from mu so bookkeeping never waits on I/O
 	mu              sync.Mutex
 	pendingRequests map[string]chan *Response
 	requestHandlers map[string]RequestHandler
-	running         bool
+	activeRequests  map[string]context.CancelFunc // cancels in-flight server requests, keyed by idKey
+	running         atomic.Bool
 	stopChan        chan struct{}
+	closedChan      chan struct{} // closed when the read loop exits for good
 	wg              sync.WaitGroup
 }
 
-// NewClient creates a new JSON-RPC client
+// NewClient creates a new JSON-RPC client that exchanges Content-Length framed
+// messages over stdin and stdout
 func NewClient(stdin io.WriteCloser, stdout io.ReadCloser) *Client {
+	return NewStreamClient(NewHeaderStream(stdout, stdin, 0))
+}
+
+// NewStreamClient creates a new JSON-RPC client that exchanges messages over stream
+func NewStreamClient(stream Stream) *Client {
 	return &Client{
-		stdin:           stdin,
-		stdout:          stdout,
+		conn:            newConnection(stream),
 		pendingRequests: make(map[string]chan *Response),
 		requestHandlers: make(map[string]RequestHandler),
+		activeRequests:  make(map[string]context.CancelFunc),
 		stopChan:        make(chan struct{}),
+		closedChan:      make(chan struct{}),
+	}
+}
+
+// SetTraceFunc registers fn to observe every message on the wire. It must be called
+// before Start.
+func (c *Client) SetTraceFunc(fn TraceFunc) {
+	c.trace = fn
+}
+
+// AddInterceptor adds an interceptor for messages on the wire. Outgoing messages pass
+// through interceptors in the order they were added and incoming ones in reverse, so
+// pairs such as compress and decompress nest correctly. The trace function sees
+// messages as the Client handles them, before outgoing and after incoming interceptors.
+// An incoming message dropped by an interceptor is reported to the dropped message
+// handler. It must be called before Start.
+func (c *Client) AddInterceptor(interceptor Interceptor) {
+	c.interceptors = append(c.interceptors, interceptor)
+}
+
+// intercept passes data through the interceptors for direction
+func (c *Client) intercept(direction TraceDirection, data []byte) ([]byte, error) {
+	for i := range c.interceptors {
+		if direction == TraceReceive {
+			i = len(c.interceptors) - 1 - i
+		}
+		var err error
+		if data, err = c.interceptors[i](direction, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// SetDroppedMessageHandler registers fn to be told about incoming messages that were
+// discarded, such as ones over the stream's size limit. Without one, drops are logged
+// as warnings. It must be called before Start.
+func (c *Client) SetDroppedMessageHandler(fn func(err error)) {
+	c.onDropped = fn
+}
+
+// SetLogger sets where the client logs failures it can't return to a caller, such as
+// read errors and undeliverable responses (default: slog.Default()). It must be called
+// before Start.
+func (c *Client) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+// log returns the client's logger
+func (c *Client) log() *slog.Logger {
+	if c.logger == nil {
+		return slog.Default()
+	}
+	return c.logger
+}
+
+// dropped reports a discarded incoming message
+func (c *Client) dropped(err error) {
+	if c.onDropped != nil {
+		c.onDropped(err)
+		return
 	}
+	c.log().Warn("dropped JSON-RPC message", "error", err)
 }
 
 // Start begins listening for messages in a background goroutine
 func (c *Client) Start() {
-	c.running = true
+	c.running.Store(true)
 	c.wg.Add(1)
 	go c.readLoop()
+	if c.heartbeat != nil {
+		c.wg.Add(1)
+		go c.heartbeatLoop()
+	}
 }
 
 // Stop stops the client and cleans up
 func (c *Client) Stop() {
-	if !c.running {
+	if !c.running.CompareAndSwap(true, false) {
 		return
 	}
-	c.running = false
 	close(c.stopChan)
 
-	// Close stdout to unblock the readLoop
-	if c.stdout != nil {
-		c.stdout.Close()
+	// Close the stream to unblock the readLoop
+	if stream := c.currentConn().stream; stream != nil {
+		stream.Close()
 	}
 
 	c.wg.Wait()
 }
 
 func NotificationHandlerFor[In any](handler func(params In)) RequestHandler {
-	return func(params json.RawMessage) (json.RawMessage, *Error) {
+	return func(_ context.Context, params json.RawMessage) (json.RawMessage, *Error) {
 		var in In
 		// If In is a pointer type, allocate the underlying value and unmarshal into it directly
 		var target any = &in
@@ -114,8 +287,8 @@ func NotificationHandlerFor[In any](handler func(params In)) RequestHandler {
 }
 
 // RequestHandlerFor creates a RequestHandler from a typed function
-func RequestHandlerFor[In, Out any](handler func(params In) (Out, *Error)) RequestHandler {
-	return func(params json.RawMessage) (json.RawMessage, *Error) {
+func RequestHandlerFor[In, Out any](handler func(ctx context.Context, params In) (Out, *Error)) RequestHandler {
+	return func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
 		var in In
 		// If In is a pointer type, allocate the underlying value and unmarshal into it directly
 		var target any = &in
@@ -129,7 +302,7 @@ func RequestHandlerFor[In, Out any](handler func(params In) (Out, *Error)) Reque
 				Message: fmt.Sprintf("Invalid params: %v", err),
 			}
 		}
-		out, errj := handler(in)
+		out, errj := handler(ctx, in)
 		if errj != nil {
 			return nil, errj
 		}
@@ -155,8 +328,15 @@ func (c *Client) SetRequestHandler(method string, handler RequestHandler) {
 	c.requestHandlers[method] = handler
 }
 
-// Request sends a JSON-RPC request and waits for the response
-func (c *Client) Request(method string, params any) (json.RawMessage, error) {
+// Request sends a JSON-RPC request and waits for the response, the client
+// stopping, or ctx being done. If ctx is done first, a $/cancelRequest notification
+// tells the server to stop working on the request. If ctx's deadline passed, the
+// error wraps both ErrTimeout and context.DeadlineExceeded.
+func (c *Client) Request(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, requestContextError(method, err)
+	}
+
 	requestID := generateUUID()
 
 	// Create response channel
@@ -185,22 +365,73 @@ func (c *Client) Request(method string, params any) (json.RawMessage, error) {
 		Params:  json.RawMessage(paramsData),
 	}
 
-	if err := c.sendMessage(request); err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
+	for {
+		conn := c.currentConn()
+		if err := c.send(conn, request); err != nil {
+			select {
+			case <-conn.lost: // the connection dropped under the write; handled below
+			default:
+				return nil, fmt.Errorf("failed to send request: %w", err)
+			}
+		}
 
-	// Wait for response
-	select {
-	case response := <-responseChan:
-		if response.Error != nil {
-			return nil, response.Error
+		// Wait for response
+		select {
+		case response := <-responseChan:
+			return responseResult(response)
+		case <-ctx.Done():
+			// Don't block the caller on the write; the server may be unresponsive
+			go c.Notify(cancelRequestMethod, cancelParams{ID: request.ID})
+			return nil, requestContextError(method, ctx.Err())
+		case <-c.stopChan:
+			return nil, fmt.Errorf("client stopped")
+		case <-conn.lost:
+			// Prefer a response that arrived just before the connection dropped
+			select {
+			case response := <-responseChan:
+				return responseResult(response)
+			default:
+			}
+			if !c.retryMethods[method] {
+				return nil, fmt.Errorf("%w: %s", ErrConnectionLost, method)
+			}
+		}
+
+		// Send the request again once the connection has been redialed
+		select {
+		case <-conn.replaced:
+		case <-c.closedChan:
+			return nil, fmt.Errorf("%w: %s", ErrConnectionLost, method)
+		case <-ctx.Done():
+			return nil, requestContextError(method, ctx.Err())
+		case <-c.stopChan:
+			return nil, fmt.Errorf("client stopped")
 		}
-		return response.Result, nil
-	case <-c.stopChan:
-		return nil, fmt.Errorf("client stopped")
 	}
 }
 
+// responseResult returns a response's result, or its error
+func responseResult(response *Response) (json.RawMessage, error) {
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	return response.Result, nil
+}
+
+// requestContextError reports why a request was abandoned before its response arrived
+func requestContextError(method string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %s: %w", ErrTimeout, method, err)
+	}
+	return err
+}
+
+// Done returns a channel that is closed when the connection is closed for good,
+// either because the peer went away and couldn't be redialed or because Stop was called.
+func (c *Client) Done() <-chan struct{} {
+	return c.closedChan
+}
+
 // Notify sends a JSON-RPC notification (no response expected)
 func (c *Client) Notify(method string, params any) error {
 	paramsData, err := json.Marshal(params)
@@ -216,69 +447,72 @@ func (c *Client) Notify(method string, params any) error {
 	return c.sendMessage(notification)
 }
 
-// sendMessage writes a message to stdin
+// sendMessage writes a message to the current stream
 func (c *Client) sendMessage(message any) error {
+	return c.send(c.currentConn(), message)
+}
+
+// send writes a message to conn's stream
+func (c *Client) send(conn *connection, message any) error {
 	data, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Write Content-Length header + message
-	header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(data))
-	if _, err := c.stdin.Write([]byte(header)); err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
+	// Trace before taking the write lock so a slow observer doesn't stall other senders
+	if c.trace != nil {
+		c.trace(TraceSend, data)
 	}
-	if _, err := c.stdin.Write(data); err != nil {
-		return fmt.Errorf("failed to write message: %w", err)
+	if data, err = c.intercept(TraceSend, data); err != nil {
+		return fmt.Errorf("failed to intercept message: %w", err)
 	}
 
-	return nil
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return conn.stream.Write(data)
 }
 
-// readLoop reads messages from stdout in a background goroutine
+// readLoop reads messages in a background goroutine, redialing the connection when
+// it drops if a redial function is set
 func (c *Client) readLoop() {
 	defer c.wg.Done()
-
-	reader := bufio.NewReader(c.stdout)
-
-	for c.running {
-		// Read Content-Length header
-		var contentLength int
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				// Only log unexpected errors (not EOF or closed pipe during shutdown)
-				if err != io.EOF && c.running {
-					fmt.Printf("Error reading header: %v\n", err)
-				}
-				return
-			}
-
-			// Check for blank line (end of headers)
-			if line == "\r\n" || line == "\n" {
-				break
-			}
-
-			// Parse Content-Length
-			var length int
-			if _, err := fmt.Sscanf(line, "Content-Length: %d", &length); err == nil {
-				contentLength = length
-			}
+	defer close(c.closedChan)
+
+	for {
+		conn := c.currentConn()
+		c.readMessages(conn.stream)
+		// Server requests from the old connection can no longer be answered
+		c.cancelActiveRequests()
+		close(conn.lost)
+		if !c.running.Load() || c.redial == nil || !c.reconnect(conn) {
+			return
 		}
+	}
+}
 
-		if contentLength == 0 {
+// readMessages dispatches messages read from stream until reading fails
+func (c *Client) readMessages(stream Stream) {
+	for c.running.Load() {
+		body, err := stream.Read()
+		if errors.Is(err, ErrMessageTooLarge) {
+			// The oversized message was skipped; keep serving the connection
+			c.dropped(err)
 			continue
 		}
-
-		// Read message body
-		body := make([]byte, contentLength)
-		if _, err := io.ReadFull(reader, body); err != nil {
-			fmt.Printf("Error reading body: %v\n", err)
+		if err != nil {
+			// Only log unexpected errors (not EOF or closed pipe during shutdown)
+			if err != io.EOF && c.running.Load() {
+				c.log().Error("failed to read JSON-RPC message", "error", err)
+			}
 			return
 		}
+		if body, err = c.intercept(TraceReceive, body); err != nil {
+			c.dropped(fmt.Errorf("interceptor dropped message: %w", err))
+			continue
+		}
+		if c.trace != nil {
+			c.trace(TraceReceive, body)
+		}
 
 		// Try to parse as request first (has both ID and Method)
 		var request Request
@@ -298,9 +532,9 @@ func (c *Client) readLoop() {
 
 // handleResponse dispatches a response to the waiting request
 func (c *Client) handleResponse(response *Response) {
-	var id string
-	if err := json.Unmarshal(response.ID, &id); err != nil {
-		return // ignore responses with non-string IDs
+	id, ok := idKey(response.ID)
+	if !ok {
+		return // a null ID answers a request the peer couldn't parse, so no one is waiting
 	}
 	c.mu.Lock()
 	responseChan, ok := c.pendingRequests[id]
@@ -315,6 +549,11 @@ func (c *Client) handleResponse(response *Response) {
 }
 
 func (c *Client) handleRequest(request *Request) {
+	if request.Method == cancelRequestMethod {
+		c.handleCancelRequest(request.Params)
+		return
+	}
+
 	c.mu.Lock()
 	handler := c.requestHandlers[request.Method]
 	c.mu.Unlock()
@@ -328,18 +567,30 @@ func (c *Client) handleRequest(request *Request) {
 
 	// Notifications run synchronously, calls run in a goroutine to avoid blocking
 	if !request.IsCall() {
-		handler(request.Params)
+		handler(context.Background(), request.Params)
 		return
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	id, _ := idKey(request.ID)
+	c.mu.Lock()
+	c.activeRequests[id] = cancel
+	c.mu.Unlock()
+
 	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.activeRequests, id)
+			c.mu.Unlock()
+			cancel()
+		}()
 		defer func() {
 			if r := recover(); r != nil {
 				c.sendErrorResponse(request.ID, -32603, fmt.Sprintf("request handler panic: %v", r), nil)
 			}
 		}()
 
-		result, err := handler(request.Params)
+		result, err := handler(ctx, request.Params)
 		if err != nil {
 			c.sendErrorResponse(request.ID, err.Code, err.Message, err.Data)
 			return
@@ -348,6 +599,34 @@ func (c *Client) handleRequest(request *Request) {
 	}()
 }
 
+// handleCancelRequest cancels the context of the in-flight server request named by
+// a $/cancelRequest notification. The handler still sends its response.
+func (c *Client) handleCancelRequest(params json.RawMessage) {
+	var p cancelParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return
+	}
+	id, ok := idKey(p.ID)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	cancel, ok := c.activeRequests[id]
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// cancelActiveRequests cancels the contexts of all in-flight server requests
+func (c *Client) cancelActiveRequests() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cancel := range c.activeRequests {
+		cancel()
+	}
+}
+
 func (c *Client) sendResponse(id json.RawMessage, result json.RawMessage) {
 	response := Response{
 		JSONRPC: "2.0",
@@ -355,7 +634,7 @@ func (c *Client) sendResponse(id json.RawMessage, result json.RawMessage) {
 		Result:  result,
 	}
 	if err := c.sendMessage(response); err != nil {
-		fmt.Printf("Failed to send JSON-RPC response: %v\n", err)
+		c.log().Warn("failed to send JSON-RPC response", "error", err)
 	}
 }
 
@@ -370,7 +649,7 @@ func (c *Client) sendErrorResponse(id json.RawMessage, code int, message string,
 		},
 	}
 	if err := c.sendMessage(response); err != nil {
-		fmt.Printf("Failed to send JSON-RPC error response: %v\n", err)
+		c.log().Warn("failed to send JSON-RPC error response", "code", code, "error", err)
 	}
 }
 
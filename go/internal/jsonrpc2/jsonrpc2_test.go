@@ -0,0 +1,494 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a started client whose peer reads and discards every message,
+// so requests never receive a response.
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	toServer, fromClient := io.Pipe()
+	fromServer, toClient := io.Pipe()
+	go io.Copy(io.Discard, toServer)
+
+	client := NewClient(fromClient, fromServer)
+	client.Start()
+	t.Cleanup(func() {
+		client.Stop()
+		toClient.Close()
+		toServer.Close()
+	})
+	return client
+}
+
+// testPeer is the server side of a connection to a client under test
+type testPeer struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// newTestPeer returns a started client connected to a peer driven by the test
+func newTestPeer(t *testing.T) (*Client, *testPeer) {
+	t.Helper()
+	toServer, fromClient := io.Pipe()
+	fromServer, toClient := io.Pipe()
+
+	client := NewClient(fromClient, fromServer)
+	client.Start()
+	t.Cleanup(func() {
+		toClient.Close()
+		toServer.Close()
+		client.Stop()
+	})
+	return client, &testPeer{r: bufio.NewReader(toServer), w: toClient}
+}
+
+func (p *testPeer) read(t *testing.T) Request {
+	t.Helper()
+	var length int
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read header: %v", err)
+		}
+		if line == "\r\n" {
+			break
+		}
+		fmt.Sscanf(line, "Content-Length: %d", &length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	var request Request
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatalf("Failed to parse message: %v", err)
+	}
+	return request
+}
+
+func (p *testPeer) write(t *testing.T, message any) {
+	t.Helper()
+	data, _ := json.Marshal(message)
+	if _, err := fmt.Fprintf(p.w, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+}
+
+func TestClient_RequestContext(t *testing.T) {
+	t.Run("should fail with ErrTimeout when the deadline passes", func(t *testing.T) {
+		client := newTestClient(t)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+		defer cancel()
+		_, err := client.Request(ctx, "slow.method", nil)
+		if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected ErrTimeout wrapping context.DeadlineExceeded, got %v", err)
+		}
+
+		client.mu.Lock()
+		pending := len(client.pendingRequests)
+		client.mu.Unlock()
+		if pending != 0 {
+			t.Errorf("Expected pending requests to be cleaned up, got %d", pending)
+		}
+	})
+
+	t.Run("should return context.Canceled when cancelled", func(t *testing.T) {
+		client := newTestClient(t)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		_, err := client.Request(ctx, "slow.method", nil)
+		if !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("should not send a request when the context is already done", func(t *testing.T) {
+		client := NewClient(nil, nil)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		if _, err := client.Request(ctx, "method", nil); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+func TestClient_CancelRequest(t *testing.T) {
+	t.Run("should send $/cancelRequest when the request context is cancelled", func(t *testing.T) {
+		client, peer := newTestPeer(t)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		go client.Request(ctx, "slow.method", nil)
+
+		request := peer.read(t)
+		cancel()
+		notification := peer.read(t)
+
+		if notification.Method != "$/cancelRequest" {
+			t.Fatalf("Expected a $/cancelRequest notification, got %+v", notification)
+		}
+		var params cancelParams
+		json.Unmarshal(notification.Params, &params)
+		if string(params.ID) != string(request.ID) {
+			t.Errorf("Expected cancellation of %s, got %s", request.ID, params.ID)
+		}
+	})
+
+	t.Run("should cancel the handler context on inbound $/cancelRequest", func(t *testing.T) {
+		client, peer := newTestPeer(t)
+
+		started := make(chan struct{})
+		client.SetRequestHandler("tool.call", func(ctx context.Context, params json.RawMessage) (json.RawMessage, *Error) {
+			close(started)
+			<-ctx.Done()
+			return nil, &Error{Code: -32800, Message: "cancelled"}
+		})
+
+		peer.write(t, Request{JSONRPC: "2.0", ID: json.RawMessage(`7`), Method: "tool.call", Params: json.RawMessage(`{}`)})
+		<-started
+		peer.write(t, Request{JSONRPC: "2.0", Method: "$/cancelRequest", Params: json.RawMessage(`{"id":7}`)})
+
+		if response := peer.read(t); string(response.ID) != "7" || response.Method != "" {
+			t.Errorf("Expected the handler to respond after cancellation, got %+v", response)
+		}
+	})
+}
+
+func TestClient_RequestIDs(t *testing.T) {
+	t.Run("should correlate responses with numeric IDs", func(t *testing.T) {
+		client := NewClient(nil, nil)
+		responseChan := make(chan *Response, 1)
+		client.pendingRequests["42"] = responseChan
+
+		client.handleResponse(&Response{ID: json.RawMessage(`42`), Result: json.RawMessage(`true`)})
+		select {
+		case response := <-responseChan:
+			if string(response.Result) != "true" {
+				t.Errorf("Unexpected response: %+v", response)
+			}
+		default:
+			t.Error("Expected the numeric ID to match the pending request")
+		}
+	})
+
+	t.Run("should ignore responses with a null ID", func(t *testing.T) {
+		client, peer := newTestPeer(t)
+
+		result := make(chan json.RawMessage, 1)
+		go func() {
+			r, _ := client.Request(t.Context(), "method", nil)
+			result <- r
+		}()
+		request := peer.read(t)
+		peer.write(t, Response{JSONRPC: "2.0", ID: json.RawMessage(`null`), Error: &Error{Code: -32700, Message: "Parse error"}})
+		peer.write(t, Response{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage(`"ok"`)})
+
+		if r := <-result; string(r) != `"ok"` {
+			t.Errorf("Expected the real response, got %s", r)
+		}
+	})
+
+	t.Run("should treat a null ID as a notification", func(t *testing.T) {
+		request := Request{ID: json.RawMessage(`null`), Method: "session.event"}
+		if request.IsCall() {
+			t.Error("Expected a request with a null ID not to be a call")
+		}
+		if request.ID = json.RawMessage(`7`); !request.IsCall() {
+			t.Error("Expected a request with a numeric ID to be a call")
+		}
+	})
+}
+
+// chanStream is a Stream exchanging whole messages over channels
+type chanStream struct {
+	incoming  chan []byte
+	outgoing  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newChanStream() *chanStream {
+	return &chanStream{incoming: make(chan []byte), outgoing: make(chan []byte, 1), closed: make(chan struct{})}
+}
+
+func (s *chanStream) Read() ([]byte, error) {
+	select {
+	case data, ok := <-s.incoming:
+		if !ok {
+			return nil, io.EOF
+		}
+		return data, nil
+	case <-s.closed:
+		return nil, io.ErrClosedPipe
+	}
+}
+
+func (s *chanStream) Write(data []byte) error {
+	s.outgoing <- append([]byte(nil), data...)
+	return nil
+}
+
+func (s *chanStream) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
+
+func TestClient_Interceptors(t *testing.T) {
+	// prefixer adds its tag to outgoing messages and strips it from incoming ones
+	prefixer := func(tag string) Interceptor {
+		return func(direction TraceDirection, data []byte) ([]byte, error) {
+			if direction == TraceSend {
+				return append([]byte(tag), data...), nil
+			}
+			rest, ok := bytes.CutPrefix(data, []byte(tag))
+			if !ok {
+				return nil, fmt.Errorf("missing %s prefix", tag)
+			}
+			return rest, nil
+		}
+	}
+	newClient := func(t *testing.T) (*Client, *chanStream) {
+		stream := newChanStream()
+		client := NewStreamClient(stream)
+		client.AddInterceptor(prefixer("1"))
+		client.AddInterceptor(prefixer("2"))
+		t.Cleanup(func() {
+			close(stream.incoming)
+			client.Stop()
+		})
+		return client, stream
+	}
+
+	t.Run("should apply interceptors in order on send and in reverse on receive", func(t *testing.T) {
+		client, stream := newClient(t)
+		received := make(chan string, 1)
+		client.SetRequestHandler("note", NotificationHandlerFor(func(params map[string]string) {
+			received <- params["text"]
+		}))
+		client.Start()
+
+		client.Notify("note", map[string]string{"text": "out"})
+		if sent := string(<-stream.outgoing); !strings.HasPrefix(sent, `21{"jsonrpc"`) {
+			t.Errorf("Expected the second interceptor to wrap the first, got %s", sent)
+		}
+
+		stream.incoming <- []byte(`21{"jsonrpc":"2.0","method":"note","params":{"text":"in"}}`)
+		if text := <-received; text != "in" {
+			t.Errorf("Expected the decoded notification, got %q", text)
+		}
+	})
+
+	t.Run("should drop incoming messages an interceptor rejects", func(t *testing.T) {
+		client, stream := newClient(t)
+		dropped := make(chan error, 1)
+		client.SetDroppedMessageHandler(func(err error) { dropped <- err })
+		client.Start()
+
+		stream.incoming <- []byte(`{"jsonrpc":"2.0","method":"note","params":{}}`)
+		select {
+		case err := <-dropped:
+			if !strings.Contains(err.Error(), "missing 2 prefix") {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected the message to be reported as dropped")
+		}
+	})
+
+	t.Run("should log dropped messages without a handler", func(t *testing.T) {
+		client, stream := newClient(t)
+		logged := make(chan string, 1)
+		client.SetLogger(slog.New(slog.NewTextHandler(writerFunc(func(p []byte) (int, error) {
+			logged <- string(p)
+			return len(p), nil
+		}), nil)))
+		client.Start()
+
+		stream.incoming <- []byte(`{"jsonrpc":"2.0","method":"note","params":{}}`)
+		select {
+		case line := <-logged:
+			if !strings.Contains(line, "level=WARN") || !strings.Contains(line, "missing 2 prefix") {
+				t.Errorf("Unexpected log line: %s", line)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected the dropped message to be logged")
+		}
+	})
+}
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestKindOf(t *testing.T) {
+	for data, want := range map[string]MessageKind{
+		`{"jsonrpc":"2.0","id":"1","method":"ping"}`:   MessageRequest,
+		`{"jsonrpc":"2.0","id":null,"method":"event"}`: MessageNotification,
+		`{"jsonrpc":"2.0","method":"event"}`:           MessageNotification,
+		`{"jsonrpc":"2.0","id":1,"result":{}}`:         MessageResponse,
+		`not json`:                                     "",
+	} {
+		if got := KindOf([]byte(data)); got != want {
+			t.Errorf("KindOf(%s) = %q, want %q", data, got, want)
+		}
+	}
+}
+
+func TestClient_Redial(t *testing.T) {
+	// respond answers the request written to stream with result
+	respond := func(t *testing.T, stream *chanStream, result string) {
+		t.Helper()
+		var request Request
+		json.Unmarshal(<-stream.outgoing, &request)
+		data, _ := json.Marshal(Response{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage(result)})
+		stream.incoming <- data
+	}
+
+	t.Run("should retry configured methods on the redialed connection", func(t *testing.T) {
+		first, second := newChanStream(), newChanStream()
+		client := NewStreamClient(first)
+		client.SetRedial(func(ctx context.Context) (Stream, error) { return second, nil }, "status.get")
+		client.Start()
+		t.Cleanup(func() {
+			close(second.incoming)
+			client.Stop()
+		})
+
+		result := make(chan json.RawMessage, 1)
+		go func() {
+			r, _ := client.Request(t.Context(), "status.get", nil)
+			result <- r
+		}()
+		<-first.outgoing
+		close(first.incoming)
+
+		respond(t, second, `"ok"`)
+		if r := <-result; string(r) != `"ok"` {
+			t.Errorf("Expected the retried request to succeed, got %s", r)
+		}
+	})
+
+	t.Run("should fail other pending requests with ErrConnectionLost", func(t *testing.T) {
+		first, second := newChanStream(), newChanStream()
+		client := NewStreamClient(first)
+		client.SetRedial(func(ctx context.Context) (Stream, error) { return second, nil }, "status.get")
+		client.Start()
+		t.Cleanup(func() {
+			close(second.incoming)
+			client.Stop()
+		})
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := client.Request(t.Context(), "session.send", nil)
+			errs <- err
+		}()
+		<-first.outgoing
+		close(first.incoming)
+
+		if err := <-errs; !errors.Is(err, ErrConnectionLost) {
+			t.Errorf("Expected ErrConnectionLost, got %v", err)
+		}
+		// Later requests use the new connection
+		go respond(t, second, `{}`)
+		if _, err := client.Request(t.Context(), "session.send", nil); err != nil {
+			t.Errorf("Expected a request on the new connection to succeed, got %v", err)
+		}
+	})
+
+	t.Run("should fail pending requests with ErrConnectionLost without a redial function", func(t *testing.T) {
+		stream := newChanStream()
+		client := NewStreamClient(stream)
+		client.Start()
+		t.Cleanup(client.Stop)
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := client.Request(t.Context(), "status.get", nil)
+			errs <- err
+		}()
+		<-stream.outgoing
+		close(stream.incoming)
+
+		if err := <-errs; !errors.Is(err, ErrConnectionLost) {
+			t.Errorf("Expected ErrConnectionLost, got %v", err)
+		}
+		<-client.Done()
+	})
+}
+
+func TestClient_Heartbeat(t *testing.T) {
+	t.Run("should close the connection when the peer stops answering", func(t *testing.T) {
+		stream := newChanStream()
+		client := NewStreamClient(stream)
+		client.SetHeartbeat("ping", nil, 10*time.Millisecond, 20*time.Millisecond)
+		client.Start()
+		t.Cleanup(client.Stop)
+
+		// Drain outgoing pings and a request that never gets an answer
+		go func() {
+			for {
+				select {
+				case <-stream.outgoing:
+				case <-stream.closed:
+					return
+				}
+			}
+		}()
+		_, err := client.Request(t.Context(), "session.send", nil)
+		if !errors.Is(err, ErrConnectionLost) {
+			t.Errorf("Expected ErrConnectionLost once the heartbeat failed, got %v", err)
+		}
+	})
+
+	t.Run("should keep a responsive connection open", func(t *testing.T) {
+		stream := newChanStream()
+		client := NewStreamClient(stream)
+		client.SetHeartbeat("ping", nil, 5*time.Millisecond, time.Second)
+		client.Start()
+		t.Cleanup(client.Stop)
+
+		go func() {
+			for {
+				select {
+				case data := <-stream.outgoing:
+					var request Request
+					json.Unmarshal(data, &request)
+					response, _ := json.Marshal(Response{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage(`{}`)})
+					select {
+					case stream.incoming <- response:
+					case <-stream.closed:
+						return
+					}
+				case <-stream.closed:
+					return
+				}
+			}
+		}()
+
+		select {
+		case <-client.Done():
+			t.Fatal("Expected the connection to stay open")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
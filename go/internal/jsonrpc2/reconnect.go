@@ -0,0 +1,77 @@
+package jsonrpc2
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrConnectionLost is returned by Client.Request when the connection drops before
+// the response arrives and the request can't be retried on a redialed connection
+var ErrConnectionLost = errors.New("connection lost")
+
+// connection is one stream the client has used. lost is closed when reading from it
+// fails, and replaced when a redialed connection takes its place.
+type connection struct {
+	stream   Stream
+	lost     chan struct{}
+	replaced chan struct{}
+}
+
+func newConnection(stream Stream) *connection {
+	return &connection{stream: stream, lost: make(chan struct{}), replaced: make(chan struct{})}
+}
+
+// currentConn returns the connection requests are currently sent on
+func (c *Client) currentConn() *connection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+// SetRedial makes the client call dial for a new stream when the peer goes away,
+// instead of closing. Requests for retryMethods, which must be idempotent, that are
+// pending when the connection drops are sent again on the new stream; other pending
+// requests fail with ErrConnectionLost. dial should retry as it sees fit and is
+// cancelled by Stop; if it fails, the client closes. State the peer kept for the old
+// connection is not restored. It must be called before Start.
+func (c *Client) SetRedial(dial func(ctx context.Context) (Stream, error), retryMethods ...string) {
+	c.redial = dial
+	c.retryMethods = make(map[string]bool, len(retryMethods))
+	for _, method := range retryMethods {
+		c.retryMethods[method] = true
+	}
+}
+
+// reconnect dials a stream to replace the lost connection old, reporting whether the
+// client can keep running
+func (c *Client) reconnect(old *connection) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stream, err := c.redial(ctx)
+	if err != nil {
+		if c.running.Load() {
+			c.log().Error("failed to reconnect", "error", err)
+		}
+		return false
+	}
+
+	c.mu.Lock()
+	if !c.running.Load() {
+		// Stop ran while dialing and won't close this stream
+		c.mu.Unlock()
+		stream.Close()
+		return false
+	}
+	c.conn = newConnection(stream)
+	c.mu.Unlock()
+	close(old.replaced)
+	return true
+}
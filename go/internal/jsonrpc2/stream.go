@@ -0,0 +1,210 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DefaultMaxMessageSize is the largest message a Stream reads when no limit is given
+const DefaultMaxMessageSize = 64 << 20
+
+// maxRetainedFrame is the largest write buffer a stream keeps for reuse
+const maxRetainedFrame = 1 << 20
+
+var (
+	// ErrMessageTooLarge is returned by Stream.Read for a message over the size limit.
+	// The message is discarded and the stream stays usable.
+	ErrMessageTooLarge = errors.New("message too large")
+	// ErrCorruptFrame is returned by Stream.Read when framing is malformed and the
+	// stream can't be resynchronized
+	ErrCorruptFrame = errors.New("corrupt message frame")
+)
+
+// Stream reads and writes whole JSON-RPC messages, hiding how they are framed on the wire
+type Stream interface {
+	// Read returns the next message, or io.EOF once the peer has closed the stream
+	Read() ([]byte, error)
+	// Write sends one message. Calls are serialized by the Client.
+	Write(data []byte) error
+	// Close closes the stream, unblocking a pending Read
+	Close() error
+}
+
+// maxMessageSize resolves a configured limit, where zero or less means the default.
+// Streams resolve it on every Read so a zero-valued stream still gets the default.
+func maxMessageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultMaxMessageSize
+	}
+	return limit
+}
+
+// headerStream frames messages with LSP-style Content-Length headers
+type headerStream struct {
+	r       io.ReadCloser
+	w       io.Writer
+	reader  *bufio.Reader
+	maxSize int
+	frame   []byte // reused for writes, which the Client serializes
+}
+
+// NewHeaderStream returns a Stream that frames each message with a
+// "Content-Length: N\r\n\r\n" header. Messages larger than maxSize bytes (default
+// DefaultMaxMessageSize) are discarded. Closing the stream closes r only.
+func NewHeaderStream(r io.ReadCloser, w io.Writer, maxSize int) Stream {
+	return &headerStream{r: r, w: w, reader: bufio.NewReader(r), maxSize: maxSize}
+}
+
+func (s *headerStream) Read() ([]byte, error) {
+	maxSize := maxMessageSize(s.maxSize)
+	for {
+		// Read Content-Length header
+		contentLength := -1
+		for {
+			// Header lines never approach the buffer size, so a full buffer means garbage
+			line, err := s.reader.ReadSlice('\n')
+			if err == bufio.ErrBufferFull {
+				return nil, fmt.Errorf("%w: header line too long", ErrCorruptFrame)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			// Check for blank line (end of headers)
+			if string(line) == "\r\n" || string(line) == "\n" {
+				break
+			}
+
+			// Parse Content-Length
+			var length int
+			if _, err := fmt.Sscanf(string(line), "Content-Length: %d", &length); err == nil {
+				if length < 0 {
+					return nil, fmt.Errorf("%w: invalid Content-Length %d", ErrCorruptFrame, length)
+				}
+				contentLength = length
+			}
+		}
+
+		if contentLength <= 0 {
+			continue
+		}
+
+		if contentLength > maxSize {
+			if _, err := s.reader.Discard(contentLength); err != nil {
+				return nil, fmt.Errorf("failed to discard body: %w", err)
+			}
+			return nil, fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrMessageTooLarge, contentLength, maxSize)
+		}
+
+		// Read message body
+		body := make([]byte, contentLength)
+		if _, err := io.ReadFull(s.reader, body); err != nil {
+			return nil, fmt.Errorf("failed to read body: %w", err)
+		}
+		return body, nil
+	}
+}
+
+func (s *headerStream) Write(data []byte) error {
+	// Write Content-Length header + message in a single call, so each frame costs one
+	// syscall and a failed write never leaves a header without its body
+	s.frame = append(s.frame[:0], "Content-Length: "...)
+	s.frame = strconv.AppendInt(s.frame, int64(len(data)), 10)
+	s.frame = append(s.frame, "\r\n\r\n"...)
+	s.frame = append(s.frame, data...)
+	if _, err := s.w.Write(s.frame); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	// Don't pin a buffer sized for one unusually large message
+	if cap(s.frame) > maxRetainedFrame {
+		s.frame = nil
+	}
+	return nil
+}
+
+func (s *headerStream) Close() error {
+	if s.r == nil {
+		return nil
+	}
+	return s.r.Close()
+}
+
+// ndjsonStream frames messages as newline-delimited JSON
+type ndjsonStream struct {
+	r       io.ReadCloser
+	w       io.Writer
+	reader  *bufio.Reader
+	maxSize int
+}
+
+// NewNDJSONStream returns a Stream that writes each message as a single line of JSON
+// and reads one message per non-empty line. Lines longer than maxSize bytes (default
+// DefaultMaxMessageSize) are discarded. Closing the stream closes r only.
+func NewNDJSONStream(r io.ReadCloser, w io.Writer, maxSize int) Stream {
+	return &ndjsonStream{r: r, w: w, reader: bufio.NewReader(r), maxSize: maxSize}
+}
+
+func (s *ndjsonStream) Read() ([]byte, error) {
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		// encoding/json never emits raw newlines, so a line is always a whole message
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return line, nil
+		}
+	}
+}
+
+// readLine reads up to the next newline, discarding the rest of the line once it
+// exceeds the size limit
+func (s *ndjsonStream) readLine() ([]byte, error) {
+	maxSize := maxMessageSize(s.maxSize)
+	var line []byte
+	tooLarge := false
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		if !tooLarge {
+			if len(line)+len(chunk) > maxSize+2 { // allow for a trailing "\r\n"
+				tooLarge, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && len(bytes.TrimSpace(line)) > 0 && !tooLarge:
+			return line, nil
+		case err != nil:
+			return nil, err
+		case tooLarge:
+			return nil, fmt.Errorf("%w: line exceeds the limit of %d bytes", ErrMessageTooLarge, maxSize)
+		default:
+			return line, nil
+		}
+	}
+}
+
+func (s *ndjsonStream) Write(data []byte) error {
+	// Write the message and its newline in one call so lines are never interleaved
+	line := make([]byte, 0, len(data)+1)
+	line = append(append(line, data...), '\n')
+	if _, err := s.w.Write(line); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+func (s *ndjsonStream) Close() error {
+	if s.r == nil {
+		return nil
+	}
+	return s.r.Close()
+}
@@ -0,0 +1,144 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readAll reads messages from stream until it fails, returning them and the final error
+func readAll(stream Stream) ([]string, []error) {
+	var messages []string
+	var errs []error
+	for {
+		message, err := stream.Read()
+		if err == nil {
+			messages = append(messages, string(message))
+			continue
+		}
+		errs = append(errs, err)
+		if !errors.Is(err, ErrMessageTooLarge) {
+			return messages, errs
+		}
+	}
+}
+
+func TestHeaderStream(t *testing.T) {
+	t.Run("should skip an oversized message and keep reading", func(t *testing.T) {
+		big := `{"big":"` + strings.Repeat("x", 5000) + `"}`
+		input := fmt.Sprintf("Content-Length: %d\r\n\r\n%sContent-Length: 2\r\n\r\n{}", len(big), big)
+		stream := NewHeaderStream(io.NopCloser(strings.NewReader(input)), io.Discard, 10)
+
+		messages, errs := readAll(stream)
+		if len(messages) != 1 || messages[0] != "{}" {
+			t.Errorf("Expected only the small message, got %q", messages)
+		}
+		if len(errs) != 2 || !errors.Is(errs[0], ErrMessageTooLarge) || errs[1] != io.EOF {
+			t.Errorf("Expected ErrMessageTooLarge then EOF, got %v", errs)
+		}
+	})
+
+	t.Run("should apply the default limit to a zero-valued stream", func(t *testing.T) {
+		input := "Content-Length: 2\r\n\r\n{}"
+		stream := &headerStream{reader: bufio.NewReader(strings.NewReader(input))}
+
+		if message, err := stream.Read(); err != nil || string(message) != "{}" {
+			t.Errorf("Expected {}, got %q, %v", message, err)
+		}
+	})
+
+	t.Run("should reject corrupt headers", func(t *testing.T) {
+		for name, input := range map[string]string{
+			"negative length": "Content-Length: -5\r\n\r\n",
+			"huge header":     strings.Repeat("x", 8192) + "\r\n\r\n",
+		} {
+			stream := NewHeaderStream(io.NopCloser(strings.NewReader(input)), io.Discard, 0)
+			if _, err := stream.Read(); !errors.Is(err, ErrCorruptFrame) {
+				t.Errorf("%s: expected ErrCorruptFrame, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("should write each frame in a single call", func(t *testing.T) {
+		var w writeRecorder
+		stream := NewHeaderStream(nil, &w, 0)
+		stream.Write([]byte(`{"a":1}`))
+		stream.Write([]byte(`{}`))
+
+		want := []string{"Content-Length: 7\r\n\r\n{\"a\":1}", "Content-Length: 2\r\n\r\n{}"}
+		if len(w.writes) != 2 || w.writes[0] != want[0] || w.writes[1] != want[1] {
+			t.Errorf("Expected %q, got %q", want, w.writes)
+		}
+	})
+}
+
+// writeRecorder records the data of each Write call
+type writeRecorder struct {
+	writes []string
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestNDJSONStream(t *testing.T) {
+	t.Run("should exchange one message per line", func(t *testing.T) {
+		toServer, fromClient := io.Pipe()
+		fromServer, toClient := io.Pipe()
+		defer toClient.Close()
+
+		go func() {
+			reader := bufio.NewReader(toServer)
+			line, err := reader.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			var request Request
+			if err := json.Unmarshal(line, &request); err != nil {
+				t.Errorf("Expected a JSON request line, got %q", line)
+				return
+			}
+			response, _ := json.Marshal(Response{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage(`"ok"`)})
+			// Blank lines between messages are ignored
+			toClient.Write([]byte("\r\n"))
+			toClient.Write(append(response, '\r', '\n'))
+		}()
+
+		client := NewStreamClient(NewNDJSONStream(fromServer, fromClient, 0))
+		client.Start()
+		defer client.Stop()
+
+		result, err := client.Request(t.Context(), "status.get", map[string]string{"text": "multi\nline"})
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if string(result) != `"ok"` {
+			t.Errorf("Unexpected result %s", result)
+		}
+	})
+
+	t.Run("should skip an oversized line and keep reading", func(t *testing.T) {
+		input := `{"big":"` + strings.Repeat("x", 5000) + `"}` + "\n{}\n"
+		stream := NewNDJSONStream(io.NopCloser(strings.NewReader(input)), io.Discard, 100)
+
+		messages, errs := readAll(stream)
+		if len(messages) != 1 || messages[0] != "{}" {
+			t.Errorf("Expected only the small message, got %q", messages)
+		}
+		if len(errs) != 2 || !errors.Is(errs[0], ErrMessageTooLarge) || errs[1] != io.EOF {
+			t.Errorf("Expected ErrMessageTooLarge then EOF, got %v", errs)
+		}
+	})
+
+	t.Run("should return a final line without a newline", func(t *testing.T) {
+		stream := NewNDJSONStream(io.NopCloser(strings.NewReader("{}")), io.Discard, 0)
+		if message, err := stream.Read(); err != nil || string(message) != "{}" {
+			t.Errorf("Expected {}, got %q, %v", message, err)
+		}
+	})
+}
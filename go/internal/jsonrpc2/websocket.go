@@ -0,0 +1,256 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes (RFC 6455 section 5.2)
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// webSocketGUID is appended to the handshake key to compute Sec-WebSocket-Accept
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// webSocketStream carries one JSON-RPC message per WebSocket text message
+type webSocketStream struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	maxSize int
+	mask    frameMask  // of the frame being read
+	writeMu sync.Mutex // serializes data frames with pongs and close frames sent by Read
+}
+
+// NewWebSocketStream performs the WebSocket opening handshake for u over conn and
+// returns a Stream that exchanges messages as WebSocket text messages. conn must
+// already be connected (and wrapped in TLS for wss:// URLs). ctx bounds the handshake.
+// Messages larger than maxSize bytes (default DefaultMaxMessageSize) are discarded.
+func NewWebSocketStream(ctx context.Context, conn net.Conn, u *url.URL, maxSize int) (Stream, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0)) // unblock the handshake
+	})
+	defer stop()
+
+	keyBytes := make([]byte, 16)
+	rand.Read(keyBytes)
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	path := u.RequestURI()
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + u.Host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, request); err != nil {
+		return nil, fmt.Errorf("failed to send WebSocket handshake: %w", handshakeError(ctx, err))
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, &http.Request{Method: http.MethodGet})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WebSocket handshake response: %w", handshakeError(ctx, err))
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("WebSocket handshake failed: server responded %s", response.Status)
+	}
+	if !strings.EqualFold(response.Header.Get("Upgrade"), "websocket") {
+		return nil, fmt.Errorf("WebSocket handshake failed: missing Upgrade header")
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		return nil, fmt.Errorf("WebSocket handshake failed: invalid Sec-WebSocket-Accept")
+	}
+
+	stop()
+	conn.SetDeadline(time.Time{})
+	return &webSocketStream{conn: conn, reader: reader, maxSize: maxSize}, nil
+}
+
+// handshakeError reports ctx's error instead of the I/O error it caused
+func handshakeError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// webSocketAccept computes the Sec-WebSocket-Accept value for key
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (s *webSocketStream) Read() ([]byte, error) {
+	maxSize := maxMessageSize(s.maxSize)
+	var message []byte
+	tooLarge := false
+	for {
+		fin, opcode, length, err := s.readFrameHeader()
+		if err != nil {
+			return nil, err
+		}
+
+		isData := opcode == wsText || opcode == wsBinary || opcode == wsContinuation
+		if isData && (tooLarge || uint64(len(message))+length > uint64(maxSize)) {
+			// Skip the rest of the message, then report it
+			if _, err := s.reader.Discard(int(length)); err != nil {
+				return nil, fmt.Errorf("failed to discard WebSocket frame: %w", err)
+			}
+			tooLarge, message = true, nil
+			if fin {
+				return nil, fmt.Errorf("%w: exceeds the limit of %d bytes", ErrMessageTooLarge, maxSize)
+			}
+			continue
+		}
+
+		payload, err := s.readPayload(length)
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsPing:
+			if err := s.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+		case wsPong:
+		case wsClose:
+			// Echo the close frame to complete the closing handshake
+			s.writeFrame(wsClose, payload)
+			return nil, io.EOF
+		case wsText, wsBinary, wsContinuation:
+			message = append(message, payload...)
+			if fin {
+				return message, nil
+			}
+		default:
+			return nil, fmt.Errorf("%w: unexpected WebSocket opcode %#x", ErrCorruptFrame, opcode)
+		}
+	}
+}
+
+// frameMask holds the masking key of the frame being read, if any
+type frameMask struct {
+	masked bool
+	key    [4]byte
+}
+
+// readFrameHeader reads a frame's header, leaving its payload to be read or discarded
+func (s *webSocketStream) readFrameHeader() (fin bool, opcode byte, length uint64, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(s.reader, header[:]); err != nil {
+		return false, 0, 0, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	s.mask.masked = header[1]&0x80 != 0
+
+	length = uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(s.reader, ext[:]); err != nil {
+			return false, 0, 0, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(s.reader, ext[:]); err != nil {
+			return false, 0, 0, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+		if length > math.MaxInt32 {
+			return false, 0, 0, fmt.Errorf("%w: WebSocket frame length %d", ErrCorruptFrame, length)
+		}
+	}
+	if opcode >= wsClose && (length > 125 || !fin) {
+		return false, 0, 0, fmt.Errorf("%w: invalid WebSocket control frame", ErrCorruptFrame)
+	}
+
+	if s.mask.masked {
+		if _, err := io.ReadFull(s.reader, s.mask.key[:]); err != nil {
+			return false, 0, 0, err
+		}
+	}
+	return fin, opcode, length, nil
+}
+
+// readPayload reads the payload of the frame whose header was just read, unmasking it if needed
+func (s *webSocketStream) readPayload(length uint64) ([]byte, error) {
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, payload); err != nil {
+		return nil, fmt.Errorf("failed to read WebSocket frame: %w", err)
+	}
+	if s.mask.masked {
+		for i := range payload {
+			payload[i] ^= s.mask.key[i%4]
+		}
+	}
+	return payload, nil
+}
+
+func (s *webSocketStream) Write(data []byte) error {
+	if err := s.writeFrame(wsText, data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// writeFrame writes a single masked frame, as required for client-to-server frames
+func (s *webSocketStream) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+func (s *webSocketStream) Close() error {
+	// Best-effort close frame (status 1000, normal closure); don't wait on a stuck peer
+	s.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	s.writeFrame(wsClose, []byte{0x03, 0xE8})
+	return s.conn.Close()
+}
@@ -0,0 +1,159 @@
+package jsonrpc2
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// fakeWebSocketServer accepts the WebSocket handshake on conn and returns a
+// reader for the client's frames
+func fakeWebSocketServer(t *testing.T, conn net.Conn) *bufio.Reader {
+	t.Helper()
+	reader := bufio.NewReader(conn)
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		t.Errorf("Failed to read handshake: %v", err)
+		return nil
+	}
+	if request.URL.Path != "/rpc" || request.Header.Get("Upgrade") != "websocket" {
+		t.Errorf("Unexpected handshake request: %s %v", request.URL, request.Header)
+	}
+	accept := webSocketAccept(request.Header.Get("Sec-WebSocket-Key"))
+	io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: "+accept+"\r\n\r\n")
+	return reader
+}
+
+// writeServerFrame writes an unmasked frame, as servers do
+func writeServerFrame(conn net.Conn, fin bool, opcode byte, payload []byte) {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	if len(payload) < 126 {
+		frame = append(frame, byte(len(payload)))
+	} else {
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+	conn.Write(append(frame, payload...))
+}
+
+func TestWebSocketStream(t *testing.T) {
+	u, _ := url.Parse("ws://copilot.example.com/rpc")
+
+	t.Run("should exchange JSON-RPC messages as WebSocket frames", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		defer serverConn.Close()
+
+		go func() {
+			reader := fakeWebSocketServer(t, serverConn)
+			server := &webSocketStream{conn: serverConn, reader: reader}
+
+			body, err := server.Read()
+			if err != nil {
+				t.Errorf("Failed to read request: %v", err)
+				return
+			}
+			var request Request
+			json.Unmarshal(body, &request)
+
+			// Ping first, then the response split across two fragments
+			writeServerFrame(serverConn, true, wsPing, []byte("hi"))
+			_, opcode, length, _ := server.readFrameHeader()
+			if payload, _ := server.readPayload(length); opcode != wsPong || string(payload) != "hi" {
+				t.Errorf("Expected pong echoing the ping, got opcode %#x %q", opcode, payload)
+			}
+			response, _ := json.Marshal(Response{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage(`{"message":"pong"}`)})
+			writeServerFrame(serverConn, false, wsText, response[:10])
+			writeServerFrame(serverConn, true, wsContinuation, response[10:])
+		}()
+
+		stream, err := NewWebSocketStream(t.Context(), clientConn, u, 0)
+		if err != nil {
+			t.Fatalf("Handshake failed: %v", err)
+		}
+		client := NewStreamClient(stream)
+		client.Start()
+		defer client.Stop()
+
+		result, err := client.Request(t.Context(), "ping", map[string]string{"message": "hello"})
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if string(result) != `{"message":"pong"}` {
+			t.Errorf("Unexpected result %s", result)
+		}
+	})
+
+	t.Run("should skip an oversized message and keep reading", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		defer serverConn.Close()
+		stream := &webSocketStream{conn: clientConn, reader: bufio.NewReader(clientConn), maxSize: 10}
+
+		go func() {
+			writeServerFrame(serverConn, false, wsText, []byte(`{"big":`))
+			writeServerFrame(serverConn, true, wsContinuation, []byte(`"xxxxxxxx"}`))
+			writeServerFrame(serverConn, true, wsText, []byte(`{}`))
+		}()
+
+		if _, err := stream.Read(); !errors.Is(err, ErrMessageTooLarge) {
+			t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+		}
+		if message, err := stream.Read(); err != nil || string(message) != "{}" {
+			t.Errorf("Expected {}, got %q, %v", message, err)
+		}
+	})
+
+	t.Run("should reject corrupt frames", func(t *testing.T) {
+		for name, frame := range map[string][]byte{
+			"fragmented control frame": {wsPing, 0},
+			"oversized control frame":  {0x80 | wsPing, 126, 0, 200},
+			"unknown opcode":           {0x80 | 0x3, 0},
+		} {
+			clientConn, serverConn := net.Pipe()
+			stream := &webSocketStream{conn: clientConn, reader: bufio.NewReader(clientConn)}
+			go serverConn.Write(frame)
+
+			if _, err := stream.Read(); !errors.Is(err, ErrCorruptFrame) {
+				t.Errorf("%s: expected ErrCorruptFrame, got %v", name, err)
+			}
+			serverConn.Close()
+		}
+	})
+
+	t.Run("should reject a handshake with the wrong accept key", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		defer serverConn.Close()
+
+		go func() {
+			reader := bufio.NewReader(serverConn)
+			http.ReadRequest(reader)
+			io.WriteString(serverConn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: bogus\r\n\r\n")
+		}()
+
+		if _, err := NewWebSocketStream(t.Context(), clientConn, u, 0); err == nil {
+			t.Error("Expected handshake to fail")
+		}
+	})
+
+	t.Run("should fail the handshake when the context is cancelled", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		defer serverConn.Close()
+		go io.Copy(io.Discard, serverConn)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		if _, err := NewWebSocketStream(ctx, clientConn, u, 0); err == nil {
+			t.Error("Expected handshake to fail")
+		}
+	})
+}
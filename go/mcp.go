@@ -0,0 +1,194 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+)
+
+// MCPServerConfig configures an MCP server: either an [MCPLocalServerConfig] or an
+// [MCPRemoteServerConfig]. Configurations are validated when a session is created or
+// resumed.
+//
+// Example:
+//
+//	servers := map[string]copilot.MCPServerConfig{
+//	    "files": copilot.MCPLocalServerConfig{Command: "mcp-files", Args: []string{"--root", "."}},
+//	    "issues": copilot.MCPRemoteServerConfig{
+//	        URL:     "https://mcp.example.com/issues",
+//	        Headers: map[string]string{"Authorization": "Bearer " + token},
+//	    },
+//	}
+type MCPServerConfig interface {
+	// Validate reports a missing or invalid field
+	Validate() error
+	mcpServerConfig()
+}
+
+// MCPLocalServerConfig configures a local MCP server that the CLI starts and talks to
+// over stdio
+type MCPLocalServerConfig struct {
+	// Tools lists the server's tools to enable; nil enables all of them ("*") and an
+	// empty slice none
+	Tools []string `json:"tools"`
+	// Type is "local" (the default) or "stdio"
+	Type string `json:"type"`
+	// Timeout is how long a tool call may take, in milliseconds (default: the CLI's)
+	Timeout int `json:"timeout,omitempty"`
+	// Command starts the server. Required.
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env,omitempty"`
+	Cwd     string            `json:"cwd,omitempty"`
+}
+
+// MCPRemoteServerConfig configures a remote MCP server reached over HTTP or SSE. Header
+// values are redacted when the configuration is printed or logged.
+type MCPRemoteServerConfig struct {
+	// Tools lists the server's tools to enable; nil enables all of them ("*") and an
+	// empty slice none
+	Tools []string `json:"tools"`
+	// Type is "http" (the default) or "sse"
+	Type string `json:"type"`
+	// Timeout is how long a tool call may take, in milliseconds (default: the CLI's)
+	Timeout int `json:"timeout,omitempty"`
+	// URL is the server's http or https endpoint. Required.
+	URL string `json:"url"`
+	// Headers are sent with every request, e.g. for authorization
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func (MCPLocalServerConfig) mcpServerConfig()  {}
+func (MCPRemoteServerConfig) mcpServerConfig() {}
+
+// Validate reports a missing command, an unknown type, or a negative timeout
+func (c MCPLocalServerConfig) Validate() error {
+	if c.Command == "" {
+		return fmt.Errorf("command is required for a local MCP server")
+	}
+	if c.Type != "" && c.Type != "local" && c.Type != "stdio" {
+		return fmt.Errorf("invalid local MCP server type %q, expected \"local\" or \"stdio\"", c.Type)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %d", c.Timeout)
+	}
+	return nil
+}
+
+// Validate reports a missing or non-HTTP URL, an unknown type, or a negative timeout
+func (c MCPRemoteServerConfig) Validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("url is required for a remote MCP server")
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q, expected an http or https URL", c.URL)
+	}
+	if c.Type != "" && c.Type != "http" && c.Type != "sse" {
+		return fmt.Errorf("invalid remote MCP server type %q, expected \"http\" or \"sse\"", c.Type)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %d", c.Timeout)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the configuration as the CLI expects it, filling in the default
+// type, tools, and args
+func (c MCPLocalServerConfig) MarshalJSON() ([]byte, error) {
+	type wire MCPLocalServerConfig // drops the methods so json.Marshal doesn't recurse
+	w := wire(c)
+	if w.Type == "" {
+		w.Type = "local"
+	}
+	if w.Tools == nil {
+		w.Tools = []string{"*"}
+	}
+	if w.Args == nil {
+		w.Args = []string{}
+	}
+	return json.Marshal(w)
+}
+
+// MarshalJSON encodes the configuration as the CLI expects it, filling in the default
+// type and tools. Header values are sent as is.
+func (c MCPRemoteServerConfig) MarshalJSON() ([]byte, error) {
+	type wire MCPRemoteServerConfig
+	w := wire(c)
+	if w.Type == "" {
+		w.Type = "http"
+	}
+	if w.Tools == nil {
+		w.Tools = []string{"*"}
+	}
+	return json.Marshal(w)
+}
+
+// String formats the configuration with header values redacted
+func (c MCPRemoteServerConfig) String() string {
+	type plain MCPRemoteServerConfig // without String, so %+v doesn't recurse
+	c.Headers = redactHeaders(c.Headers)
+	return fmt.Sprintf("%+v", plain(c))
+}
+
+// LogValue logs the configuration with header values redacted
+func (c MCPRemoteServerConfig) LogValue() slog.Value {
+	attrs := []slog.Attr{slog.String("type", c.Type), slog.String("url", c.URL)}
+	if c.Tools != nil {
+		attrs = append(attrs, slog.Any("tools", c.Tools))
+	}
+	if c.Timeout != 0 {
+		attrs = append(attrs, slog.Int("timeout", c.Timeout))
+	}
+	if len(c.Headers) > 0 {
+		attrs = append(attrs, slog.Any("headers", redactHeaders(c.Headers)))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// redactHeaders returns a copy of headers with every value replaced
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for name := range headers {
+		redacted[name] = "REDACTED"
+	}
+	return redacted
+}
+
+// validateMCPServers validates the MCP servers of a session and of its custom agents
+func validateMCPServers(servers map[string]MCPServerConfig, agents []CustomAgentConfig) error {
+	check := func(servers map[string]MCPServerConfig, owner string) error {
+		for _, name := range slices.Sorted(maps.Keys(servers)) {
+			server := servers[name]
+			if server == nil {
+				return fmt.Errorf("invalid MCP server %q%s: no configuration", name, owner)
+			}
+			if err := server.Validate(); err != nil {
+				return fmt.Errorf("invalid MCP server %q%s: %w", name, owner, err)
+			}
+		}
+		return nil
+	}
+	if err := check(servers, ""); err != nil {
+		return err
+	}
+	for _, agent := range agents {
+		if err := check(agent.MCPServers, fmt.Sprintf(" of agent %q", agent.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
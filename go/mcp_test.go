@@ -0,0 +1,90 @@
+package copilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMCPServerConfig(t *testing.T) {
+	t.Run("validates required fields", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			config MCPServerConfig
+			want   string
+		}{
+			"local without command":   {MCPLocalServerConfig{Args: []string{"x"}}, "command is required"},
+			"local with remote type":  {MCPLocalServerConfig{Command: "node", Type: "http"}, `invalid local MCP server type "http"`},
+			"remote without url":      {MCPRemoteServerConfig{}, "url is required"},
+			"remote with file url":    {MCPRemoteServerConfig{URL: "file:///tmp/server"}, "expected an http or https URL"},
+			"remote with local type":  {MCPRemoteServerConfig{URL: "https://example.com", Type: "stdio"}, `invalid remote MCP server type "stdio"`},
+			"remote negative timeout": {MCPRemoteServerConfig{URL: "https://example.com", Timeout: -1}, "invalid timeout"},
+		} {
+			if err := tc.config.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+			}
+		}
+
+		for _, config := range []MCPServerConfig{
+			MCPLocalServerConfig{Command: "node", Type: "stdio"},
+			MCPRemoteServerConfig{URL: "https://example.com/mcp", Type: "sse"},
+		} {
+			if err := config.Validate(); err != nil {
+				t.Errorf("Expected %T to be valid, got %v", config, err)
+			}
+		}
+	})
+
+	t.Run("marshals what the CLI expects", func(t *testing.T) {
+		data, err := json.Marshal(map[string]MCPServerConfig{
+			"local":  MCPLocalServerConfig{Command: "node"},
+			"remote": MCPRemoteServerConfig{URL: "https://example.com/mcp", Tools: []string{}, Headers: map[string]string{"Authorization": "Bearer secret"}},
+		})
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		want := `{"local":{"tools":["*"],"type":"local","command":"node","args":[]},` +
+			`"remote":{"tools":[],"type":"http","url":"https://example.com/mcp","headers":{"Authorization":"Bearer secret"}}}`
+		if string(data) != want {
+			t.Errorf("Unexpected JSON:\n got %s\nwant %s", data, want)
+		}
+	})
+
+	t.Run("redacts headers when printed or logged", func(t *testing.T) {
+		config := MCPRemoteServerConfig{URL: "https://example.com/mcp", Headers: map[string]string{"Authorization": "Bearer secret"}}
+
+		var logged bytes.Buffer
+		slog.New(slog.NewTextHandler(&logged, nil)).Info("mcp", "server", config)
+		for _, out := range []string{fmt.Sprint(config), fmt.Sprintf("%+v", config), logged.String()} {
+			if strings.Contains(out, "secret") || !strings.Contains(out, "Authorization") || !strings.Contains(out, "https://example.com/mcp") {
+				t.Errorf("Expected the header value to be redacted, got %s", out)
+			}
+		}
+		if config.Headers["Authorization"] != "Bearer secret" {
+			t.Error("Expected redaction to leave the config unchanged")
+		}
+	})
+
+	t.Run("rejects invalid servers before creating a session", func(t *testing.T) {
+		client := NewClient(&ClientOptions{AutoStart: Bool(false)})
+
+		_, err := client.CreateSession(t.Context(), &SessionConfig{
+			CustomAgents: []CustomAgentConfig{{
+				Name:       "reviewer",
+				MCPServers: map[string]MCPServerConfig{"issues": MCPRemoteServerConfig{}},
+			}},
+		})
+		if err == nil || err.Error() != `invalid MCP server "issues" of agent "reviewer": url is required for a remote MCP server` {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		_, err = client.ResumeSessionWithOptions(t.Context(), "session-1", &ResumeSessionConfig{
+			MCPServers: map[string]MCPServerConfig{"files": nil},
+		})
+		if err == nil || !strings.Contains(err.Error(), `invalid MCP server "files": no configuration`) {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"strconv"
+	"time"
+)
+
+// Metric names reported to [ClientOptions.Metrics]. They follow OpenTelemetry naming
+// so an adapter can create an instrument per name.
+const (
+	// MetricActiveSessions is an up-down counter of sessions created or resumed and
+	// not yet destroyed
+	MetricActiveSessions = "copilot.sessions.active"
+	// MetricTurns counts assistant turns; attribute "session.id"
+	MetricTurns = "copilot.turns"
+	// MetricToolCalls counts SDK tool calls; attributes "tool.name" and "success"
+	MetricToolCalls = "copilot.tool.calls"
+	// MetricToolDuration is a histogram of SDK tool call durations in seconds;
+	// attributes "tool.name" and "success"
+	MetricToolDuration = "copilot.tool.duration"
+	// MetricPermissionDenials counts denied permission requests; attributes
+	// "permission.kind" and "result"
+	MetricPermissionDenials = "copilot.permission.denials"
+	// MetricTokens counts model tokens; attributes "model" and "token.type" ("input"
+	// or "output")
+	MetricTokens = "copilot.tokens"
+)
+
+// Metrics receives the SDK's measurements, for forwarding to a metrics backend such as
+// an OpenTelemetry meter. Methods may be called concurrently and should not block.
+//
+// Example:
+//
+//	type otelMetrics struct{ meter metric.Meter }
+//
+//	func (m otelMetrics) Add(name string, delta int64, attrs map[string]string) {
+//	    counter, _ := m.meter.Int64UpDownCounter(name) // cache instruments in real code
+//	    counter.Add(context.Background(), delta, metric.WithAttributes(toAttributes(attrs)...))
+//	}
+//
+//	func (m otelMetrics) Record(name string, value float64, attrs map[string]string) {
+//	    histogram, _ := m.meter.Float64Histogram(name, metric.WithUnit("s"))
+//	    histogram.Record(context.Background(), value, metric.WithAttributes(toAttributes(attrs)...))
+//	}
+type Metrics interface {
+	// Add adds delta to the counter name
+	Add(name string, delta int64, attributes map[string]string)
+	// Record records value in the histogram name
+	Record(name string, value float64, attributes map[string]string)
+}
+
+// recordEventMetrics reports the turns and tokens of a session event
+func recordEventMetrics(metrics Metrics, sessionID string, event SessionEvent) {
+	switch event.Type {
+	case AssistantTurnStart:
+		metrics.Add(MetricTurns, 1, map[string]string{"session.id": sessionID})
+	case AssistantUsage:
+		model := ""
+		if event.Data.Model != nil {
+			model = *event.Data.Model
+		}
+		if event.Data.InputTokens != nil {
+			metrics.Add(MetricTokens, int64(*event.Data.InputTokens), map[string]string{"model": model, "token.type": "input"})
+		}
+		if event.Data.OutputTokens != nil {
+			metrics.Add(MetricTokens, int64(*event.Data.OutputTokens), map[string]string{"model": model, "token.type": "output"})
+		}
+	}
+}
+
+// recordToolMetrics reports a finished SDK tool call
+func recordToolMetrics(metrics Metrics, toolName string, duration time.Duration, result ToolResult) {
+	attributes := map[string]string{"tool.name": toolName, "success": strconv.FormatBool(result.ResultType == "success")}
+	metrics.Add(MetricToolCalls, 1, attributes)
+	metrics.Record(MetricToolDuration, duration.Seconds(), attributes)
+}
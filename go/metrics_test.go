@@ -0,0 +1,140 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// recordedMetric is one call to a recordingMetrics
+type recordedMetric struct {
+	name       string
+	value      float64
+	attributes map[string]string
+}
+
+// recordingMetrics is a Metrics that keeps every measurement it receives
+type recordingMetrics struct {
+	mu       sync.Mutex
+	recorded []recordedMetric
+}
+
+func (m *recordingMetrics) Add(name string, delta int64, attributes map[string]string) {
+	m.Record(name, float64(delta), attributes)
+}
+
+func (m *recordingMetrics) Record(name string, value float64, attributes map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recorded = append(m.recorded, recordedMetric{name, value, attributes})
+}
+
+// named returns the measurements recorded for name
+func (m *recordingMetrics) named(name string) []recordedMetric {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []recordedMetric
+	for _, r := range m.recorded {
+		if r.name == name {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("counts turns and tokens from session events", func(t *testing.T) {
+		metrics := &recordingMetrics{}
+		client := NewClient(&ClientOptions{Metrics: metrics})
+
+		model := "gpt-5"
+		client.handleSessionEvent(sessionEventRequest{SessionID: "session-1", Event: SessionEvent{Type: AssistantTurnStart}})
+		client.handleSessionEvent(sessionEventRequest{SessionID: "session-1", Event: SessionEvent{Type: AssistantUsage, Data: Data{Model: &model, InputTokens: Float64(120), OutputTokens: Float64(30)}}})
+
+		if turns := metrics.named(MetricTurns); len(turns) != 1 || turns[0].attributes["session.id"] != "session-1" {
+			t.Errorf("Expected one turn for session-1, got %+v", turns)
+		}
+		tokens := metrics.named(MetricTokens)
+		if len(tokens) != 2 {
+			t.Fatalf("Expected input and output token counts, got %+v", tokens)
+		}
+		if tokens[0].value != 120 || tokens[0].attributes["token.type"] != "input" || tokens[0].attributes["model"] != "gpt-5" {
+			t.Errorf("Unexpected input tokens %+v", tokens[0])
+		}
+		if tokens[1].value != 30 || tokens[1].attributes["token.type"] != "output" {
+			t.Errorf("Unexpected output tokens %+v", tokens[1])
+		}
+	})
+
+	t.Run("records tool calls with duration and success", func(t *testing.T) {
+		metrics := &recordingMetrics{}
+		client := NewClient(&ClientOptions{Metrics: metrics})
+
+		client.executeToolCall(t.Context(), "session-1", "call-1", "build", nil,
+			func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+				return ToolResult{ResultType: "success"}, nil
+			})
+		client.executeToolCall(t.Context(), "session-1", "call-2", "deploy", nil,
+			func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+				return ToolResult{}, errors.New("boom")
+			})
+		client.executeToolCall(t.Context(), "session-1", "call-3", "crash", nil,
+			func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+				panic("oops")
+			})
+
+		calls := metrics.named(MetricToolCalls)
+		if len(calls) != 3 {
+			t.Fatalf("Expected 3 tool calls, got %+v", calls)
+		}
+		for i, want := range []struct{ tool, success string }{{"build", "true"}, {"deploy", "false"}, {"crash", "false"}} {
+			if calls[i].attributes["tool.name"] != want.tool || calls[i].attributes["success"] != want.success {
+				t.Errorf("Call %d: expected %s success=%s, got %+v", i, want.tool, want.success, calls[i].attributes)
+			}
+		}
+		if durations := metrics.named(MetricToolDuration); len(durations) != 3 || durations[0].value < 0 {
+			t.Errorf("Expected 3 durations, got %+v", durations)
+		}
+	})
+
+	t.Run("counts permission denials", func(t *testing.T) {
+		metrics := &recordingMetrics{}
+		client := NewClient(&ClientOptions{Metrics: metrics})
+		session := newSession("session-1", nil, "")
+		session.registerPermissionHandler(func(ctx context.Context, request PermissionRequest, invocation PermissionInvocation) (PermissionRequestResult, error) {
+			if request.Kind == "read" {
+				return PermissionRequestResult{Kind: "approved"}, nil
+			}
+			return PermissionRequestResult{Kind: "denied-interactively-by-user"}, nil
+		})
+		client.sessions["session-1"] = session
+
+		client.handlePermissionRequest(t.Context(), permissionRequestRequest{SessionID: "session-1", Request: PermissionRequest{Kind: "read"}})
+		client.handlePermissionRequest(t.Context(), permissionRequestRequest{SessionID: "session-1", Request: PermissionRequest{Kind: "shell"}})
+
+		denials := metrics.named(MetricPermissionDenials)
+		if len(denials) != 1 || denials[0].attributes["permission.kind"] != "shell" || denials[0].attributes["result"] != "denied-interactively-by-user" {
+			t.Errorf("Expected one shell denial, got %+v", denials)
+		}
+	})
+
+	t.Run("tracks active sessions until destroyed", func(t *testing.T) {
+		metrics := &recordingMetrics{}
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return map[string]any{}
+		})
+		session.setMetrics(metrics)
+
+		if err := session.Destroy(); err != nil {
+			t.Fatalf("Destroy failed: %v", err)
+		}
+		session.Destroy()
+
+		active := metrics.named(MetricActiveSessions)
+		if len(active) != 2 || active[0].value != 1 || active[1].value != -1 {
+			t.Errorf("Expected +1 then a single -1, got %+v", active)
+		}
+	})
+}
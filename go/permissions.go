@@ -0,0 +1,386 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Handle evaluates request against the policy and can be used directly as
+// SessionConfig.OnPermissionRequest. Deny rules are checked first, then the other
+// rules in order, then decisions persisted with [PermissionPolicy.AlwaysAllow], then
+// Fallback, then Default.
+//
+// Example:
+//
+//	policy := &copilot.PermissionPolicy{
+//	    Rules: []copilot.PermissionRule{
+//	        {Kinds: []string{"shell"}, CommandPattern: `\brm\b`, Action: copilot.PermissionDeny},
+//	        {Kinds: []string{"read"}, Action: copilot.PermissionAllow},
+//	        {Kinds: []string{"write"}, PathGlob: "/repo/src/**", Action: copilot.PermissionAllow},
+//	        {Kinds: []string{"shell"}, CommandPattern: `^(go|git) [^;&|]*$`, Action: copilot.PermissionAllow},
+//	    },
+//	    Store: copilot.NewFilePermissionStore(".copilot-permissions.json"),
+//	}
+//	session, _ := client.CreateSession(ctx, &copilot.SessionConfig{
+//	    OnPermissionRequest: policy.Handle,
+//	})
+func (p *PermissionPolicy) Handle(ctx context.Context, request PermissionRequest, invocation PermissionInvocation) (PermissionRequestResult, error) {
+	rule, err := matchPermissionRules(p.Rules, request)
+	if err != nil {
+		return PermissionRequestResult{}, err
+	}
+	if rule != nil {
+		return rule.Action.result(), nil
+	}
+
+	persisted, err := p.persistedRules()
+	if err != nil {
+		return PermissionRequestResult{}, err
+	}
+	rule, err = matchPermissionRules(persisted, request)
+	if err != nil {
+		return PermissionRequestResult{}, err
+	}
+	if rule != nil {
+		return rule.Action.result(), nil
+	}
+
+	if p.Fallback != nil {
+		return p.Fallback(ctx, request, invocation)
+	}
+	if p.Default == "" {
+		return PermissionRequestResult{Kind: "denied-no-approval-rule-and-could-not-request-from-user"}, nil
+	}
+	return p.Default.result(), nil
+}
+
+// AlwaysAllow records a rule that approves matching requests from now on, saving it
+// to the policy's Store if one is configured.
+func (p *PermissionPolicy) AlwaysAllow(rule PermissionRule) error {
+	rule.Action = PermissionAllow
+	if _, err := rule.Matches(PermissionRequest{}); err != nil {
+		return err
+	}
+
+	if _, err := p.persistedRules(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.persisted = append(p.persisted, rule)
+	if p.Store == nil {
+		return nil
+	}
+	if err := p.Store.Save(p.persisted); err != nil {
+		return fmt.Errorf("failed to save permission rules: %w", err)
+	}
+	return nil
+}
+
+// persistedRules returns the "always allow" rules, loading them from Store on first use
+func (p *PermissionPolicy) persistedRules() ([]PermissionRule, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.loaded {
+		if p.Store != nil {
+			rules, err := p.Store.Load()
+			if err != nil {
+				return nil, fmt.Errorf("failed to load permission rules: %w", err)
+			}
+			p.persisted = append(rules, p.persisted...)
+		}
+		p.loaded = true
+	}
+	return append([]PermissionRule(nil), p.persisted...), nil
+}
+
+// matchPermissionRules returns the first deny rule matching request, or else the first
+// other matching rule, or nil if none match. Checking deny rules first keeps a broad
+// allow rule from approving a request that a deny rule was written to catch.
+func matchPermissionRules(rules []PermissionRule, request PermissionRequest) (*PermissionRule, error) {
+	for _, deny := range []bool{true, false} {
+		for i := range rules {
+			if (rules[i].Action == PermissionDeny) != deny {
+				continue
+			}
+			matched, err := rules[i].Matches(request)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				return &rules[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (a PermissionAction) result() PermissionRequestResult {
+	if a == PermissionAllow {
+		return PermissionRequestResult{Kind: "approved"}
+	}
+	return PermissionRequestResult{Kind: "denied-by-rules"}
+}
+
+// Matches reports whether request satisfies every condition set on the rule.
+// Path conditions on allow rules require all of the request's paths to match;
+// on deny rules any matching path is enough. Paths are cleaned before matching, and
+// a path that still climbs out with ".." never satisfies an allow rule but always
+// satisfies a deny rule. An error is returned if the rule's CommandPattern or
+// PathGlob is invalid.
+func (r PermissionRule) Matches(request PermissionRequest) (bool, error) {
+	var command *regexp.Regexp
+	if r.CommandPattern != "" {
+		var err error
+		if command, err = compilePattern("command", r.CommandPattern, regexp.Compile); err != nil {
+			return false, fmt.Errorf("invalid command pattern %q: %w", r.CommandPattern, err)
+		}
+	}
+	var paths *regexp.Regexp
+	if r.PathGlob != "" {
+		var err error
+		if paths, err = compilePattern("glob", r.PathGlob, globToRegexp); err != nil {
+			return false, fmt.Errorf("invalid path glob %q: %w", r.PathGlob, err)
+		}
+	}
+
+	if len(r.Kinds) > 0 && !slices.Contains(r.Kinds, request.Kind) {
+		return false, nil
+	}
+	if r.ServerName != "" || r.ToolName != "" {
+		mcp, ok := request.MCP()
+		if !ok {
+			return false, nil
+		}
+		if ok, _ := path.Match(r.ServerName, mcp.ServerName); r.ServerName != "" && !ok {
+			return false, nil
+		}
+		if ok, _ := path.Match(r.ToolName, mcp.ToolName); r.ToolName != "" && !ok {
+			return false, nil
+		}
+	}
+	if command != nil {
+		shell, ok := request.Shell()
+		if !ok || !command.MatchString(shell.FullCommandText) {
+			return false, nil
+		}
+	}
+	if paths != nil {
+		requestPaths := permissionRequestPaths(request)
+		if len(requestPaths) == 0 {
+			return false, nil
+		}
+		matched := 0
+		for _, p := range requestPaths {
+			p, ok := cleanPermissionPath(p)
+			if !ok {
+				if r.Action == PermissionDeny {
+					matched++
+				}
+				continue
+			}
+			if paths.MatchString(p) {
+				matched++
+			}
+		}
+		if r.Action == PermissionDeny && matched == 0 {
+			return false, nil
+		}
+		if r.Action != PermissionDeny && matched < len(requestPaths) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// cleanPermissionPath cleans p into slash-separated form. It reports false if the
+// cleaned path still contains a ".." element, since it can't be matched safely.
+func cleanPermissionPath(p string) (string, bool) {
+	p = filepath.ToSlash(filepath.Clean(p))
+	if slices.Contains(strings.Split(p, "/"), "..") {
+		return "", false
+	}
+	return p, true
+}
+
+// compiledPatterns caches compiled command patterns and path globs by source, since
+// rules are plain values that are matched against every request
+var compiledPatterns sync.Map // map[string]compiledPattern
+
+type compiledPattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+// compilePattern compiles pattern with compile, reusing an earlier result for the
+// same kind of pattern
+func compilePattern(kind, pattern string, compile func(string) (*regexp.Regexp, error)) (*regexp.Regexp, error) {
+	key := kind + "\x00" + pattern
+	if cached, ok := compiledPatterns.Load(key); ok {
+		c := cached.(compiledPattern)
+		return c.re, c.err
+	}
+	re, err := compile(pattern)
+	compiledPatterns.Store(key, compiledPattern{re, err})
+	return re, err
+}
+
+// permissionRequestPaths returns the file paths a request refers to
+func permissionRequestPaths(request PermissionRequest) []string {
+	if read, ok := request.Read(); ok && read.Path != "" {
+		return []string{read.Path}
+	}
+	if write, ok := request.Write(); ok && write.FileName != "" {
+		return []string{write.FileName}
+	}
+	if shell, ok := request.Shell(); ok {
+		return shell.PossiblePaths
+	}
+	return nil
+}
+
+// globToRegexp converts a slash-separated glob into an anchored regular expression.
+// "**" matches any sequence of characters including "/", "*" matches any sequence
+// without "/", and "?" matches a single character other than "/". A backslash before
+// one of "*?[\\" matches that character literally.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '\\':
+			if i+1 < len(glob) && strings.IndexByte(globMeta, glob[i+1]) >= 0 {
+				b.WriteString(regexp.QuoteMeta(glob[i+1 : i+2]))
+				i++
+				continue
+			}
+			b.WriteString(regexp.QuoteMeta(filepath.ToSlash(string(c))))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// globMeta holds the characters with a special meaning in PathGlob, ServerName, and ToolName
+const globMeta = `*?[\`
+
+// escapeGlob quotes s so that it matches only itself as a PathGlob, ServerName, or ToolName
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(globMeta, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// NewFilePermissionStore returns a PermissionStore that keeps rules as JSON in the file at path.
+// A missing file is treated as an empty rule set.
+func NewFilePermissionStore(path string) PermissionStore {
+	return &filePermissionStore{path: path}
+}
+
+type filePermissionStore struct {
+	path string
+}
+
+func (s *filePermissionStore) Load() ([]PermissionRule, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []PermissionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (s *filePermissionStore) Save(rules []PermissionRule) error {
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0o600)
+}
+
+// Shell returns the request's shell-specific fields, or false if it is not a "shell" request.
+//
+// Example:
+//
+//	func(ctx context.Context, request copilot.PermissionRequest, inv copilot.PermissionInvocation) (copilot.PermissionRequestResult, error) {
+//	    if shell, ok := request.Shell(); ok && strings.HasPrefix(shell.FullCommandText, "git ") {
+//	        return copilot.PermissionRequestResult{Kind: "approved"}, nil
+//	    }
+//	    return copilot.PermissionRequestResult{Kind: "denied-by-rules"}, nil
+//	}
+func (r PermissionRequest) Shell() (*ShellPermissionRequest, bool) {
+	return decodePermissionRequest[ShellPermissionRequest](r, "shell")
+}
+
+// Write returns the request's write-specific fields, or false if it is not a "write" request.
+func (r PermissionRequest) Write() (*WritePermissionRequest, bool) {
+	return decodePermissionRequest[WritePermissionRequest](r, "write")
+}
+
+// Read returns the request's read-specific fields, or false if it is not a "read" request.
+func (r PermissionRequest) Read() (*ReadPermissionRequest, bool) {
+	return decodePermissionRequest[ReadPermissionRequest](r, "read")
+}
+
+// MCP returns the request's MCP-specific fields, or false if it is not an "mcp" request.
+func (r PermissionRequest) MCP() (*MCPPermissionRequest, bool) {
+	return decodePermissionRequest[MCPPermissionRequest](r, "mcp")
+}
+
+// URL returns the request's URL-specific fields, or false if it is not a "url" request.
+func (r PermissionRequest) URL() (*URLPermissionRequest, bool) {
+	return decodePermissionRequest[URLPermissionRequest](r, "url")
+}
+
+// decodePermissionRequest decodes Extra into T if the request has the given kind
+func decodePermissionRequest[T any](r PermissionRequest, kind string) (*T, bool) {
+	if r.Kind != kind {
+		return nil, false
+	}
+	data, err := json.Marshal(r.Extra)
+	if err != nil {
+		return nil, false
+	}
+	var typed T
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, false
+	}
+	return &typed, true
+}
@@ -0,0 +1,219 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestPermissionRequest_UnmarshalJSON(t *testing.T) {
+	var request PermissionRequest
+	err := json.Unmarshal([]byte(`{"kind":"shell","toolCallId":"call-1","fullCommandText":"ls -la","possiblePaths":["/tmp"]}`), &request)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if request.Kind != "shell" || request.ToolCallID != "call-1" {
+		t.Errorf("Unexpected request: %+v", request)
+	}
+	if request.Extra["fullCommandText"] != "ls -la" {
+		t.Errorf("Expected kind-specific fields in Extra, got %v", request.Extra)
+	}
+	if _, ok := request.Extra["kind"]; ok {
+		t.Error("Expected kind to be excluded from Extra")
+	}
+}
+
+func TestPermissionRequest_TypedVariants(t *testing.T) {
+	decode := func(t *testing.T, data string) PermissionRequest {
+		t.Helper()
+		var request PermissionRequest
+		if err := json.Unmarshal([]byte(data), &request); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		return request
+	}
+
+	t.Run("shell", func(t *testing.T) {
+		request := decode(t, `{"kind":"shell","fullCommandText":"git push","intention":"Publish","commands":[{"identifier":"git","readOnly":false}],"possiblePaths":["/repo"]}`)
+		shell, ok := request.Shell()
+		if !ok {
+			t.Fatal("Expected shell request")
+		}
+		if shell.FullCommandText != "git push" || shell.Intention != "Publish" || len(shell.Commands) != 1 || shell.Commands[0].Identifier != "git" || shell.PossiblePaths[0] != "/repo" {
+			t.Errorf("Unexpected shell fields: %+v", shell)
+		}
+		if _, ok := request.Write(); ok {
+			t.Error("Expected Write to report false for a shell request")
+		}
+	})
+
+	t.Run("write", func(t *testing.T) {
+		write, ok := decode(t, `{"kind":"write","fileName":"main.go","diff":"+x"}`).Write()
+		if !ok || write.FileName != "main.go" || write.Diff != "+x" {
+			t.Errorf("Unexpected write fields: %+v", write)
+		}
+	})
+
+	t.Run("read", func(t *testing.T) {
+		read, ok := decode(t, `{"kind":"read","path":"/etc/hosts"}`).Read()
+		if !ok || read.Path != "/etc/hosts" {
+			t.Errorf("Unexpected read fields: %+v", read)
+		}
+	})
+
+	t.Run("mcp", func(t *testing.T) {
+		mcp, ok := decode(t, `{"kind":"mcp","serverName":"github","toolName":"search","args":{"q":"bug"},"readOnly":true}`).MCP()
+		if !ok || mcp.ServerName != "github" || mcp.ToolName != "search" || !mcp.ReadOnly || mcp.Args == nil {
+			t.Errorf("Unexpected mcp fields: %+v", mcp)
+		}
+	})
+
+	t.Run("url", func(t *testing.T) {
+		url, ok := decode(t, `{"kind":"url","url":"https://example.com"}`).URL()
+		if !ok || url.URL != "https://example.com" {
+			t.Errorf("Unexpected url fields: %+v", url)
+		}
+	})
+}
+
+func TestPermissionRule_Matches(t *testing.T) {
+	shell := func(command string, paths ...any) PermissionRequest {
+		return PermissionRequest{Kind: "shell", Extra: map[string]any{"fullCommandText": command, "possiblePaths": paths}}
+	}
+	write := PermissionRequest{Kind: "write", Extra: map[string]any{"fileName": "/repo/src/pkg/main.go"}}
+	mcp := PermissionRequest{Kind: "mcp", Extra: map[string]any{"toolName": "github_search_issues"}}
+
+	tests := []struct {
+		name    string
+		rule    PermissionRule
+		request PermissionRequest
+		want    bool
+	}{
+		{"empty rule matches anything", PermissionRule{}, write, true},
+		{"kind matches", PermissionRule{Kinds: []string{"write"}}, write, true},
+		{"kind mismatch", PermissionRule{Kinds: []string{"read"}}, write, false},
+		{"command pattern matches", PermissionRule{CommandPattern: `^go (test|build)`}, shell("go test ./..."), true},
+		{"command pattern mismatch", PermissionRule{CommandPattern: `^go `}, shell("rm -rf /"), false},
+		{"command pattern needs a command", PermissionRule{CommandPattern: `.*`}, write, false},
+		{"double star spans directories", PermissionRule{PathGlob: "/repo/src/**"}, write, true},
+		{"single star stays in directory", PermissionRule{PathGlob: "/repo/src/*"}, write, false},
+		{"allow requires every path to match", PermissionRule{PathGlob: "/repo/**", Action: PermissionAllow}, shell("cp", "/repo/a", "/etc/b"), false},
+		{"deny matches any path", PermissionRule{PathGlob: "/etc/**", Action: PermissionDeny}, shell("cp", "/repo/a", "/etc/b"), true},
+		{"paths are cleaned before matching", PermissionRule{PathGlob: "/repo/src/**", Action: PermissionAllow}, shell("cat", "/repo/src/../../etc/passwd"), false},
+		{"cleaned paths inside the glob match", PermissionRule{PathGlob: "/repo/src/**", Action: PermissionAllow}, shell("cat", "/repo/src/a/../b.go"), true},
+		{"allow rejects paths escaping with ..", PermissionRule{PathGlob: "**", Action: PermissionAllow}, shell("cat", "../secrets"), false},
+		{"deny matches paths escaping with ..", PermissionRule{PathGlob: "/etc/**", Action: PermissionDeny}, shell("cat", "../secrets"), true},
+		{"tool name glob", PermissionRule{ToolName: "github_*"}, mcp, true},
+		{"server name mismatch", PermissionRule{ServerName: "jira", ToolName: "github_*"}, mcp, false},
+		{"escaped glob is literal", PermissionRule{PathGlob: `/repo/src/\*\*`}, write, false},
+		{"tool name mismatch", PermissionRule{ToolName: "jira_*"}, mcp, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.Matches(tt.request)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("invalid command pattern is an error", func(t *testing.T) {
+		if _, err := (PermissionRule{CommandPattern: "("}).Matches(shell("ls")); err == nil {
+			t.Error("Expected error for invalid pattern")
+		}
+	})
+}
+
+func TestPermissionPolicy_Handle(t *testing.T) {
+	invocation := PermissionInvocation{SessionID: "session-1"}
+	read := PermissionRequest{Kind: "read", Extra: map[string]any{"path": "/repo/README.md"}}
+	shell := PermissionRequest{Kind: "shell", Extra: map[string]any{"fullCommandText": "rm -rf build"}}
+
+	t.Run("first matching rule decides", func(t *testing.T) {
+		policy := &PermissionPolicy{Rules: []PermissionRule{
+			{CommandPattern: `\brm\b`, Action: PermissionDeny},
+			{Action: PermissionAllow},
+		}}
+
+		result, _ := policy.Handle(t.Context(), shell, invocation)
+		if result.Kind != "denied-by-rules" {
+			t.Errorf("Expected denied-by-rules, got %q", result.Kind)
+		}
+		result, _ = policy.Handle(t.Context(), read, invocation)
+		if result.Kind != "approved" {
+			t.Errorf("Expected approved, got %q", result.Kind)
+		}
+	})
+
+	t.Run("deny rules win over earlier allow rules", func(t *testing.T) {
+		policy := &PermissionPolicy{Rules: []PermissionRule{
+			{Kinds: []string{"shell"}, CommandPattern: `^(go|git) `, Action: PermissionAllow},
+			{Kinds: []string{"shell"}, CommandPattern: `\brm\b`, Action: PermissionDeny},
+		}}
+		chained := PermissionRequest{Kind: "shell", Extra: map[string]any{"fullCommandText": "git log; rm -rf ~"}}
+
+		result, _ := policy.Handle(t.Context(), chained, invocation)
+		if result.Kind != "denied-by-rules" {
+			t.Errorf("Expected denied-by-rules, got %q", result.Kind)
+		}
+	})
+
+	t.Run("unmatched requests are denied by default", func(t *testing.T) {
+		policy := &PermissionPolicy{}
+		result, _ := policy.Handle(t.Context(), read, invocation)
+		if result.Kind != "denied-no-approval-rule-and-could-not-request-from-user" {
+			t.Errorf("Unexpected result: %q", result.Kind)
+		}
+	})
+
+	t.Run("unmatched requests use Fallback", func(t *testing.T) {
+		called := false
+		policy := &PermissionPolicy{
+			Default: PermissionDeny,
+			Fallback: func(ctx context.Context, request PermissionRequest, inv PermissionInvocation) (PermissionRequestResult, error) {
+				called = true
+				return PermissionRequestResult{Kind: "denied-interactively-by-user"}, nil
+			},
+		}
+		result, _ := policy.Handle(t.Context(), read, invocation)
+		if !called || result.Kind != "denied-interactively-by-user" {
+			t.Errorf("Expected fallback decision, got %q", result.Kind)
+		}
+	})
+
+	t.Run("AlwaysAllow persists decisions", func(t *testing.T) {
+		store := NewFilePermissionStore(filepath.Join(t.TempDir(), "permissions.json"))
+		policy := &PermissionPolicy{Store: store}
+
+		if err := policy.AlwaysAllow(PermissionRule{Kinds: []string{"read"}, PathGlob: "/repo/**"}); err != nil {
+			t.Fatalf("AlwaysAllow failed: %v", err)
+		}
+		result, _ := policy.Handle(t.Context(), read, invocation)
+		if result.Kind != "approved" {
+			t.Errorf("Expected approved, got %q", result.Kind)
+		}
+
+		reloaded := &PermissionPolicy{Store: store}
+		result, err := reloaded.Handle(t.Context(), read, invocation)
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+		if result.Kind != "approved" {
+			t.Errorf("Expected persisted rule to approve, got %q", result.Kind)
+		}
+	})
+
+	t.Run("explicit rules take precedence over persisted ones", func(t *testing.T) {
+		policy := &PermissionPolicy{Rules: []PermissionRule{{Kinds: []string{"read"}, Action: PermissionDeny}}}
+		policy.AlwaysAllow(PermissionRule{Kinds: []string{"read"}})
+
+		result, _ := policy.Handle(t.Context(), read, invocation)
+		if result.Kind != "denied-by-rules" {
+			t.Errorf("Expected explicit deny to win, got %q", result.Kind)
+		}
+	})
+}
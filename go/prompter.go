@@ -0,0 +1,225 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// TerminalPermissionPrompter asks the user to approve permission requests on a terminal.
+// It prints each request's details and reads y (allow once), n (deny), or a (always
+// allow matching requests). Use [TerminalPermissionPrompter.Handle] as
+// SessionConfig.OnPermissionRequest or as a PermissionPolicy's Fallback.
+//
+// Example:
+//
+//	prompter := &copilot.TerminalPermissionPrompter{}
+//	session, _ := client.CreateSession(ctx, &copilot.SessionConfig{
+//	    OnPermissionRequest: prompter.Handle,
+//	})
+type TerminalPermissionPrompter struct {
+	// In is read for answers (default: os.Stdin)
+	In io.Reader
+	// Out receives the prompts (default: os.Stderr)
+	Out io.Writer
+	// Policy records "always" answers with AlwaysAllow. If nil, they are remembered
+	// in memory for the prompter's lifetime.
+	Policy *PermissionPolicy
+
+	mu      sync.Mutex
+	once    sync.Once
+	lines   chan string
+	session PermissionPolicy
+}
+
+// Handle prompts for a decision on request. Concurrent requests are prompted one at
+// a time. If ctx is done before the user answers, the request is denied.
+func (p *TerminalPermissionPrompter) Handle(ctx context.Context, request PermissionRequest, invocation PermissionInvocation) (PermissionRequestResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	policy := p.policy()
+	persisted, err := policy.persistedRules()
+	if err != nil {
+		return PermissionRequestResult{}, err
+	}
+	rule, err := matchPermissionRules(persisted, request)
+	if err != nil {
+		return PermissionRequestResult{}, err
+	}
+	if rule != nil {
+		return rule.Action.result(), nil
+	}
+
+	out := p.Out
+	if out == nil {
+		out = os.Stderr
+	}
+	always, canAlways := alwaysAllowRule(request)
+
+	fmt.Fprintf(out, "\nCopilot requests permission (%s):\n%s", sanitizeTerminal(request.Kind), describePermissionRequest(request))
+	for {
+		if canAlways {
+			fmt.Fprint(out, "Allow? [y]es, [n]o, [a]lways: ")
+		} else {
+			fmt.Fprint(out, "Allow? [y]es, [n]o: ")
+		}
+
+		var answer string
+		select {
+		case line, ok := <-p.readLines():
+			if !ok {
+				return PermissionRequestResult{Kind: "denied-no-approval-rule-and-could-not-request-from-user"}, nil
+			}
+			answer = strings.ToLower(strings.TrimSpace(line))
+		case <-ctx.Done():
+			fmt.Fprintln(out)
+			return PermissionRequestResult{Kind: "denied-no-approval-rule-and-could-not-request-from-user"}, nil
+		}
+
+		switch answer {
+		case "y", "yes":
+			return PermissionRequestResult{Kind: "approved"}, nil
+		case "n", "no":
+			return PermissionRequestResult{Kind: "denied-interactively-by-user"}, nil
+		case "a", "always":
+			if canAlways {
+				if err := policy.AlwaysAllow(always); err != nil {
+					return PermissionRequestResult{}, err
+				}
+				return PermissionRequestResult{Kind: "approved"}, nil
+			}
+		}
+	}
+}
+
+func (p *TerminalPermissionPrompter) policy() *PermissionPolicy {
+	if p.Policy != nil {
+		return p.Policy
+	}
+	return &p.session
+}
+
+// readLines starts a single reader goroutine so a prompt abandoned on ctx.Done
+// doesn't leave a competing read on In
+func (p *TerminalPermissionPrompter) readLines() <-chan string {
+	p.once.Do(func() {
+		in := p.In
+		if in == nil {
+			in = os.Stdin
+		}
+		p.lines = make(chan string)
+		go func() {
+			defer close(p.lines)
+			scanner := bufio.NewScanner(in)
+			for scanner.Scan() {
+				p.lines <- scanner.Text()
+			}
+		}()
+	})
+	return p.lines
+}
+
+// describePermissionRequest formats the details of request for display
+func describePermissionRequest(request PermissionRequest) string {
+	var b strings.Builder
+	// Continuation lines are indented so a value can't pass for a line of the prompt
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "  %s: %s\n", label, strings.TrimPrefix(indent(sanitizeTerminal(value), "    "), "    "))
+		}
+	}
+
+	if shell, ok := request.Shell(); ok {
+		line("Command", shell.FullCommandText)
+		line("Reason", shell.Intention)
+		line("Warning", shell.Warning)
+	} else if write, ok := request.Write(); ok {
+		line("File", write.FileName)
+		line("Reason", write.Intention)
+		if write.Diff != "" {
+			fmt.Fprintf(&b, "  Diff:\n%s\n", indent(sanitizeTerminal(write.Diff), "    "))
+		}
+	} else if read, ok := request.Read(); ok {
+		line("Path", read.Path)
+		line("Reason", read.Intention)
+	} else if mcp, ok := request.MCP(); ok {
+		line("Server", mcp.ServerName)
+		line("Tool", mcp.ToolName)
+		if mcp.Args != nil {
+			line("Arguments", fmt.Sprint(mcp.Args))
+		}
+	} else if url, ok := request.URL(); ok {
+		line("URL", url.URL)
+		line("Reason", url.Intention)
+	}
+	return b.String()
+}
+
+// alwaysAllowRule returns a rule approving requests identical to request, or false
+// if the request kind has no identifying field to match on. Names are escaped so the
+// rule can't match more than the one target.
+func alwaysAllowRule(request PermissionRequest) (PermissionRule, bool) {
+	rule := PermissionRule{Kinds: []string{request.Kind}, Action: PermissionAllow}
+	if shell, ok := request.Shell(); ok && shell.FullCommandText != "" {
+		rule.CommandPattern = "^" + regexp.QuoteMeta(shell.FullCommandText) + "$"
+		return rule, true
+	}
+	if write, ok := request.Write(); ok && write.FileName != "" {
+		return pathRule(rule, write.FileName)
+	}
+	if read, ok := request.Read(); ok && read.Path != "" {
+		return pathRule(rule, read.Path)
+	}
+	if mcp, ok := request.MCP(); ok && mcp.ServerName != "" && mcp.ToolName != "" {
+		rule.ServerName = escapeGlob(mcp.ServerName)
+		rule.ToolName = escapeGlob(mcp.ToolName)
+		return rule, true
+	}
+	return PermissionRule{}, false
+}
+
+// pathRule restricts rule to exactly the file at p, as it will be matched after cleaning
+func pathRule(rule PermissionRule, p string) (PermissionRule, bool) {
+	p, ok := cleanPermissionPath(p)
+	if !ok {
+		return PermissionRule{}, false
+	}
+	rule.PathGlob = escapeGlob(p)
+	return rule, true
+}
+
+// ansiEscape matches terminal escape sequences: CSI, OSC, and two-byte sequences
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)?|\x1b.?`)
+
+// sanitizeTerminal removes escape sequences, control characters other than newline
+// and tab, and bidirectional overrides from model-controlled text, so it can't move
+// the cursor, recolor, or rewrite the prompt it's shown in
+func sanitizeTerminal(s string) string {
+	s = ansiEscape.ReplaceAllString(s, "")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case unicode.IsControl(r),
+			r >= '\u202a' && r <= '\u202e',
+			r >= '\u2066' && r <= '\u2069':
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+prefix)
+}
@@ -0,0 +1,158 @@
+package copilot
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTerminalPermissionPrompter(t *testing.T) {
+	shell := PermissionRequest{Kind: "shell", Extra: map[string]any{"fullCommandText": "npm test", "intention": "Run the tests"}}
+
+	t.Run("should approve on y and print the request details", func(t *testing.T) {
+		var out bytes.Buffer
+		prompter := &TerminalPermissionPrompter{In: strings.NewReader("y\n"), Out: &out}
+
+		result, err := prompter.Handle(context.Background(), shell, PermissionInvocation{})
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+		if result.Kind != "approved" {
+			t.Errorf("Expected approved, got %q", result.Kind)
+		}
+		if !strings.Contains(out.String(), "npm test") || !strings.Contains(out.String(), "Run the tests") {
+			t.Errorf("Expected request details in output, got %q", out.String())
+		}
+	})
+
+	t.Run("should deny on n", func(t *testing.T) {
+		prompter := &TerminalPermissionPrompter{In: strings.NewReader("n\n"), Out: io.Discard}
+
+		result, _ := prompter.Handle(context.Background(), shell, PermissionInvocation{})
+		if result.Kind != "denied-interactively-by-user" {
+			t.Errorf("Expected denied-interactively-by-user, got %q", result.Kind)
+		}
+	})
+
+	t.Run("should re-prompt on unrecognized input", func(t *testing.T) {
+		var out bytes.Buffer
+		prompter := &TerminalPermissionPrompter{In: strings.NewReader("maybe\nyes\n"), Out: &out}
+
+		result, _ := prompter.Handle(context.Background(), shell, PermissionInvocation{})
+		if result.Kind != "approved" {
+			t.Errorf("Expected approved, got %q", result.Kind)
+		}
+		if n := strings.Count(out.String(), "Allow?"); n != 2 {
+			t.Errorf("Expected 2 prompts, got %d", n)
+		}
+	})
+
+	t.Run("should remember always answers without prompting again", func(t *testing.T) {
+		policy := &PermissionPolicy{}
+		prompter := &TerminalPermissionPrompter{In: strings.NewReader("a\n"), Out: io.Discard, Policy: policy}
+
+		if result, _ := prompter.Handle(context.Background(), shell, PermissionInvocation{}); result.Kind != "approved" {
+			t.Fatalf("Expected approved, got %q", result.Kind)
+		}
+		if result, _ := prompter.Handle(context.Background(), shell, PermissionInvocation{}); result.Kind != "approved" {
+			t.Errorf("Expected remembered approval, got %q", result.Kind)
+		}
+
+		other := PermissionRequest{Kind: "shell", Extra: map[string]any{"fullCommandText": "npm test && rm -rf /"}}
+		if result, _ := prompter.Handle(context.Background(), other, PermissionInvocation{}); result.Kind == "approved" {
+			t.Error("Expected the always rule to match only the exact command")
+		}
+		if len(policy.persisted) != 1 {
+			t.Errorf("Expected the rule to be recorded on the policy, got %v", policy.persisted)
+		}
+	})
+
+	t.Run("should scope always rules to the exact file and MCP server", func(t *testing.T) {
+		policy := &PermissionPolicy{}
+		prompter := &TerminalPermissionPrompter{In: strings.NewReader("a\na\n"), Out: io.Discard, Policy: policy}
+
+		write := func(name string) PermissionRequest {
+			return PermissionRequest{Kind: "write", Extra: map[string]any{"fileName": name}}
+		}
+		mcp := func(server, tool string) PermissionRequest {
+			return PermissionRequest{Kind: "mcp", Extra: map[string]any{"serverName": server, "toolName": tool}}
+		}
+
+		prompter.Handle(context.Background(), write("/repo/*.go"), PermissionInvocation{})
+		prompter.Handle(context.Background(), mcp("github", "search_*"), PermissionInvocation{})
+
+		for _, request := range []PermissionRequest{write("/repo/main.go"), mcp("github", "search_code"), mcp("evil", "search_*")} {
+			if matched, _ := matchPermissionRules(policy.persisted, request); matched != nil {
+				t.Errorf("Expected %v not to be approved by %+v", request.Extra, matched)
+			}
+		}
+		for _, request := range []PermissionRequest{write("/repo/*.go"), mcp("github", "search_*")} {
+			if matched, _ := matchPermissionRules(policy.persisted, request); matched == nil {
+				t.Errorf("Expected %v to be approved", request.Extra)
+			}
+		}
+	})
+
+	t.Run("should strip terminal escape sequences from request details", func(t *testing.T) {
+		var out bytes.Buffer
+		prompter := &TerminalPermissionPrompter{In: strings.NewReader("n\n"), Out: &out}
+
+		request := PermissionRequest{Kind: "shell", Extra: map[string]any{
+			"fullCommandText": "rm -rf ~\x1b[2K\r\x1b[1Aecho hi\x1b]0;title\x07\u202e",
+			"intention":       "Harmless\nAllow? [y]es",
+		}}
+		prompter.Handle(context.Background(), request, PermissionInvocation{})
+
+		printed := out.String()
+		if strings.ContainsAny(printed, "\x1b\r\a\u202e") {
+			t.Errorf("Expected control characters to be stripped, got %q", printed)
+		}
+		if !strings.Contains(printed, "rm -rf ~echo hi") {
+			t.Errorf("Expected the visible command text, got %q", printed)
+		}
+		if !strings.Contains(printed, "Harmless\n    Allow? [y]es") {
+			t.Errorf("Expected continuation lines to be indented, got %q", printed)
+		}
+	})
+
+	t.Run("should not offer always for url requests", func(t *testing.T) {
+		var out bytes.Buffer
+		prompter := &TerminalPermissionPrompter{In: strings.NewReader("a\nn\n"), Out: &out}
+
+		request := PermissionRequest{Kind: "url", Extra: map[string]any{"url": "https://example.com"}}
+		result, _ := prompter.Handle(context.Background(), request, PermissionInvocation{})
+		if result.Kind != "denied-interactively-by-user" {
+			t.Errorf("Expected denied-interactively-by-user, got %q", result.Kind)
+		}
+		if strings.Contains(out.String(), "[a]lways") {
+			t.Errorf("Expected no always option, got %q", out.String())
+		}
+	})
+
+	t.Run("should deny when the context is done before an answer", func(t *testing.T) {
+		in, _ := io.Pipe()
+		prompter := &TerminalPermissionPrompter{In: in, Out: io.Discard}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		result, err := prompter.Handle(ctx, shell, PermissionInvocation{})
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+		if result.Kind != "denied-no-approval-rule-and-could-not-request-from-user" {
+			t.Errorf("Expected denial, got %q", result.Kind)
+		}
+	})
+
+	t.Run("should deny when input is closed", func(t *testing.T) {
+		prompter := &TerminalPermissionPrompter{In: strings.NewReader(""), Out: io.Discard}
+
+		result, _ := prompter.Handle(context.Background(), shell, PermissionInvocation{})
+		if result.Kind != "denied-no-approval-rule-and-could-not-request-from-user" {
+			t.Errorf("Expected denial, got %q", result.Kind)
+		}
+	})
+}
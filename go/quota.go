@@ -0,0 +1,96 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// WatchQuota polls the account quota until ctx is done, calling options.OnThreshold
+// each time a quota's remaining percentage drops to or below one of options.Thresholds.
+//
+// Each threshold fires once per crossing; it is re-armed if the quota later rises
+// above it again (for example after the monthly reset). Thresholds already crossed
+// when watching starts are reported on the first poll. Unlimited quotas are ignored.
+//
+// A failed poll is passed to options.OnError, or logged to the client's Logger if
+// OnError is nil, and polling continues. WatchQuota blocks, so it is typically run in
+// its own goroutine. It returns ctx.Err() when ctx is done, or an error matching
+// [ErrNotConnected] or [ErrUnsupported] when polling can't succeed.
+//
+// Example:
+//
+//	go client.WatchQuota(ctx, copilot.QuotaWatchOptions{
+//	    Thresholds: []float64{50, 20, 5},
+//	    OnThreshold: func(event copilot.QuotaThresholdEvent) {
+//	        log.Printf("%s quota below %.0f%%", event.QuotaType, event.Threshold)
+//	    },
+//	})
+func (c *Client) WatchQuota(ctx context.Context, options QuotaWatchOptions) error {
+	interval := options.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Last observed remaining percentage per quota type
+	previous := make(map[string]float64)
+	for {
+		snapshots, err := c.GetQuota(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, ErrNotConnected) || errors.Is(err, ErrUnsupported):
+			return err
+		case err != nil && options.OnError != nil:
+			options.OnError(err)
+		case err != nil:
+			c.logger.Warn("failed to poll quota", "error", err)
+		}
+
+		for quotaType, snapshot := range snapshots {
+			if snapshot.IsUnlimitedEntitlement {
+				continue
+			}
+			last, seen := previous[quotaType]
+			if !seen {
+				last = math.Inf(1)
+			}
+			for _, threshold := range crossedQuotaThresholds(last, snapshot.RemainingPercentage, options.Thresholds) {
+				if options.OnThreshold != nil {
+					options.OnThreshold(QuotaThresholdEvent{
+						QuotaType: quotaType,
+						Threshold: threshold,
+						Snapshot:  snapshot,
+					})
+				}
+			}
+			previous[quotaType] = snapshot.RemainingPercentage
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// crossedQuotaThresholds returns the thresholds crossed when the remaining
+// percentage fell from previous to current.
+func crossedQuotaThresholds(previous, current float64, thresholds []float64) []float64 {
+	var crossed []float64
+	for _, threshold := range thresholds {
+		if current <= threshold && previous > threshold {
+			crossed = append(crossed, threshold)
+		}
+	}
+	return crossed
+}
@@ -0,0 +1,160 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCrossedQuotaThresholds(t *testing.T) {
+	thresholds := []float64{50, 20, 5}
+
+	tests := []struct {
+		name     string
+		previous float64
+		current  float64
+		want     []float64
+	}{
+		{"first poll reports thresholds already crossed", math.Inf(1), 18, []float64{50, 20}},
+		{"no crossing above all thresholds", 90, 60, nil},
+		{"single crossing", 60, 45, []float64{50}},
+		{"landing exactly on a threshold", 25, 20, []float64{20}},
+		{"already below does not re-fire", 45, 30, nil},
+		{"quota reset re-arms thresholds", 3, 100, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := crossedQuotaThresholds(tt.previous, tt.current, thresholds)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_GetQuota(t *testing.T) {
+	t.Run("reports a server without account.getQuota as ErrUnsupported", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32601, Message: "Method not found"}
+		})
+
+		client := NewClient(&ClientOptions{Conn: clientConn})
+		t.Cleanup(func() { client.ForceStop() })
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+
+		if _, err := client.GetQuota(t.Context()); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected ErrUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestClient_WatchQuota(t *testing.T) {
+	t.Run("notifies when remaining percentage crosses thresholds", func(t *testing.T) {
+		remaining := []float64{80, 40, 35, 10}
+		var polls atomic.Int32
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			i := int(polls.Add(1)) - 1
+			if i >= len(remaining) {
+				i = len(remaining) - 1
+			}
+			return map[string]any{"quotaSnapshots": map[string]any{
+				"premium_interactions": map[string]any{"remainingPercentage": remaining[i]},
+				"chat":                 map[string]any{"isUnlimitedEntitlement": true},
+			}}
+		})
+
+		client := NewClient(&ClientOptions{Conn: clientConn})
+		t.Cleanup(func() { client.ForceStop() })
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		var events []QuotaThresholdEvent
+		err := client.WatchQuota(ctx, QuotaWatchOptions{
+			Interval:   time.Millisecond,
+			Thresholds: []float64{50, 20},
+			OnThreshold: func(event QuotaThresholdEvent) {
+				events = append(events, event)
+				if len(events) == 2 {
+					cancel()
+				}
+			},
+		})
+		if err != context.Canceled {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+
+		if len(events) != 2 {
+			t.Fatalf("Expected 2 threshold events, got %d", len(events))
+		}
+		if events[0].Threshold != 50 || events[0].Snapshot.RemainingPercentage != 40 {
+			t.Errorf("Expected 50%% threshold crossed at 40%%, got %+v", events[0])
+		}
+		if events[1].Threshold != 20 || events[1].QuotaType != "premium_interactions" {
+			t.Errorf("Expected 20%% threshold for premium_interactions, got %+v", events[1])
+		}
+	})
+
+	t.Run("reports failed polls and keeps polling", func(t *testing.T) {
+		var polls atomic.Int32
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			if polls.Add(1) == 1 {
+				return &fakeCLIError{Code: -32000, Message: "quota service unavailable"}
+			}
+			return map[string]any{"quotaSnapshots": map[string]any{
+				"premium_interactions": map[string]any{"remainingPercentage": 10},
+			}}
+		})
+
+		client := NewClient(&ClientOptions{Conn: clientConn})
+		t.Cleanup(func() { client.ForceStop() })
+		if err := client.Start(t.Context()); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		var errs []error
+		var events []QuotaThresholdEvent
+		err := client.WatchQuota(ctx, QuotaWatchOptions{
+			Interval:   time.Millisecond,
+			Thresholds: []float64{20},
+			OnError:    func(err error) { errs = append(errs, err) },
+			OnThreshold: func(event QuotaThresholdEvent) {
+				events = append(events, event)
+				cancel()
+			},
+		})
+		if err != context.Canceled {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+		if len(errs) != 1 || len(events) != 1 {
+			t.Errorf("Expected 1 error then 1 threshold event, got %v and %+v", errs, events)
+		}
+	})
+
+	t.Run("returns ErrNotConnected when the client is not connected", func(t *testing.T) {
+		client := NewClient(&ClientOptions{AutoStart: Bool(false)})
+
+		err := client.WatchQuota(t.Context(), QuotaWatchOptions{Interval: time.Millisecond})
+		if !errors.Is(err, ErrNotConnected) {
+			t.Errorf("Expected ErrNotConnected, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
+)
+
+// RequestHandler answers a request or notification from the CLI server for a method
+// registered with [Client.HandleRequest]. Its result is sent as JSON; the result of a
+// notification is discarded. Returning an [*RPCError] sends its code, message, and
+// data; any other error is sent as an internal error.
+type RequestHandler func(ctx context.Context, params json.RawMessage) (any, error)
+
+// builtinMethods are the server-to-client methods the SDK handles itself
+var builtinMethods = map[string]bool{
+	"session.event":      true,
+	"session.lifecycle":  true,
+	"tool.call":          true,
+	"permission.request": true,
+	"userInput.request":  true,
+	"hooks.invoke":       true,
+}
+
+// HandleRequest registers handler for requests and notifications the CLI server sends
+// for method, so applications can serve methods the SDK doesn't support yet. Passing
+// a nil handler removes it. Handlers may be registered before or after [Client.Start]
+// and are kept across restarts.
+//
+// Returns an error for methods the SDK handles itself.
+//
+// Example:
+//
+//	client.HandleRequest("workspace.openFile", func(ctx context.Context, params json.RawMessage) (any, error) {
+//	    var req struct{ Path string `json:"path"` }
+//	    if err := json.Unmarshal(params, &req); err != nil {
+//	        return nil, &copilot.RPCError{Code: -32602, Message: err.Error()}
+//	    }
+//	    return map[string]bool{"opened": editor.Open(req.Path)}, nil
+//	})
+func (c *Client) HandleRequest(method string, handler RequestHandler) error {
+	if method == "" {
+		return fmt.Errorf("method is required")
+	}
+	if builtinMethods[method] {
+		return fmt.Errorf("method %q is handled by the SDK", method)
+	}
+
+	c.requestHandlersMux.Lock()
+	defer c.requestHandlersMux.Unlock()
+	if handler == nil {
+		delete(c.requestHandlers, method)
+	} else {
+		if c.requestHandlers == nil {
+			c.requestHandlers = make(map[string]RequestHandler)
+		}
+		c.requestHandlers[method] = handler
+	}
+	if c.client != nil {
+		c.client.SetRequestHandler(method, adaptRequestHandler(handler))
+	}
+	return nil
+}
+
+// Raw calls method on the CLI server with params and returns the raw JSON result,
+// for methods the SDK doesn't have typed wrappers for yet. Server errors are
+// returned as [*RPCError].
+//
+// Example:
+//
+//	result, err := client.Raw(ctx, "session.getTitle", map[string]string{"sessionId": session.SessionID})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	var title struct{ Title string `json:"title"` }
+//	json.Unmarshal(result, &title)
+func (c *Client) Raw(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	if method == "" {
+		return nil, fmt.Errorf("method is required")
+	}
+	if err := c.ensureConnected(); err != nil {
+		return nil, err
+	}
+	return c.request(ctx, method, params)
+}
+
+// setupCustomHandlers registers the handlers added with HandleRequest on a new connection
+func (c *Client) setupCustomHandlers() {
+	c.requestHandlersMux.Lock()
+	defer c.requestHandlersMux.Unlock()
+	for method, handler := range c.requestHandlers {
+		c.client.SetRequestHandler(method, adaptRequestHandler(handler))
+	}
+}
+
+// adaptRequestHandler converts a RequestHandler to a jsonrpc2 handler; nil stays nil
+func adaptRequestHandler(handler RequestHandler) jsonrpc2.RequestHandler {
+	if handler == nil {
+		return nil
+	}
+	return func(ctx context.Context, params json.RawMessage) (json.RawMessage, *jsonrpc2.Error) {
+		result, err := handler(ctx, params)
+		if err != nil {
+			var rpcErr *RPCError
+			if errors.As(err, &rpcErr) {
+				return nil, &jsonrpc2.Error{Code: rpcErr.Code, Message: rpcErr.Message, Data: rpcErr.Data}
+			}
+			return nil, &jsonrpc2.Error{Code: -32603, Message: err.Error()}
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, &jsonrpc2.Error{Code: -32603, Message: fmt.Sprintf("failed to marshal result: %v", err)}
+		}
+		return data, nil
+	}
+}
@@ -0,0 +1,148 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
+)
+
+func TestClient_HandleRequest(t *testing.T) {
+	// newPeer connects a client to a JSON-RPC peer that plays the CLI server
+	newPeer := func(t *testing.T) (*Client, *jsonrpc2.Client) {
+		clientConn, serverConn := net.Pipe()
+		rpc := jsonrpc2.NewClient(clientConn, clientConn)
+		rpc.Start()
+		t.Cleanup(rpc.Stop)
+		peer := jsonrpc2.NewClient(serverConn, serverConn)
+		peer.Start()
+		t.Cleanup(peer.Stop)
+		return &Client{client: rpc}, peer
+	}
+
+	t.Run("answers server requests for custom methods", func(t *testing.T) {
+		client, peer := newPeer(t)
+		err := client.HandleRequest("workspace.openFile", func(ctx context.Context, params json.RawMessage) (any, error) {
+			var req struct {
+				Path string `json:"path"`
+			}
+			if err := json.Unmarshal(params, &req); err != nil {
+				return nil, err
+			}
+			return map[string]string{"opened": req.Path}, nil
+		})
+		if err != nil {
+			t.Fatalf("HandleRequest failed: %v", err)
+		}
+
+		result, err := peer.Request(t.Context(), "workspace.openFile", map[string]string{"path": "main.go"})
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		if string(result) != `{"opened":"main.go"}` {
+			t.Errorf("Unexpected result %s", result)
+		}
+	})
+
+	t.Run("sends RPCError codes and other errors as internal errors", func(t *testing.T) {
+		client, peer := newPeer(t)
+		client.HandleRequest("custom.invalid", func(ctx context.Context, params json.RawMessage) (any, error) {
+			return nil, &RPCError{Code: -32602, Message: "bad path", Data: map[string]any{"path": "x"}}
+		})
+		client.HandleRequest("custom.failing", func(ctx context.Context, params json.RawMessage) (any, error) {
+			return nil, errors.New("boom")
+		})
+
+		_, err := peer.Request(t.Context(), "custom.invalid", nil)
+		var rpcErr *jsonrpc2.Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != -32602 || rpcErr.Message != "bad path" || rpcErr.Data["path"] != "x" {
+			t.Errorf("Expected the handler's RPC error, got %v", err)
+		}
+		_, err = peer.Request(t.Context(), "custom.failing", nil)
+		if !errors.As(err, &rpcErr) || rpcErr.Code != -32603 || rpcErr.Message != "boom" {
+			t.Errorf("Expected an internal error, got %v", err)
+		}
+	})
+
+	t.Run("registers handlers added before the connection", func(t *testing.T) {
+		client := &Client{}
+		client.HandleRequest("custom.echo", func(ctx context.Context, params json.RawMessage) (any, error) {
+			return params, nil
+		})
+
+		clientConn, serverConn := net.Pipe()
+		client.client = jsonrpc2.NewClient(clientConn, clientConn)
+		client.setupCustomHandlers()
+		client.client.Start()
+		t.Cleanup(client.client.Stop)
+		peer := jsonrpc2.NewClient(serverConn, serverConn)
+		peer.Start()
+		t.Cleanup(peer.Stop)
+
+		result, err := peer.Request(t.Context(), "custom.echo", []int{1, 2})
+		if err != nil || string(result) != "[1,2]" {
+			t.Errorf("Expected echoed params, got %s, %v", result, err)
+		}
+	})
+
+	t.Run("rejects methods the SDK handles", func(t *testing.T) {
+		client := &Client{}
+		err := client.HandleRequest("tool.call", func(ctx context.Context, params json.RawMessage) (any, error) {
+			return nil, nil
+		})
+		if err == nil {
+			t.Error("Expected an error for a built-in method")
+		}
+	})
+}
+
+func TestClient_Raw(t *testing.T) {
+	t.Run("returns the raw result of the call", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			if method != "custom.method" || string(params) != `{"x":1}` {
+				return &fakeCLIError{Code: -32601, Message: "unexpected " + method + " " + string(params)}
+			}
+			return map[string]any{"ok": true}
+		})
+		rpc := jsonrpc2.NewClient(clientConn, clientConn)
+		rpc.Start()
+		t.Cleanup(rpc.Stop)
+		client := &Client{client: rpc}
+
+		result, err := client.Raw(t.Context(), "custom.method", map[string]int{"x": 1})
+		if err != nil {
+			t.Fatalf("Raw failed: %v", err)
+		}
+		if string(result) != `{"ok":true}` {
+			t.Errorf("Unexpected result %s", result)
+		}
+	})
+
+	t.Run("returns server errors as RPCError", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go serveFakeCLI(serverConn, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32601, Message: "Method not found"}
+		})
+		rpc := jsonrpc2.NewClient(clientConn, clientConn)
+		rpc.Start()
+		t.Cleanup(rpc.Stop)
+		client := &Client{client: rpc}
+
+		_, err := client.Raw(t.Context(), "custom.missing", nil)
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+			t.Errorf("Expected an RPCError, got %v", err)
+		}
+	})
+
+	t.Run("returns ErrNotConnected without auto-start", func(t *testing.T) {
+		client := NewClient(&ClientOptions{AutoStart: Bool(false)})
+		if _, err := client.Raw(t.Context(), "custom.method", nil); !errors.Is(err, ErrNotConnected) {
+			t.Errorf("Expected ErrNotConnected, got %v", err)
+		}
+	})
+}
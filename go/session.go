@@ -4,8 +4,17 @@ package copilot
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
@@ -49,20 +58,50 @@ type sessionHandler struct {
 //	})
 type Session struct {
 	// SessionID is the unique identifier for this session.
-	SessionID         string
-	workspacePath     string
-	client            *jsonrpc2.Client
-	handlers          []sessionHandler
-	nextHandlerID     uint64
-	handlerMutex      sync.RWMutex
-	toolHandlers      map[string]ToolHandler
-	toolHandlersM     sync.RWMutex
-	permissionHandler PermissionHandler
-	permissionMux     sync.RWMutex
-	userInputHandler  UserInputHandler
-	userInputMux      sync.RWMutex
-	hooks             *SessionHooks
-	hooksMux          sync.RWMutex
+	SessionID           string
+	workspacePath       string
+	client              *jsonrpc2.Client
+	handlers            []sessionHandler
+	nextHandlerID       uint64
+	handlerMutex        sync.RWMutex
+	toolHandlers        map[string]ToolHandler
+	toolHandlersM       sync.RWMutex
+	toolSlots           map[string]chan struct{}
+	tools               []Tool
+	toolsUpdateMux      sync.Mutex
+	toolCtx             context.Context
+	toolCancel          context.CancelFunc
+	toolCtxMux          sync.Mutex
+	permissionHandler   PermissionHandler
+	permissionTimeout   time.Duration
+	permissionOnTimeout PermissionAction
+	permissionMux       sync.RWMutex
+	userInputHandler    UserInputHandler
+	userInputMux        sync.RWMutex
+	hooks               *SessionHooks
+	hooksMux            sync.RWMutex
+	hooksUpdateMux      sync.Mutex
+	usage               SessionUsage
+	usageMux            sync.Mutex
+	replaying           atomic.Bool
+	replayBuffer        []SessionEvent
+	replayMux           sync.Mutex
+	eventQueue          *eventQueue
+	onPanic             PanicHandler
+	repanic             bool
+	destroyed           atomic.Bool
+	metrics             Metrics
+	logger              *slog.Logger // nil means slog.Default()
+}
+
+// request sends a session-scoped request to the server. It fails with
+// ErrSessionDestroyed once the session has been destroyed.
+func (s *Session) request(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	if s.destroyed.Load() {
+		return nil, ErrSessionDestroyed
+	}
+	result, err := s.client.Request(ctx, method, params)
+	return result, wrapRPCError(err)
 }
 
 // WorkspacePath returns the path to the session workspace directory when infinite
@@ -74,12 +113,16 @@ func (s *Session) WorkspacePath() string {
 
 // newSession creates a new session wrapper with the given session ID and client.
 func newSession(sessionID string, client *jsonrpc2.Client, workspacePath string) *Session {
+	toolCtx, toolCancel := context.WithCancel(context.Background())
 	return &Session{
+		toolCtx:       toolCtx,
+		toolCancel:    toolCancel,
 		SessionID:     sessionID,
 		workspacePath: workspacePath,
 		client:        client,
 		handlers:      make([]sessionHandler, 0),
 		toolHandlers:  make(map[string]ToolHandler),
+		usage:         SessionUsage{ByModel: make(map[string]UsageTotals)},
 	}
 }
 
@@ -88,6 +131,11 @@ func newSession(sessionID string, client *jsonrpc2.Client, workspacePath string)
 // The message is processed asynchronously. Subscribe to events via [Session.On]
 // to receive streaming responses and other session events.
 //
+// If ctx is done before the server acknowledges the message, Send stops waiting
+// and returns ctx.Err(). When options.AbortOnCancel is set, cancelling ctx also
+// aborts the agent turn, both while sending and after Send returns, until the
+// session next becomes idle.
+//
 // Parameters:
 //   - options: The message options including the prompt and optional attachments.
 //
@@ -112,9 +160,46 @@ func (s *Session) Send(ctx context.Context, options MessageOptions) (string, err
 		Attachments: options.Attachments,
 		Mode:        options.Mode,
 	}
+	if len(options.Content) > 0 {
+		content, prompt, err := buildMessageContent(options.Prompt, options.Content)
+		if err != nil {
+			return "", err
+		}
+		req.Content = content
+		req.Prompt = prompt
+	}
 
-	result, err := s.client.Request("session.send", req)
+	var endTurn func()
+	if options.AbortOnCancel && ctx.Done() != nil {
+		// Subscribe before sending so the end of the turn can't be missed
+		turnDone := make(chan struct{})
+		var once sync.Once
+		endTurn = func() { once.Do(func() { close(turnDone) }) }
+		unsubscribe := s.On(func(event SessionEvent) {
+			if event.Type == SessionIdle || event.Type == SessionError {
+				endTurn()
+			}
+		})
+		go func() {
+			defer unsubscribe()
+			select {
+			case <-turnDone:
+			case <-ctx.Done():
+				select {
+				case <-turnDone: // Both were ready; the turn already ended
+				default:
+					s.Abort(context.Background()) // Ignore errors; the session may already be idle
+				}
+			}
+		}()
+	}
+
+	result, err := s.request(ctx, "session.send", req)
 	if err != nil {
+		if endTurn != nil && ctx.Err() == nil {
+			// The message was not sent, so there is no turn to abort
+			endTurn()
+		}
 		return "", fmt.Errorf("failed to send message: %w", err)
 	}
 
@@ -125,6 +210,29 @@ func (s *Session) Send(ctx context.Context, options MessageOptions) (string, err
 	return response.MessageID, nil
 }
 
+// buildMessageContent combines a prompt and content blocks into the blocks sent to the
+// server, along with the concatenated text for servers that only read the prompt.
+func buildMessageContent(prompt string, blocks []ContentBlock) ([]ContentBlock, string, error) {
+	content := make([]ContentBlock, 0, len(blocks)+1)
+	if prompt != "" {
+		content = append(content, TextBlock(prompt))
+	}
+	for i, block := range blocks {
+		if err := block.validate(); err != nil {
+			return nil, "", fmt.Errorf("invalid content block %d: %w", i, err)
+		}
+		content = append(content, block)
+	}
+
+	var text []string
+	for _, block := range content {
+		if block.Type == ContentBlockText {
+			text = append(text, block.Text)
+		}
+	}
+	return content, strings.Join(text, "\n\n"), nil
+}
+
 // SendAndWait sends a message to this session and waits until the session becomes idle.
 //
 // This is a convenience method that combines [Session.Send] with waiting for
@@ -140,6 +248,7 @@ func (s *Session) Send(ctx context.Context, options MessageOptions) (string, err
 //
 // Returns the final assistant message event, or nil if none was received.
 // Returns an error if the timeout is reached or the connection fails.
+// Use [Session.RunTurn] to also get the turn's tool calls, reasoning, and usage.
 //
 // Example:
 //
@@ -153,27 +262,52 @@ func (s *Session) Send(ctx context.Context, options MessageOptions) (string, err
 //	    fmt.Println(*response.Data.Content)
 //	}
 func (s *Session) SendAndWait(ctx context.Context, options MessageOptions) (*SessionEvent, error) {
-	if _, ok := ctx.Deadline(); !ok {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, 60*time.Second)
-		defer cancel()
+	result, err := s.RunTurn(ctx, options)
+	if err != nil {
+		return nil, err
 	}
+	return result.Message, nil
+}
 
-	idleCh := make(chan struct{}, 1)
-	errCh := make(chan error, 1)
-	var lastAssistantMessage *SessionEvent
-	var mu sync.Mutex
+// WaitForIdle blocks until the session's next session.idle event, which marks the end
+// of the current turn. It returns an error if a session.error event arrives first or
+// ctx is done. Unlike [Session.SendAndWait], it applies no default timeout.
+//
+// Call it after [Session.Send] when handling events yourself. Because it waits for the
+// next idle event, a turn that completes before WaitForIdle is called is not observed.
+//
+// Example:
+//
+//	session.Send(ctx, copilot.MessageOptions{Prompt: "Refactor main.go"})
+//	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+//	defer cancel()
+//	if err := session.WaitForIdle(ctx); err != nil {
+//	    log.Printf("Turn did not complete: %v", err)
+//	}
+func (s *Session) WaitForIdle(ctx context.Context) error {
+	idle := s.newIdleWaiter()
+	defer idle.stop()
+	return idle.wait(ctx)
+}
 
-	unsubscribe := s.On(func(event SessionEvent) {
+// idleWaiter watches a session for the end of a turn: its next session.idle or
+// session.error event
+type idleWaiter struct {
+	idleCh chan struct{}
+	errCh  chan error
+	stop   func()
+}
+
+func (s *Session) newIdleWaiter() *idleWaiter {
+	w := &idleWaiter{
+		idleCh: make(chan struct{}, 1),
+		errCh:  make(chan error, 1),
+	}
+	w.stop = s.On(func(event SessionEvent) {
 		switch event.Type {
-		case AssistantMessage:
-			mu.Lock()
-			eventCopy := event
-			lastAssistantMessage = &eventCopy
-			mu.Unlock()
 		case SessionIdle:
 			select {
-			case idleCh <- struct{}{}:
+			case w.idleCh <- struct{}{}:
 			default:
 			}
 		case SessionError:
@@ -182,29 +316,148 @@ func (s *Session) SendAndWait(ctx context.Context, options MessageOptions) (*Ses
 				errMsg = *event.Data.Message
 			}
 			select {
-			case errCh <- fmt.Errorf("session error: %s", errMsg):
+			case w.errCh <- fmt.Errorf("session error: %s", errMsg):
 			default:
 			}
 		}
 	})
-	defer unsubscribe()
+	return w
+}
 
-	_, err := s.Send(ctx, options)
-	if err != nil {
-		return nil, err
+func (w *idleWaiter) wait(ctx context.Context) error {
+	select {
+	case <-w.idleCh:
+		return nil
+	case err := <-w.errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for session.idle: %w", ctx.Err())
 	}
+}
 
-	select {
-	case <-idleCh:
+// streamChunkBuffer is the channel capacity used for [MessageStream.Chunks].
+const streamChunkBuffer = 64
+
+// MessageStream is the streamed output of a message sent with [Session.SendAndStream].
+type MessageStream struct {
+	chunks chan StreamChunk
+	done   chan struct{}
+	result *SessionEvent
+	err    error
+}
+
+// Chunks returns a channel of assistant message and reasoning deltas, closed when the
+// turn completes, fails, or the context passed to SendAndStream is done.
+func (m *MessageStream) Chunks() <-chan StreamChunk {
+	return m.chunks
+}
+
+// Result waits for the turn to complete and returns the final assistant message,
+// or nil if none was received. Any chunks not yet read are discarded.
+func (m *MessageStream) Result() (*SessionEvent, error) {
+	for range m.chunks {
+	}
+	<-m.done
+	return m.result, m.err
+}
+
+// SendAndStream sends a message and returns a stream of the assistant's response.
+//
+// Deltas are only produced when the session was created with Streaming enabled;
+// otherwise the stream carries no chunks and only the final result.
+// Delivery blocks while the chunk buffer is full, so consumers should either range
+// over [MessageStream.Chunks] or call [MessageStream.Result].
+//
+// Example:
+//
+//	stream, err := session.SendAndStream(ctx, copilot.MessageOptions{Prompt: "Tell me a story"})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for chunk := range stream.Chunks() {
+//	    if !chunk.Reasoning {
+//	        fmt.Print(chunk.Content)
+//	    }
+//	}
+//	final, err := stream.Result()
+func (s *Session) SendAndStream(ctx context.Context, options MessageOptions) (*MessageStream, error) {
+	stream := &MessageStream{
+		chunks: make(chan StreamChunk, streamChunkBuffer),
+		done:   make(chan struct{}),
+	}
+
+	// mu guards closing the stream against in-flight deliveries
+	var mu sync.Mutex
+	closed := false
+	var lastAssistantMessage *SessionEvent
+	var unsubscribe func()
+
+	finish := func(err error) {
 		mu.Lock()
-		result := lastAssistantMessage
-		mu.Unlock()
-		return result, nil
-	case err := <-errCh:
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		closed = true
+		stream.result = lastAssistantMessage
+		stream.err = err
+		close(stream.chunks)
+		close(stream.done)
+	}
+
+	unsubscribe = s.On(func(event SessionEvent) {
+		switch event.Type {
+		case AssistantMessageDelta, AssistantReasoningDelta:
+			if event.Data.DeltaContent == nil {
+				return
+			}
+			chunk := StreamChunk{
+				Content:   *event.Data.DeltaContent,
+				Reasoning: event.Type == AssistantReasoningDelta,
+			}
+			if event.Data.MessageID != nil {
+				chunk.MessageID = *event.Data.MessageID
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case stream.chunks <- chunk:
+			case <-ctx.Done():
+			}
+		case AssistantMessage:
+			mu.Lock()
+			eventCopy := event
+			lastAssistantMessage = &eventCopy
+			mu.Unlock()
+		case SessionIdle:
+			finish(nil)
+		case SessionError:
+			errMsg := "session error"
+			if event.Data.Message != nil {
+				errMsg = *event.Data.Message
+			}
+			finish(fmt.Errorf("session error: %s", errMsg))
+		}
+	})
+
+	go func() {
+		select {
+		case <-stream.done:
+		case <-ctx.Done():
+			finish(fmt.Errorf("waiting for session.idle: %w", ctx.Err()))
+		}
+		unsubscribe()
+	}()
+
+	if _, err := s.Send(ctx, options); err != nil {
+		finish(err)
 		return nil, err
-	case <-ctx.Done(): // TODO: remove once session.Send honors the context
-		return nil, fmt.Errorf("waiting for session.idle: %w", ctx.Err())
 	}
+
+	return stream, nil
 }
 
 // On subscribes to events from this session.
@@ -251,6 +504,105 @@ func (s *Session) On(handler SessionEventHandler) func() {
 	}
 }
 
+// OnEventType subscribes to events of a single type from this session.
+//
+// Handlers registered with OnEventType are called in registration order together
+// with handlers registered via [Session.On].
+//
+// Returns a function that, when called, unsubscribes the handler.
+//
+// Example:
+//
+//	unsubscribe := session.OnEventType(copilot.ToolExecutionStart, func(event copilot.SessionEvent) {
+//	    fmt.Println("Running tool:", *event.Data.ToolName)
+//	})
+//	defer unsubscribe()
+func (s *Session) OnEventType(eventType SessionEventType, handler SessionEventHandler) func() {
+	return s.On(func(event SessionEvent) {
+		if event.Type == eventType {
+			handler(event)
+		}
+	})
+}
+
+// Events returns a channel that receives all events from this session until ctx is done,
+// at which point the channel is closed.
+//
+// buffer sets the channel capacity. When the buffer is full, event delivery blocks
+// until the consumer catches up or ctx is done, so consumers should drain the channel
+// promptly.
+//
+// Example:
+//
+//	ctx, cancel := context.WithCancel(context.Background())
+//	defer cancel()
+//	for event := range session.Events(ctx, 64) {
+//	    if event.Type == copilot.SessionIdle {
+//	        break
+//	    }
+//	    fmt.Println(event.Type)
+//	}
+func (s *Session) Events(ctx context.Context, buffer int) <-chan SessionEvent {
+	ch := make(chan SessionEvent, buffer)
+
+	// mu guards closing ch against in-flight deliveries, since a handler may still be
+	// running after it has been unsubscribed
+	var mu sync.Mutex
+	closed := false
+
+	unsubscribe := s.On(func(event SessionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- event:
+		case <-ctx.Done():
+		}
+	})
+
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+		mu.Lock()
+		closed = true
+		close(ch)
+		mu.Unlock()
+	}()
+
+	return ch
+}
+
+// eventSeqBuffer is the channel capacity used by [Session.EventSeq].
+const eventSeqBuffer = 64
+
+// EventSeq returns an iterator over events from this session, for use with range-over-func.
+//
+// The subscription starts when iteration begins and ends when the loop exits or ctx
+// is done, so no explicit unsubscribe is needed.
+//
+// Example:
+//
+//	for event := range session.EventSeq(ctx) {
+//	    if event.Type == copilot.SessionIdle {
+//	        break
+//	    }
+//	    fmt.Println(event.Type)
+//	}
+func (s *Session) EventSeq(ctx context.Context) iter.Seq[SessionEvent] {
+	return func(yield func(SessionEvent) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		for event := range s.Events(ctx, eventSeqBuffer) {
+			if !yield(event) {
+				return
+			}
+		}
+	}
+}
+
 // registerTools registers tool handlers for this session.
 //
 // Tools allow the assistant to execute custom functions. When the assistant
@@ -261,12 +613,249 @@ func (s *Session) registerTools(tools []Tool) {
 	s.toolHandlersM.Lock()
 	defer s.toolHandlersM.Unlock()
 
+	s.tools = slices.Clone(tools)
 	s.toolHandlers = make(map[string]ToolHandler)
 	for _, tool := range tools {
 		if tool.Name == "" || tool.Handler == nil {
 			continue
 		}
-		s.toolHandlers[tool.Name] = tool.Handler
+		s.toolHandlers[tool.Name] = limitToolHandler(tool, s.toolSlotsFor(tool))
+	}
+}
+
+// toolSlotsFor returns the semaphore enforcing tool's MaxConcurrent, or nil if it has
+// no limit. The semaphore is kept across registrations of the same tool name so that
+// calls still running from before an update count against the limit; it is only
+// replaced when the limit changes. Must be called with toolHandlersM held.
+func (s *Session) toolSlotsFor(tool Tool) chan struct{} {
+	if tool.MaxConcurrent <= 0 {
+		return nil
+	}
+	slots := s.toolSlots[tool.Name]
+	if cap(slots) != tool.MaxConcurrent {
+		slots = make(chan struct{}, tool.MaxConcurrent)
+		if s.toolSlots == nil {
+			s.toolSlots = make(map[string]chan struct{})
+		}
+		s.toolSlots[tool.Name] = slots
+	}
+	return slots
+}
+
+// limitToolHandler wraps the tool's handler to enforce its Timeout, and its
+// MaxConcurrent setting using slots as the semaphore when non-nil
+func limitToolHandler(tool Tool, slots chan struct{}) ToolHandler {
+	handler := tool.Handler
+
+	if slots != nil {
+		next := handler
+		handler = func(ctx context.Context, invocation ToolInvocation) (ToolResult, error) {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ToolResult{}, ctx.Err()
+			}
+			defer func() { <-slots }()
+			return next(ctx, invocation)
+		}
+	}
+
+	if tool.Timeout > 0 {
+		next := handler
+		handler = func(ctx context.Context, invocation ToolInvocation) (ToolResult, error) {
+			ctx, cancel := context.WithTimeout(ctx, tool.Timeout)
+			defer cancel()
+
+			type outcome struct {
+				result ToolResult
+				err    error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				var o outcome
+				defer func() {
+					// The caller's panic recovery doesn't cover this goroutine
+					if r := recover(); r != nil {
+						o.err = fmt.Errorf("tool panic: %v", r)
+					}
+					done <- o
+				}()
+				o.result, o.err = next(ctx, invocation)
+			}()
+
+			var o outcome
+			select {
+			case o = <-done:
+			case <-ctx.Done():
+				o.err = ctx.Err()
+			}
+			// A handler that gave up because of the deadline also counts as timed out
+			if o.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return buildTimedOutToolResult(tool.Name, tool.Timeout), nil
+			}
+			return o.result, o.err
+		}
+	}
+
+	return handler
+}
+
+// RegisterTool adds a tool to this session after it has been created, e.g. once a
+// plugin is loaded, and sends the updated tool set to the server. A tool with the
+// same name as an existing one replaces it.
+//
+// If the server rejects the update or ctx is done first, the previous tools remain
+// registered.
+//
+// Example:
+//
+//	err := session.RegisterTool(ctx, copilot.DefineTool("lookup_ticket", "Look up a ticket",
+//	    func(params TicketParams, inv copilot.ToolInvocation) (any, error) {
+//	        return tracker.Get(params.ID)
+//	    }))
+func (s *Session) RegisterTool(ctx context.Context, tool Tool) error {
+	if err := validateTool(tool); err != nil {
+		return err
+	}
+
+	return s.updateTools(ctx, func(tools []Tool) []Tool {
+		tools = slices.DeleteFunc(tools, func(t Tool) bool { return t.Name == tool.Name })
+		return append(tools, tool)
+	})
+}
+
+// UnregisterTool removes the named tool from this session and sends the updated tool
+// set to the server, so the model is no longer offered it.
+//
+// Returns an error if no tool with that name is registered.
+func (s *Session) UnregisterTool(ctx context.Context, name string) error {
+	s.toolHandlersM.RLock()
+	found := slices.ContainsFunc(s.tools, func(t Tool) bool { return t.Name == name })
+	s.toolHandlersM.RUnlock()
+	if !found {
+		return fmt.Errorf("tool %q is not registered", name)
+	}
+
+	return s.updateTools(ctx, func(tools []Tool) []Tool {
+		return slices.DeleteFunc(tools, func(t Tool) bool { return t.Name == name })
+	})
+}
+
+// SetTools replaces all of this session's tools with tools and sends the new tool set
+// to the server. Passing nil removes every tool.
+//
+// Example:
+//
+//	// Switch to a read-only tool set
+//	err := session.SetTools(ctx, []copilot.Tool{searchTool, readFileTool})
+func (s *Session) SetTools(ctx context.Context, tools []Tool) error {
+	names := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		if err := validateTool(tool); err != nil {
+			return err
+		}
+		if names[tool.Name] {
+			return fmt.Errorf("duplicate tool name %q", tool.Name)
+		}
+		names[tool.Name] = true
+	}
+
+	return s.updateTools(ctx, func([]Tool) []Tool {
+		return slices.Clone(tools)
+	})
+}
+
+func validateTool(tool Tool) error {
+	if tool.Name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tool %q has no handler", tool.Name)
+	}
+	return nil
+}
+
+// updateTools applies update to the session's tools, registers the result locally, and
+// sends it to the server, restoring the previous tools if the server rejects it.
+// Handlers are registered before the server is notified so that calls to new tools
+// can be served as soon as the model is offered them.
+func (s *Session) updateTools(ctx context.Context, update func(tools []Tool) []Tool) error {
+	s.toolsUpdateMux.Lock()
+	defer s.toolsUpdateMux.Unlock()
+
+	s.toolHandlersM.RLock()
+	previous := slices.Clone(s.tools)
+	s.toolHandlersM.RUnlock()
+
+	tools := update(slices.Clone(previous))
+	if tools == nil {
+		tools = []Tool{}
+	}
+	s.registerTools(tools)
+
+	_, err := s.request(ctx, "session.updateTools", sessionUpdateToolsRequest{
+		SessionID: s.SessionID,
+		Tools:     tools,
+	})
+	if err != nil {
+		s.registerTools(previous)
+		return fmt.Errorf("failed to update tools: %w", err)
+	}
+	return nil
+}
+
+// toolContext returns the context passed to tool handlers started now
+func (s *Session) toolContext() context.Context {
+	s.toolCtxMux.Lock()
+	defer s.toolCtxMux.Unlock()
+	return s.toolCtx
+}
+
+// handlerContext returns a context for a handler answering a server request, which
+// is cancelled when either ctx is done (the server cancelled the request) or the
+// session's tool calls are cancelled.
+func (s *Session) handlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.toolContext(), cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// cancelToolCalls cancels the context of all in-flight tool handlers. Handlers
+// started afterwards receive a fresh context.
+func (s *Session) cancelToolCalls() {
+	s.toolCtxMux.Lock()
+	defer s.toolCtxMux.Unlock()
+	s.toolCancel()
+	s.toolCtx, s.toolCancel = context.WithCancel(context.Background())
+}
+
+// dispatchToolProgress delivers a progress update from a running tool to the event
+// handlers as ephemeral events, since the CLI has no way to receive it. Percent is
+// appended to the message because the progress event has no field for it.
+func (s *Session) dispatchToolProgress(toolCallID string, progress ToolProgress) {
+	ephemeral := true
+	message := progress.Message
+	if progress.Percent != nil {
+		message = strings.TrimSpace(fmt.Sprintf("%s (%.0f%%)", message, *progress.Percent))
+	}
+	if message != "" {
+		s.dispatchEvent(SessionEvent{
+			Type:      ToolExecutionProgress,
+			Ephemeral: &ephemeral,
+			Timestamp: time.Now(),
+			Data:      Data{ToolCallID: &toolCallID, ProgressMessage: &message},
+		})
+	}
+	if progress.Output != "" {
+		s.dispatchEvent(SessionEvent{
+			Type:      ToolExecutionPartialResult,
+			Ephemeral: &ephemeral,
+			Timestamp: time.Now(),
+			Data:      Data{ToolCallID: &toolCallID, PartialOutput: &progress.Output},
+		})
 	}
 }
 
@@ -291,6 +880,31 @@ func (s *Session) registerPermissionHandler(handler PermissionHandler) {
 	s.permissionHandler = handler
 }
 
+// SetPermissionHandler replaces the session's permission handler, so approval behavior
+// can change while the session is live, e.g. when the user toggles a safety mode.
+// Requests already being handled finish with the previous handler. Passing nil denies
+// all subsequent requests.
+//
+// The server only sends permission requests to sessions created or resumed with an
+// OnPermissionRequest handler; set one there to be able to swap it later.
+//
+// Example:
+//
+//	safeMode := &copilot.PermissionPolicy{Default: copilot.PermissionDeny, Fallback: prompter.Handle}
+//	session.SetPermissionHandler(safeMode.Handle)
+func (s *Session) SetPermissionHandler(handler PermissionHandler) {
+	s.registerPermissionHandler(handler)
+}
+
+// setPermissionTimeout configures how long the permission handler may take and the
+// decision made when it does not answer in time
+func (s *Session) setPermissionTimeout(timeout time.Duration, onTimeout PermissionAction) {
+	s.permissionMux.Lock()
+	defer s.permissionMux.Unlock()
+	s.permissionTimeout = timeout
+	s.permissionOnTimeout = onTimeout
+}
+
 // getPermissionHandler returns the currently registered permission handler, or nil.
 func (s *Session) getPermissionHandler() PermissionHandler {
 	s.permissionMux.RLock()
@@ -300,7 +914,7 @@ func (s *Session) getPermissionHandler() PermissionHandler {
 
 // handlePermissionRequest handles a permission request from the Copilot CLI.
 // This is an internal method called by the SDK when the CLI requests permission.
-func (s *Session) handlePermissionRequest(request PermissionRequest) (PermissionRequestResult, error) {
+func (s *Session) handlePermissionRequest(ctx context.Context, request PermissionRequest) (PermissionRequestResult, error) {
 	handler := s.getPermissionHandler()
 
 	if handler == nil {
@@ -313,7 +927,50 @@ func (s *Session) handlePermissionRequest(request PermissionRequest) (Permission
 		SessionID: s.SessionID,
 	}
 
-	return handler(request, invocation)
+	s.permissionMux.RLock()
+	timeout, onTimeout := s.permissionTimeout, s.permissionOnTimeout
+	s.permissionMux.RUnlock()
+
+	ctx, cancel := s.handlerContext(ctx)
+	defer cancel()
+	if timeout <= 0 {
+		return handler(ctx, request, invocation)
+	}
+
+	ctx, cancel = context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		result PermissionRequestResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		var o outcome
+		defer func() {
+			if r := recover(); r != nil {
+				o.err = fmt.Errorf("permission handler panic: %v", r)
+			}
+			done <- o
+		}()
+		o.result, o.err = handler(ctx, request, invocation)
+	}()
+
+	var o outcome
+	select {
+	case o = <-done:
+	case <-ctx.Done():
+		o.err = ctx.Err()
+	}
+	if o.err == nil {
+		return o.result, nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && onTimeout == PermissionAllow {
+		return PermissionRequestResult{Kind: "approved"}, nil
+	}
+	return PermissionRequestResult{
+		Kind: "denied-no-approval-rule-and-could-not-request-from-user",
+	}, nil
 }
 
 // registerUserInputHandler registers a user input handler for this session.
@@ -328,6 +985,14 @@ func (s *Session) registerUserInputHandler(handler UserInputHandler) {
 	s.userInputHandler = handler
 }
 
+// SetUserInputHandler replaces the session's user input handler while the session is live.
+// Passing nil makes subsequent requests fail. As with [Session.SetPermissionHandler], the
+// server only sends user input requests to sessions created or resumed with an
+// OnUserInputRequest handler.
+func (s *Session) SetUserInputHandler(handler UserInputHandler) {
+	s.registerUserInputHandler(handler)
+}
+
 // getUserInputHandler returns the currently registered user input handler, or nil.
 func (s *Session) getUserInputHandler() UserInputHandler {
 	s.userInputMux.RLock()
@@ -337,7 +1002,7 @@ func (s *Session) getUserInputHandler() UserInputHandler {
 
 // handleUserInputRequest handles a user input request from the Copilot CLI.
 // This is an internal method called by the SDK when the CLI requests user input.
-func (s *Session) handleUserInputRequest(request UserInputRequest) (UserInputResponse, error) {
+func (s *Session) handleUserInputRequest(ctx context.Context, request UserInputRequest) (UserInputResponse, error) {
 	handler := s.getUserInputHandler()
 
 	if handler == nil {
@@ -348,7 +1013,9 @@ func (s *Session) handleUserInputRequest(request UserInputRequest) (UserInputRes
 		SessionID: s.SessionID,
 	}
 
-	return handler(request, invocation)
+	ctx, cancel := s.handlerContext(ctx)
+	defer cancel()
+	return handler(ctx, request, invocation)
 }
 
 // registerHooks registers hook handlers for this session.
@@ -363,6 +1030,52 @@ func (s *Session) registerHooks(hooks *SessionHooks) {
 	s.hooks = hooks
 }
 
+// SetHooks replaces the session's hook handlers, so hook behavior can change without
+// destroying and resuming the session. Passing nil removes all hooks. When the change
+// turns hooks on or off, the server is told whether to invoke them; if it rejects the
+// update or ctx is done first, the previous hooks are restored and an error is returned.
+//
+// Example:
+//
+//	err := session.SetHooks(ctx, &copilot.SessionHooks{
+//	    OnPreToolUse: func(input copilot.PreToolUseHookInput, inv copilot.HookInvocation) (*copilot.PreToolUseHookOutput, error) {
+//	        log.Printf("tool: %s", input.ToolName)
+//	        return nil, nil
+//	    },
+//	})
+func (s *Session) SetHooks(ctx context.Context, hooks *SessionHooks) error {
+	s.hooksUpdateMux.Lock()
+	defer s.hooksUpdateMux.Unlock()
+
+	previous := s.getHooks()
+	s.registerHooks(hooks)
+
+	enabled := hooks.hasHandlers()
+	if enabled == previous.hasHandlers() {
+		return nil
+	}
+	_, err := s.request(ctx, "session.updateHooks", sessionUpdateHooksRequest{
+		SessionID: s.SessionID,
+		Hooks:     enabled,
+	})
+	if err != nil {
+		s.registerHooks(previous)
+		return fmt.Errorf("failed to update hooks: %w", err)
+	}
+	return nil
+}
+
+// hasHandlers reports whether any hook handler is set, i.e. whether the server
+// needs to invoke hooks for the session
+func (h *SessionHooks) hasHandlers() bool {
+	return h != nil && (h.OnPreToolUse != nil ||
+		h.OnPostToolUse != nil ||
+		h.OnUserPromptSubmitted != nil ||
+		h.OnSessionStart != nil ||
+		h.OnSessionEnd != nil ||
+		h.OnErrorOccurred != nil)
+}
+
 // getHooks returns the currently registered hooks, or nil.
 func (s *Session) getHooks() *SessionHooks {
 	s.hooksMux.RLock()
@@ -452,11 +1165,43 @@ func (s *Session) handleHooksInvoke(hookType string, rawInput json.RawMessage) (
 // This is an internal method; handlers are called synchronously and any panics
 // are recovered to prevent crashing the event dispatcher.
 func (s *Session) dispatchEvent(event SessionEvent) {
-	s.handlerMutex.RLock()
-	handlers := make([]SessionEventHandler, 0, len(s.handlers))
-	for _, h := range s.handlers {
-		handlers = append(handlers, h.fn)
+	s.replayMux.Lock()
+	if s.replayBuffer != nil {
+		// History is being replayed; deliver live events after it
+		s.replayBuffer = append(s.replayBuffer, event)
+		s.replayMux.Unlock()
+		return
+	}
+	s.replayMux.Unlock()
+
+	s.processEvent(event)
+}
+
+// processEvent updates session state from a live event and delivers it to the handlers
+func (s *Session) processEvent(event SessionEvent) {
+	s.recordUsage(event)
+	if event.Type == Abort {
+		s.cancelToolCalls()
 	}
+	if s.eventQueue != nil {
+		s.eventQueue.push(event)
+		return
+	}
+	s.deliverEvent(event)
+}
+
+// setEventQueue makes the session deliver events to its handlers asynchronously
+// through a queue of the given size. It must be called before events arrive.
+func (s *Session) setEventQueue(size int, overflow EventOverflowPolicy) {
+	if size > 0 {
+		s.eventQueue = newEventQueue(size, overflow, s.deliverEvent)
+	}
+}
+
+// deliverEvent calls the registered handlers with event
+func (s *Session) deliverEvent(event SessionEvent) {
+	s.handlerMutex.RLock()
+	handlers := slices.Clone(s.handlers)
 	s.handlerMutex.RUnlock()
 
 	for _, handler := range handlers {
@@ -464,14 +1209,106 @@ func (s *Session) dispatchEvent(event SessionEvent) {
 		func() {
 			defer func() {
 				if r := recover(); r != nil {
-					fmt.Printf("Error in session event handler: %v\n", r)
+					s.handlePanic(event, handler, r, debug.Stack())
 				}
 			}()
-			handler(event)
+			handler.fn(event)
 		}()
 	}
 }
 
+// setPanicHandler configures how panics in event handlers are reported
+func (s *Session) setPanicHandler(handler PanicHandler, repanic bool) {
+	s.onPanic = handler
+	s.repanic = repanic
+}
+
+// setMetrics reports the session as active to metrics, which are told when it is destroyed
+func (s *Session) setMetrics(metrics Metrics) {
+	s.metrics = metrics
+	if metrics != nil {
+		metrics.Add(MetricActiveSessions, 1, nil)
+	}
+}
+
+// log returns the session's logger
+func (s *Session) log() *slog.Logger {
+	if s.logger == nil {
+		return slog.Default()
+	}
+	return s.logger
+}
+
+// handlePanic reports a panic recovered from an event handler
+func (s *Session) handlePanic(event SessionEvent, handler sessionHandler, value any, stack []byte) {
+	if s.onPanic == nil {
+		s.log().Error("session event handler panicked", "session", s.SessionID, "event", event.Type, "panic", value, "stack", string(stack))
+	} else {
+		s.onPanic(HandlerPanic{
+			SessionID:   s.SessionID,
+			Event:       event,
+			HandlerID:   handler.id,
+			HandlerName: runtime.FuncForPC(reflect.ValueOf(handler.fn).Pointer()).Name(),
+			Value:       value,
+			Stack:       stack,
+		})
+	}
+	if s.repanic {
+		panic(value)
+	}
+}
+
+// IsReplaying reports whether the session is replaying history to its event handlers,
+// as requested with ResumeSessionConfig.ReplayHistory. Handlers can call it to tell
+// historical events from live ones.
+//
+// Example:
+//
+//	session.On(func(event copilot.SessionEvent) {
+//	    render(event, session.IsReplaying())
+//	})
+func (s *Session) IsReplaying() bool {
+	return s.replaying.Load()
+}
+
+// beginReplay holds back live events until endReplay is called
+func (s *Session) beginReplay() {
+	s.replayMux.Lock()
+	defer s.replayMux.Unlock()
+	s.replayBuffer = []SessionEvent{}
+}
+
+// endReplay delivers history to the event handlers, marked as replayed, then the live
+// events that arrived in the meantime, skipping those already contained in history
+func (s *Session) endReplay(history []SessionEvent) {
+	s.replaying.Store(true)
+	seen := make(map[string]bool, len(history))
+	for _, event := range history {
+		seen[event.ID] = true
+		s.deliverEvent(event)
+	}
+	s.replaying.Store(false)
+
+	for {
+		s.replayMux.Lock()
+		buffered := s.replayBuffer
+		if len(buffered) == 0 {
+			s.replayBuffer = nil
+			s.replayMux.Unlock()
+			return
+		}
+		s.replayBuffer = []SessionEvent{}
+		s.replayMux.Unlock()
+
+		for _, event := range buffered {
+			if event.ID != "" && seen[event.ID] {
+				continue
+			}
+			s.processEvent(event)
+		}
+	}
+}
+
 // GetMessages retrieves all events and messages from this session's history.
 //
 // This returns the complete conversation history including user messages,
@@ -493,8 +1330,43 @@ func (s *Session) dispatchEvent(event SessionEvent) {
 //	    }
 //	}
 func (s *Session) GetMessages(ctx context.Context) ([]SessionEvent, error) {
+	return s.GetMessagesWithOptions(ctx, nil)
+}
 
-	result, err := s.client.Request("session.getMessages", sessionGetMessagesRequest{SessionID: s.SessionID})
+// GetMessagesWithOptions retrieves events from this session's history, filtered and
+// paginated by options. A nil options value returns the full history, like [Session.GetMessages].
+//
+// The options are sent to the server so long histories are not transferred in full;
+// they are also applied to the response, so results are consistent with servers that
+// ignore them.
+//
+// Example:
+//
+//	// Page through assistant messages 100 at a time
+//	opts := &copilot.GetMessagesOptions{
+//	    Types: []copilot.SessionEventType{copilot.AssistantMessage},
+//	    Limit: 100,
+//	}
+//	for {
+//	    events, err := session.GetMessagesWithOptions(ctx, opts)
+//	    if err != nil || len(events) == 0 {
+//	        break
+//	    }
+//	    // process events...
+//	    opts.After = events[len(events)-1].ID
+//	}
+func (s *Session) GetMessagesWithOptions(ctx context.Context, options *GetMessagesOptions) ([]SessionEvent, error) {
+	req := sessionGetMessagesRequest{SessionID: s.SessionID}
+	if options != nil {
+		req.Types = options.Types
+		req.After = options.After
+		req.Limit = options.Limit
+		if !options.Since.IsZero() {
+			req.Since = &options.Since
+		}
+	}
+
+	result, err := s.request(ctx, "session.getMessages", req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get messages: %w", err)
 	}
@@ -503,7 +1375,37 @@ func (s *Session) GetMessages(ctx context.Context) ([]SessionEvent, error) {
 	if err := json.Unmarshal(result, &response); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal get messages response: %w", err)
 	}
-	return response.Events, nil
+	if options == nil {
+		return response.Events, nil
+	}
+	return options.apply(response.Events), nil
+}
+
+// apply filters and truncates events according to the options
+func (o *GetMessagesOptions) apply(events []SessionEvent) []SessionEvent {
+	if o.After != "" {
+		for i, event := range events {
+			if event.ID == o.After {
+				events = events[i+1:]
+				break
+			}
+		}
+	}
+
+	filtered := make([]SessionEvent, 0, len(events))
+	for _, event := range events {
+		if len(o.Types) > 0 && !slices.Contains(o.Types, event.Type) {
+			continue
+		}
+		if !o.Since.IsZero() && event.Timestamp.Before(o.Since) {
+			continue
+		}
+		filtered = append(filtered, event)
+		if o.Limit > 0 && len(filtered) == o.Limit {
+			break
+		}
+	}
+	return filtered
 }
 
 // Destroy destroys this session and releases all associated resources.
@@ -521,10 +1423,15 @@ func (s *Session) GetMessages(ctx context.Context) ([]SessionEvent, error) {
 //	    log.Printf("Failed to destroy session: %v", err)
 //	}
 func (s *Session) Destroy() error {
-	_, err := s.client.Request("session.destroy", sessionDestroyRequest{SessionID: s.SessionID})
+	_, err := s.request(context.Background(), "session.destroy", sessionDestroyRequest{SessionID: s.SessionID})
 	if err != nil {
 		return fmt.Errorf("failed to destroy session: %w", err)
 	}
+	if !s.destroyed.Swap(true) && s.metrics != nil {
+		s.metrics.Add(MetricActiveSessions, -1, nil)
+	}
+
+	s.cancelToolCalls()
 
 	// Clear handlers
 	s.handlerMutex.Lock()
@@ -564,10 +1471,66 @@ func (s *Session) Destroy() error {
 //	    log.Printf("Failed to abort: %v", err)
 //	}
 func (s *Session) Abort(ctx context.Context) error {
-	_, err := s.client.Request("session.abort", sessionAbortRequest{SessionID: s.SessionID})
+	_, err := s.request(ctx, "session.abort", sessionAbortRequest{SessionID: s.SessionID})
 	if err != nil {
 		return fmt.Errorf("failed to abort session: %w", err)
 	}
 
+	s.cancelToolCalls()
+
+	return nil
+}
+
+// Compact summarizes the session's conversation history now, rather than waiting for
+// the infinite-session threshold, freeing context window space. Use it at natural
+// boundaries, such as after a task finishes. The session.compaction_start and
+// session.compaction_complete events are still emitted.
+//
+// Compaction is requested with session.compact, which SDK protocol version 2 doesn't
+// define; the SDK assumes the CLI server provides it. On servers that don't, Compact
+// returns an error matching [ErrUnsupported], and compaction still happens
+// automatically at the thresholds in [InfiniteSessionConfig].
+//
+// Example:
+//
+//	result, err := session.Compact(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Printf("freed %d tokens\n", result.TokensRemoved)
+func (s *Session) Compact(ctx context.Context) (*CompactionResult, error) {
+	result, err := s.request(ctx, "session.compact", sessionCompactRequest{SessionID: s.SessionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compact session: %w", err)
+	}
+
+	var response CompactionResult
+	if err := json.Unmarshal(result, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal compact response: %w", err)
+	}
+	return &response, nil
+}
+
+// SetSummary sets the session's summary, which [Client.ListSessions] reports as
+// SessionMetadata.Summary. Apps that show a list of sessions can use it to name
+// conversations, like chat titles.
+//
+// The summary is sent with session.setSummary, a request the SDK assumes the CLI
+// server provides; SDK protocol version 2 doesn't include it. Servers without it
+// return an error matching [ErrUnsupported] and keep the summary they generate.
+//
+// Example:
+//
+//	if err := session.SetSummary(ctx, "Parser refactor"); err != nil {
+//	    log.Printf("Failed to set summary: %v", err)
+//	}
+func (s *Session) SetSummary(ctx context.Context, summary string) error {
+	_, err := s.request(ctx, "session.setSummary", sessionSetSummaryRequest{
+		SessionID: s.SessionID,
+		Summary:   summary,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set session summary: %w", err)
+	}
 	return nil
 }
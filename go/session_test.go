@@ -1,8 +1,19 @@
 package copilot
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
 )
 
 func TestSession_On(t *testing.T) {
@@ -119,3 +130,1130 @@ func TestSession_On(t *testing.T) {
 		}
 	})
 }
+
+func TestSession_OnEventType(t *testing.T) {
+	t.Run("only receives events of the subscribed type", func(t *testing.T) {
+		session := &Session{handlers: make([]sessionHandler, 0)}
+
+		var received []SessionEventType
+		unsub := session.OnEventType(AssistantMessage, func(event SessionEvent) {
+			received = append(received, event.Type)
+		})
+
+		session.dispatchEvent(SessionEvent{Type: AssistantMessageDelta})
+		session.dispatchEvent(SessionEvent{Type: AssistantMessage})
+		session.dispatchEvent(SessionEvent{Type: SessionIdle})
+		unsub()
+		session.dispatchEvent(SessionEvent{Type: AssistantMessage})
+
+		if len(received) != 1 || received[0] != AssistantMessage {
+			t.Errorf("Expected exactly one assistant.message, got %v", received)
+		}
+	})
+}
+
+func TestSession_Events(t *testing.T) {
+	t.Run("delivers events until the context is done", func(t *testing.T) {
+		session := &Session{handlers: make([]sessionHandler, 0)}
+
+		ctx, cancel := context.WithCancel(t.Context())
+		events := session.Events(ctx, 2)
+
+		session.dispatchEvent(SessionEvent{Type: AssistantMessage})
+		session.dispatchEvent(SessionEvent{Type: SessionIdle})
+
+		if event := <-events; event.Type != AssistantMessage {
+			t.Errorf("Expected %q, got %q", AssistantMessage, event.Type)
+		}
+		if event := <-events; event.Type != SessionIdle {
+			t.Errorf("Expected %q, got %q", SessionIdle, event.Type)
+		}
+
+		cancel()
+		if _, ok := <-events; ok {
+			t.Error("Expected channel to be closed after cancel")
+		}
+
+		session.handlerMutex.RLock()
+		count := len(session.handlers)
+		session.handlerMutex.RUnlock()
+		if count != 0 {
+			t.Errorf("Expected handler to be unsubscribed, got %d handlers", count)
+		}
+	})
+
+	t.Run("cancellation unblocks a full buffer", func(t *testing.T) {
+		session := &Session{handlers: make([]sessionHandler, 0)}
+
+		ctx, cancel := context.WithCancel(t.Context())
+		session.Events(ctx, 0)
+
+		done := make(chan struct{})
+		go func() {
+			session.dispatchEvent(SessionEvent{Type: AssistantMessage})
+			close(done)
+		}()
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected dispatch to unblock after cancel")
+		}
+	})
+}
+
+func TestSession_EventSeq(t *testing.T) {
+	t.Run("unsubscribes when the loop exits", func(t *testing.T) {
+		session := &Session{handlers: make([]sessionHandler, 0)}
+
+		go func() {
+			// Wait for the range loop to subscribe before dispatching
+			for {
+				session.handlerMutex.RLock()
+				n := len(session.handlers)
+				session.handlerMutex.RUnlock()
+				if n > 0 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			session.dispatchEvent(SessionEvent{Type: AssistantMessage})
+			session.dispatchEvent(SessionEvent{Type: SessionIdle})
+		}()
+
+		var types []SessionEventType
+		for event := range session.EventSeq(t.Context()) {
+			types = append(types, event.Type)
+			if event.Type == SessionIdle {
+				break
+			}
+		}
+
+		if len(types) != 2 || types[0] != AssistantMessage || types[1] != SessionIdle {
+			t.Errorf("Expected [assistant.message session.idle], got %v", types)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			session.handlerMutex.RLock()
+			n := len(session.handlers)
+			session.handlerMutex.RUnlock()
+			if n == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected handler to be unsubscribed, got %d handlers", n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+// newTestSession returns a session connected to a fake CLI server driven by handle.
+func newTestSession(t *testing.T, handle func(method string, params json.RawMessage) any) *Session {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+	go serveFakeCLI(serverConn, handle)
+	client := jsonrpc2.NewClient(clientConn, clientConn)
+	client.Start()
+	t.Cleanup(client.Stop)
+	return newSession("session-1", client, "")
+}
+
+func TestSession_Send(t *testing.T) {
+	t.Run("returns when the context is cancelled and aborts the turn", func(t *testing.T) {
+		release := make(chan struct{})
+		aborted := make(chan struct{}, 1)
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			switch method {
+			case "session.send":
+				<-release
+				return map[string]any{"messageId": "m1"}
+			case "session.abort":
+				aborted <- struct{}{}
+			}
+			return map[string]any{}
+		})
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := session.Send(ctx, MessageOptions{Prompt: "hello", AbortOnCancel: true})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+		}
+
+		select {
+		case <-aborted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected session.abort to be sent")
+		}
+	})
+
+	t.Run("does not abort once the session is idle", func(t *testing.T) {
+		var aborted bool
+		var mu sync.Mutex
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			if method == "session.abort" {
+				mu.Lock()
+				aborted = true
+				mu.Unlock()
+			}
+			return map[string]any{"messageId": "m1"}
+		})
+
+		ctx, cancel := context.WithCancel(t.Context())
+		if _, err := session.Send(ctx, MessageOptions{Prompt: "hello", AbortOnCancel: true}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		session.dispatchEvent(SessionEvent{Type: SessionIdle})
+		cancel()
+
+		// Give a (buggy) abort a chance to be sent
+		if _, err := session.client.Request(t.Context(), "ping", nil); err != nil {
+			t.Fatalf("Ping failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		if aborted {
+			t.Error("Expected no abort after the session became idle")
+		}
+	})
+}
+
+func TestSession_WaitForIdle(t *testing.T) {
+	// waitFor runs WaitForIdle in the background and returns once it has subscribed
+	waitFor := func(session *Session, ctx context.Context) <-chan error {
+		result := make(chan error, 1)
+		go func() { result <- session.WaitForIdle(ctx) }()
+		for {
+			session.handlerMutex.RLock()
+			subscribed := len(session.handlers) > 0
+			session.handlerMutex.RUnlock()
+			if subscribed {
+				return result
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	t.Run("returns on the next session.idle event", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		result := waitFor(session, t.Context())
+
+		session.dispatchEvent(SessionEvent{Type: AssistantMessage})
+		session.dispatchEvent(SessionEvent{Type: SessionIdle})
+		if err := <-result; err != nil {
+			t.Errorf("Expected nil error, got %v", err)
+		}
+	})
+
+	t.Run("returns the session error", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		result := waitFor(session, t.Context())
+
+		message := "rate limited"
+		session.dispatchEvent(SessionEvent{Type: SessionError, Data: Data{Message: &message}})
+		if err := <-result; err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected session error, got %v", err)
+		}
+	})
+
+	t.Run("returns when the context is done", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := session.WaitForIdle(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+		if len(session.handlers) != 0 {
+			t.Error("Expected the event handler to be removed")
+		}
+	})
+}
+
+func TestSession_SendAndStream(t *testing.T) {
+	delta := func(eventType SessionEventType, content string) SessionEvent {
+		return SessionEvent{Type: eventType, Data: Data{DeltaContent: &content}}
+	}
+
+	t.Run("streams deltas and returns the final message", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return map[string]any{"messageId": "m1"}
+		})
+
+		stream, err := session.SendAndStream(t.Context(), MessageOptions{Prompt: "hello"})
+		if err != nil {
+			t.Fatalf("SendAndStream failed: %v", err)
+		}
+
+		content := "Hello world"
+		go func() {
+			session.dispatchEvent(delta(AssistantReasoningDelta, "thinking"))
+			session.dispatchEvent(delta(AssistantMessageDelta, "Hello "))
+			session.dispatchEvent(delta(AssistantMessageDelta, "world"))
+			session.dispatchEvent(SessionEvent{Type: AssistantMessage, Data: Data{Content: &content}})
+			session.dispatchEvent(SessionEvent{Type: SessionIdle})
+		}()
+
+		var text, reasoning string
+		for chunk := range stream.Chunks() {
+			if chunk.Reasoning {
+				reasoning += chunk.Content
+			} else {
+				text += chunk.Content
+			}
+		}
+		if text != "Hello world" || reasoning != "thinking" {
+			t.Errorf("Unexpected streamed text %q / reasoning %q", text, reasoning)
+		}
+
+		final, err := stream.Result()
+		if err != nil {
+			t.Fatalf("Result failed: %v", err)
+		}
+		if final == nil || final.Data.Content == nil || *final.Data.Content != content {
+			t.Errorf("Expected final message %q, got %v", content, final)
+		}
+	})
+
+	t.Run("reports session errors from Result", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return map[string]any{"messageId": "m1"}
+		})
+
+		stream, err := session.SendAndStream(t.Context(), MessageOptions{Prompt: "hello"})
+		if err != nil {
+			t.Fatalf("SendAndStream failed: %v", err)
+		}
+
+		msg := "model unavailable"
+		session.dispatchEvent(delta(AssistantMessageDelta, "partial"))
+		session.dispatchEvent(SessionEvent{Type: SessionError, Data: Data{Message: &msg}})
+
+		if _, err := stream.Result(); err == nil || err.Error() != "session error: model unavailable" {
+			t.Errorf("Expected session error, got %v", err)
+		}
+	})
+}
+
+func TestSession_SendContentBlocks(t *testing.T) {
+	t.Run("sends ordered blocks with the prompt first", func(t *testing.T) {
+		requests := make(chan sessionSendRequest, 1)
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			var req sessionSendRequest
+			json.Unmarshal(params, &req)
+			requests <- req
+			return map[string]any{"messageId": "m1"}
+		})
+
+		_, err := session.Send(t.Context(), MessageOptions{
+			Prompt: "Compare these",
+			Content: []ContentBlock{
+				ImageBlock([]byte{0x89, 0x50}, "image/png"),
+				TextBlock("with this file"),
+				FileBlock("./main.go"),
+			},
+		})
+		if err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+
+		req := <-requests
+		if len(req.Content) != 4 {
+			t.Fatalf("Expected 4 content blocks, got %d", len(req.Content))
+		}
+		wantTypes := []ContentBlockType{ContentBlockText, ContentBlockImage, ContentBlockText, ContentBlockFile}
+		for i, want := range wantTypes {
+			if req.Content[i].Type != want {
+				t.Errorf("Block %d: expected type %q, got %q", i, want, req.Content[i].Type)
+			}
+		}
+		if req.Content[1].Data != "iVA=" {
+			t.Errorf("Expected base64 image data 'iVA=', got %q", req.Content[1].Data)
+		}
+		if req.Prompt != "Compare these\n\nwith this file" {
+			t.Errorf("Expected prompt to join text blocks, got %q", req.Prompt)
+		}
+	})
+
+	t.Run("rejects invalid blocks", func(t *testing.T) {
+		session := newTestSession(t, nil)
+
+		_, err := session.Send(t.Context(), MessageOptions{
+			Content: []ContentBlock{{Type: ContentBlockImage}},
+		})
+		if err == nil {
+			t.Error("Expected error for image block without data")
+		}
+	})
+}
+
+func TestSession_GetMessagesWithOptions(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []SessionEvent{
+		{ID: "e1", Type: UserMessage, Timestamp: base},
+		{ID: "e2", Type: AssistantMessage, Timestamp: base.Add(time.Minute)},
+		{ID: "e3", Type: UserMessage, Timestamp: base.Add(2 * time.Minute)},
+		{ID: "e4", Type: AssistantMessage, Timestamp: base.Add(3 * time.Minute)},
+		{ID: "e5", Type: AssistantMessage, Timestamp: base.Add(4 * time.Minute)},
+	}
+
+	requests := make(chan sessionGetMessagesRequest, 10)
+	session := newTestSession(t, func(method string, params json.RawMessage) any {
+		var req sessionGetMessagesRequest
+		json.Unmarshal(params, &req)
+		requests <- req
+		// Simulate a server that ignores the filters
+		return map[string]any{"events": history}
+	})
+
+	ids := func(events []SessionEvent) []string {
+		var out []string
+		for _, e := range events {
+			out = append(out, e.ID)
+		}
+		return out
+	}
+
+	t.Run("sends options to the server", func(t *testing.T) {
+		_, err := session.GetMessagesWithOptions(t.Context(), &GetMessagesOptions{
+			Types: []SessionEventType{AssistantMessage},
+			Since: base,
+			After: "e1",
+			Limit: 2,
+		})
+		if err != nil {
+			t.Fatalf("GetMessagesWithOptions failed: %v", err)
+		}
+		req := <-requests
+		if len(req.Types) != 1 || req.Since == nil || !req.Since.Equal(base) || req.After != "e1" || req.Limit != 2 {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		options *GetMessagesOptions
+		want    []string
+	}{
+		{"nil options returns everything", nil, []string{"e1", "e2", "e3", "e4", "e5"}},
+		{"filters by type", &GetMessagesOptions{Types: []SessionEventType{UserMessage}}, []string{"e1", "e3"}},
+		{"filters by timestamp", &GetMessagesOptions{Since: base.Add(3 * time.Minute)}, []string{"e4", "e5"}},
+		{"paginates with a cursor", &GetMessagesOptions{After: "e2", Limit: 2}, []string{"e3", "e4"}},
+		{"combines filters", &GetMessagesOptions{Types: []SessionEventType{AssistantMessage}, After: "e2", Limit: 1}, []string{"e4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := session.GetMessagesWithOptions(t.Context(), tt.options)
+			if err != nil {
+				t.Fatalf("GetMessagesWithOptions failed: %v", err)
+			}
+			<-requests
+			if got := ids(events); !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSession_RegisterTool(t *testing.T) {
+	echo := func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+		return ToolResult{TextResultForLLM: "ok", ResultType: "success"}, nil
+	}
+
+	t.Run("registers the handler and sends the full tool set", func(t *testing.T) {
+		updates := make(chan sessionUpdateToolsRequest, 1)
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			var req sessionUpdateToolsRequest
+			json.Unmarshal(params, &req)
+			updates <- req
+			return map[string]any{}
+		})
+		session.registerTools([]Tool{{Name: "existing", Handler: echo}})
+
+		if err := session.RegisterTool(t.Context(), Tool{Name: "added", Description: "new tool", Handler: echo}); err != nil {
+			t.Fatalf("RegisterTool failed: %v", err)
+		}
+
+		req := <-updates
+		if req.SessionID != "session-1" || len(req.Tools) != 2 || req.Tools[1].Name != "added" {
+			t.Errorf("Unexpected update request: %+v", req)
+		}
+		if _, ok := session.getToolHandler("added"); !ok {
+			t.Error("Expected handler for added tool")
+		}
+	})
+
+	t.Run("replaces a tool with the same name", func(t *testing.T) {
+		updates := make(chan sessionUpdateToolsRequest, 1)
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			var req sessionUpdateToolsRequest
+			json.Unmarshal(params, &req)
+			updates <- req
+			return map[string]any{}
+		})
+		session.registerTools([]Tool{{Name: "tool", Description: "v1", Handler: echo}})
+
+		if err := session.RegisterTool(t.Context(), Tool{Name: "tool", Description: "v2", Handler: echo}); err != nil {
+			t.Fatalf("RegisterTool failed: %v", err)
+		}
+		req := <-updates
+		if len(req.Tools) != 1 || req.Tools[0].Description != "v2" {
+			t.Errorf("Expected tool to be replaced, got %+v", req.Tools)
+		}
+	})
+
+	t.Run("keeps previous tools when the server rejects the update", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32603, Message: "boom"}
+		})
+
+		if err := session.RegisterTool(t.Context(), Tool{Name: "added", Handler: echo}); err == nil {
+			t.Fatal("Expected error from rejected update")
+		}
+		if _, ok := session.getToolHandler("added"); ok {
+			t.Error("Expected handler to be removed after rejected update")
+		}
+	})
+
+	t.Run("validates the tool", func(t *testing.T) {
+		session := newTestSession(t, nil)
+		if err := session.RegisterTool(t.Context(), Tool{Handler: echo}); err == nil {
+			t.Error("Expected error for missing name")
+		}
+		if err := session.RegisterTool(t.Context(), Tool{Name: "x"}); err == nil {
+			t.Error("Expected error for missing handler")
+		}
+	})
+}
+
+func TestSession_UnregisterToolAndSetTools(t *testing.T) {
+	echo := func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+		return ToolResult{TextResultForLLM: "ok", ResultType: "success"}, nil
+	}
+	newSessionWithTools := func(t *testing.T) (*Session, chan sessionUpdateToolsRequest) {
+		updates := make(chan sessionUpdateToolsRequest, 1)
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			var req sessionUpdateToolsRequest
+			json.Unmarshal(params, &req)
+			updates <- req
+			return map[string]any{}
+		})
+		session.registerTools([]Tool{{Name: "a", Handler: echo}, {Name: "b", Handler: echo}})
+		return session, updates
+	}
+
+	t.Run("UnregisterTool removes the tool", func(t *testing.T) {
+		session, updates := newSessionWithTools(t)
+
+		if err := session.UnregisterTool(t.Context(), "a"); err != nil {
+			t.Fatalf("UnregisterTool failed: %v", err)
+		}
+		req := <-updates
+		if len(req.Tools) != 1 || req.Tools[0].Name != "b" {
+			t.Errorf("Unexpected tools sent: %+v", req.Tools)
+		}
+		if _, ok := session.getToolHandler("a"); ok {
+			t.Error("Expected handler to be removed")
+		}
+	})
+
+	t.Run("UnregisterTool errors for unknown tools", func(t *testing.T) {
+		session, _ := newSessionWithTools(t)
+		if err := session.UnregisterTool(t.Context(), "missing"); err == nil {
+			t.Error("Expected error for unknown tool")
+		}
+	})
+
+	t.Run("SetTools replaces all tools", func(t *testing.T) {
+		session, updates := newSessionWithTools(t)
+
+		if err := session.SetTools(t.Context(), []Tool{{Name: "c", Handler: echo}}); err != nil {
+			t.Fatalf("SetTools failed: %v", err)
+		}
+		req := <-updates
+		if len(req.Tools) != 1 || req.Tools[0].Name != "c" {
+			t.Errorf("Unexpected tools sent: %+v", req.Tools)
+		}
+		if _, ok := session.getToolHandler("a"); ok {
+			t.Error("Expected old handlers to be removed")
+		}
+	})
+
+	t.Run("SetTools with nil sends an empty list", func(t *testing.T) {
+		session, updates := newSessionWithTools(t)
+
+		if err := session.SetTools(t.Context(), nil); err != nil {
+			t.Fatalf("SetTools failed: %v", err)
+		}
+		req := <-updates
+		if req.Tools == nil || len(req.Tools) != 0 {
+			t.Errorf("Expected empty tool list, got %+v", req.Tools)
+		}
+	})
+
+	t.Run("SetTools rejects duplicate names", func(t *testing.T) {
+		session, _ := newSessionWithTools(t)
+		if err := session.SetTools(t.Context(), []Tool{{Name: "x", Handler: echo}, {Name: "x", Handler: echo}}); err == nil {
+			t.Error("Expected error for duplicate names")
+		}
+	})
+}
+
+func TestSession_ToolContext(t *testing.T) {
+	t.Run("is cancelled on Abort and renewed for later calls", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return map[string]any{}
+		})
+		ctx := session.toolContext()
+
+		if err := session.Abort(t.Context()); err != nil {
+			t.Fatalf("Abort failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("Expected tool context to be cancelled on abort")
+		}
+		if session.toolContext().Err() != nil {
+			t.Error("Expected a fresh tool context after abort")
+		}
+	})
+
+	t.Run("is cancelled on an abort event", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		ctx := session.toolContext()
+
+		session.dispatchEvent(SessionEvent{Type: Abort})
+		if ctx.Err() == nil {
+			t.Error("Expected tool context to be cancelled by abort event")
+		}
+	})
+
+	t.Run("is cancelled on Destroy", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return map[string]any{}
+		})
+		ctx := session.toolContext()
+
+		if err := session.Destroy(); err != nil {
+			t.Fatalf("Destroy failed: %v", err)
+		}
+		if ctx.Err() == nil {
+			t.Error("Expected tool context to be cancelled on destroy")
+		}
+	})
+}
+
+func TestLimitToolHandler(t *testing.T) {
+	t.Run("returns a failure result on timeout and cancels the handler", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		handler := limitToolHandler(Tool{
+			Name:    "slow",
+			Timeout: 10 * time.Millisecond,
+			Handler: func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+				<-ctx.Done()
+				close(cancelled)
+				return ToolResult{}, ctx.Err()
+			},
+		}, nil)
+
+		result, err := handler(t.Context(), ToolInvocation{ToolName: "slow"})
+		if err != nil {
+			t.Fatalf("Expected failure result, got error: %v", err)
+		}
+		if result.ResultType != "failure" || result.Error == "" {
+			t.Errorf("Expected timeout failure result, got %+v", result)
+		}
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Error("Expected handler context to be cancelled")
+		}
+	})
+
+	t.Run("recovers panics in the timed handler", func(t *testing.T) {
+		handler := limitToolHandler(Tool{
+			Name:    "panics",
+			Timeout: time.Second,
+			Handler: func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+				panic("boom")
+			},
+		}, nil)
+
+		if _, err := handler(t.Context(), ToolInvocation{}); err == nil {
+			t.Error("Expected panic to be returned as an error")
+		}
+	})
+
+	t.Run("limits concurrent invocations", func(t *testing.T) {
+		var mu sync.Mutex
+		running, peak := 0, 0
+		handler := limitToolHandler(Tool{
+			Name:          "limited",
+			MaxConcurrent: 2,
+			Handler: func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+				mu.Lock()
+				running++
+				peak = max(peak, running)
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return ToolResult{ResultType: "success"}, nil
+			},
+		}, make(chan struct{}, 2))
+
+		var wg sync.WaitGroup
+		for range 6 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handler(t.Context(), ToolInvocation{})
+			}()
+		}
+		wg.Wait()
+
+		if peak > 2 {
+			t.Errorf("Expected at most 2 concurrent invocations, peak was %d", peak)
+		}
+	})
+
+	t.Run("keeps the concurrency limit across tool updates", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		tool := Tool{
+			Name:          "limited",
+			MaxConcurrent: 1,
+			Handler: func(ctx context.Context, inv ToolInvocation) (ToolResult, error) {
+				<-release
+				return ToolResult{ResultType: "success"}, nil
+			},
+		}
+		session := newSession("session-1", nil, "")
+		session.registerTools([]Tool{tool})
+
+		before, _ := session.getToolHandler("limited")
+		started := make(chan struct{})
+		go func() {
+			close(started)
+			before(t.Context(), ToolInvocation{})
+		}()
+		<-started
+		time.Sleep(10 * time.Millisecond)
+
+		session.registerTools([]Tool{tool})
+		after, _ := session.getToolHandler("limited")
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := after(ctx, ToolInvocation{}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the call to wait for the slot held before the update, got %v", err)
+		}
+	})
+}
+
+func TestSession_PermissionTimeout(t *testing.T) {
+	// slowHandler never answers before the test ends, so only the timeout path can decide
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	slowHandler := func(ctx context.Context, request PermissionRequest, inv PermissionInvocation) (PermissionRequestResult, error) {
+		<-release
+		return PermissionRequestResult{Kind: "denied-interactively-by-user"}, nil
+	}
+
+	t.Run("denies by default when the handler does not answer in time", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		session.registerPermissionHandler(slowHandler)
+		session.setPermissionTimeout(10*time.Millisecond, "")
+
+		result, err := session.handlePermissionRequest(t.Context(), PermissionRequest{Kind: "shell"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Kind != "denied-no-approval-rule-and-could-not-request-from-user" {
+			t.Errorf("Expected denial on timeout, got %q", result.Kind)
+		}
+	})
+
+	t.Run("uses the configured timeout action", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		session.registerPermissionHandler(slowHandler)
+		session.setPermissionTimeout(10*time.Millisecond, PermissionAllow)
+
+		result, _ := session.handlePermissionRequest(t.Context(), PermissionRequest{Kind: "read"})
+		if result.Kind != "approved" {
+			t.Errorf("Expected approval on timeout, got %q", result.Kind)
+		}
+	})
+
+	t.Run("returns the handler's answer when it is in time", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		session.registerPermissionHandler(func(ctx context.Context, request PermissionRequest, inv PermissionInvocation) (PermissionRequestResult, error) {
+			return PermissionRequestResult{Kind: "denied-interactively-by-user"}, nil
+		})
+		session.setPermissionTimeout(time.Second, PermissionAllow)
+
+		result, _ := session.handlePermissionRequest(t.Context(), PermissionRequest{Kind: "write"})
+		if result.Kind != "denied-interactively-by-user" {
+			t.Errorf("Expected handler decision, got %q", result.Kind)
+		}
+	})
+
+	t.Run("cancels the handler context on abort", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		session.registerPermissionHandler(slowHandler)
+		session.setPermissionTimeout(time.Minute, PermissionAllow)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			session.dispatchEvent(SessionEvent{Type: Abort})
+		}()
+
+		result, _ := session.handlePermissionRequest(t.Context(), PermissionRequest{Kind: "shell"})
+		if result.Kind != "denied-no-approval-rule-and-could-not-request-from-user" {
+			t.Errorf("Expected denial after abort, got %q", result.Kind)
+		}
+	})
+}
+
+func TestSession_SetHandlers(t *testing.T) {
+	t.Run("should use the replacement permission handler for later requests", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		session.registerPermissionHandler(func(ctx context.Context, request PermissionRequest, inv PermissionInvocation) (PermissionRequestResult, error) {
+			return PermissionRequestResult{Kind: "approved"}, nil
+		})
+
+		session.SetPermissionHandler(func(ctx context.Context, request PermissionRequest, inv PermissionInvocation) (PermissionRequestResult, error) {
+			return PermissionRequestResult{Kind: "denied-interactively-by-user"}, nil
+		})
+		result, _ := session.handlePermissionRequest(t.Context(), PermissionRequest{Kind: "read"})
+		if result.Kind != "denied-interactively-by-user" {
+			t.Errorf("Expected the replacement handler's decision, got %q", result.Kind)
+		}
+
+		session.SetPermissionHandler(nil)
+		result, _ = session.handlePermissionRequest(t.Context(), PermissionRequest{Kind: "read"})
+		if result.Kind != "denied-no-approval-rule-and-could-not-request-from-user" {
+			t.Errorf("Expected denial without a handler, got %q", result.Kind)
+		}
+	})
+
+	t.Run("should use the replacement user input handler for later requests", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		session.SetUserInputHandler(func(ctx context.Context, request UserInputRequest, inv UserInputInvocation) (UserInputResponse, error) {
+			return UserInputResponse{Answer: "blue"}, nil
+		})
+
+		response, err := session.handleUserInputRequest(t.Context(), UserInputRequest{Question: "Color?"})
+		if err != nil || response.Answer != "blue" {
+			t.Errorf("Expected answer from the replacement handler, got %+v, %v", response, err)
+		}
+
+		session.SetUserInputHandler(nil)
+		if _, err := session.handleUserInputRequest(t.Context(), UserInputRequest{Question: "Color?"}); err == nil {
+			t.Error("Expected an error without a handler")
+		}
+	})
+}
+
+func TestSession_SetHooks(t *testing.T) {
+	preToolUse := func(input PreToolUseHookInput, inv HookInvocation) (*PreToolUseHookOutput, error) {
+		return &PreToolUseHookOutput{PermissionDecision: "deny"}, nil
+	}
+
+	t.Run("enables hooks on the server when the first handler is set", func(t *testing.T) {
+		updates := make(chan sessionUpdateHooksRequest, 2)
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			var req sessionUpdateHooksRequest
+			json.Unmarshal(params, &req)
+			updates <- req
+			return map[string]any{}
+		})
+
+		if err := session.SetHooks(t.Context(), &SessionHooks{OnPreToolUse: preToolUse}); err != nil {
+			t.Fatalf("SetHooks failed: %v", err)
+		}
+		if req := <-updates; req.SessionID != "session-1" || !req.Hooks {
+			t.Errorf("Unexpected update request: %+v", req)
+		}
+		output, _ := session.handleHooksInvoke("preToolUse", json.RawMessage(`{"toolName":"bash"}`))
+		if out, ok := output.(*PreToolUseHookOutput); !ok || out.PermissionDecision != "deny" {
+			t.Errorf("Expected the new hook to run, got %#v", output)
+		}
+
+		if err := session.SetHooks(t.Context(), nil); err != nil {
+			t.Fatalf("SetHooks failed: %v", err)
+		}
+		if req := <-updates; req.Hooks {
+			t.Errorf("Expected hooks to be disabled, got %+v", req)
+		}
+	})
+
+	t.Run("does not contact the server when hooks stay enabled", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			t.Errorf("Unexpected request %s", method)
+			return map[string]any{}
+		})
+		session.registerHooks(&SessionHooks{OnSessionEnd: func(input SessionEndHookInput, inv HookInvocation) (*SessionEndHookOutput, error) {
+			return nil, nil
+		}})
+
+		if err := session.SetHooks(t.Context(), &SessionHooks{OnPreToolUse: preToolUse}); err != nil {
+			t.Fatalf("SetHooks failed: %v", err)
+		}
+		if session.getHooks().OnPreToolUse == nil {
+			t.Error("Expected hooks to be replaced")
+		}
+	})
+
+	t.Run("keeps previous hooks when the server rejects the update", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32603, Message: "boom"}
+		})
+
+		if err := session.SetHooks(t.Context(), &SessionHooks{OnPreToolUse: preToolUse}); err == nil {
+			t.Fatal("Expected error from rejected update")
+		}
+		if session.getHooks() != nil {
+			t.Error("Expected hooks to be restored after rejected update")
+		}
+	})
+}
+
+func TestSession_Compact(t *testing.T) {
+	t.Run("returns the summary and token stats", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			if method != "session.compact" {
+				t.Errorf("Unexpected method %s", method)
+			}
+			return map[string]any{
+				"summaryContent":       "Refactored the parser",
+				"preCompactionTokens":  90000,
+				"postCompactionTokens": 12000,
+				"tokensRemoved":        78000,
+				"messagesRemoved":      41,
+			}
+		})
+
+		result, err := session.Compact(t.Context())
+		if err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+		if result.Summary != "Refactored the parser" || result.TokensRemoved != 78000 || result.MessagesRemoved != 41 || result.PostCompactionTokens != 12000 {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("returns server errors", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32603, Message: "nothing to compact"}
+		})
+
+		if _, err := session.Compact(t.Context()); err == nil {
+			t.Error("Expected error")
+		}
+	})
+
+	t.Run("reports a server without session.compact as ErrUnsupported", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32601, Message: "Method not found"}
+		})
+
+		if _, err := session.Compact(t.Context()); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected ErrUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestSession_SetSummary(t *testing.T) {
+	t.Run("sends the summary", func(t *testing.T) {
+		var req sessionSetSummaryRequest
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			if method == "session.setSummary" {
+				json.Unmarshal(params, &req)
+			}
+			return map[string]any{}
+		})
+
+		if err := session.SetSummary(t.Context(), "Parser refactor"); err != nil {
+			t.Fatalf("SetSummary failed: %v", err)
+		}
+		if req.SessionID != "session-1" || req.Summary != "Parser refactor" {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+	})
+
+	t.Run("reports a server without session.setSummary as ErrUnsupported", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32601, Message: "Method not found"}
+		})
+
+		if err := session.SetSummary(t.Context(), "Parser refactor"); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected ErrUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestSession_Errors(t *testing.T) {
+	t.Run("should report an unknown session as ErrSessionNotFound", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32603, Message: "Session not found: session-1"}
+		})
+
+		_, err := session.Send(t.Context(), MessageOptions{Prompt: "hello"})
+		if !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("Expected ErrSessionNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should not treat other errors as ErrSessionNotFound", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32601, Message: "Method not found"}
+		})
+
+		_, err := session.Send(t.Context(), MessageOptions{Prompt: "hello"})
+		if err == nil || errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("Expected a plain error, got %v", err)
+		}
+	})
+
+	t.Run("should decode error data into an RPCError", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32000, Message: "Too many requests", Data: map[string]any{"errorType": "rate_limit", "retryAfter": 1.5}}
+		})
+
+		_, err := session.Send(t.Context(), MessageOptions{Prompt: "hello"})
+		var rpcErr *RPCError
+		if !errors.Is(err, ErrRateLimited) || !errors.As(err, &rpcErr) {
+			t.Fatalf("Expected a rate-limit RPCError, got %v", err)
+		}
+		if rpcErr.Code != -32000 || rpcErr.RetryAfter != 1500*time.Millisecond || rpcErr.Data["errorType"] != "rate_limit" {
+			t.Errorf("Unexpected error fields: %+v", rpcErr)
+		}
+	})
+
+	t.Run("should recognize authentication failures", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return &fakeCLIError{Code: -32603, Message: "Not authenticated: run copilot login"}
+		})
+
+		if _, err := session.Send(t.Context(), MessageOptions{Prompt: "hello"}); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Expected ErrUnauthorized, got %v", err)
+		}
+	})
+
+	t.Run("should fail with ErrSessionDestroyed after Destroy", func(t *testing.T) {
+		calls := 0
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			calls++
+			return map[string]any{}
+		})
+
+		if err := session.Destroy(); err != nil {
+			t.Fatalf("Destroy failed: %v", err)
+		}
+		if _, err := session.Send(t.Context(), MessageOptions{Prompt: "hello"}); !errors.Is(err, ErrSessionDestroyed) {
+			t.Errorf("Expected ErrSessionDestroyed, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("Expected no requests after Destroy, got %d", calls-1)
+		}
+	})
+}
+
+func TestSession_Replay(t *testing.T) {
+	t.Run("delivers history before live events, marked as replayed", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		type delivered struct {
+			id        string
+			replaying bool
+		}
+		var got []delivered
+		session.On(func(event SessionEvent) {
+			got = append(got, delivered{event.ID, session.IsReplaying()})
+		})
+
+		session.beginReplay()
+		session.dispatchEvent(SessionEvent{ID: "e2", Type: AssistantMessage})
+		session.dispatchEvent(SessionEvent{ID: "e3", Type: SessionIdle})
+		if len(got) != 0 {
+			t.Fatalf("Expected live events to be held back during replay, got %v", got)
+		}
+
+		session.endReplay([]SessionEvent{{ID: "e1", Type: UserMessage}, {ID: "e2", Type: AssistantMessage}})
+		want := []delivered{{"e1", true}, {"e2", true}, {"e3", false}}
+		if !slices.Equal(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+
+		session.dispatchEvent(SessionEvent{ID: "e4", Type: UserMessage})
+		if len(got) != 4 || got[3].replaying {
+			t.Errorf("Expected live delivery after replay, got %v", got)
+		}
+	})
+}
+
+func TestSession_HandlerPanic(t *testing.T) {
+	t.Run("reports panics to the panic handler and keeps dispatching", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		var reported []HandlerPanic
+		session.setPanicHandler(func(p HandlerPanic) { reported = append(reported, p) }, false)
+
+		delivered := false
+		session.On(func(event SessionEvent) { panic("boom") })
+		session.On(func(event SessionEvent) { delivered = true })
+
+		session.dispatchEvent(SessionEvent{ID: "e1", Type: AssistantMessage})
+
+		if !delivered {
+			t.Error("Expected later handlers to still receive the event")
+		}
+		if len(reported) != 1 {
+			t.Fatalf("Expected one reported panic, got %d", len(reported))
+		}
+		p := reported[0]
+		if p.SessionID != "session-1" || p.Event.ID != "e1" || p.Value != "boom" || p.HandlerID != 0 {
+			t.Errorf("Unexpected panic report: %+v", p)
+		}
+		if !strings.Contains(p.HandlerName, "TestSession_HandlerPanic") || len(p.Stack) == 0 {
+			t.Errorf("Expected handler name and stack, got %q / %d bytes", p.HandlerName, len(p.Stack))
+		}
+	})
+
+	t.Run("re-panics when configured", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		called := false
+		session.setPanicHandler(func(p HandlerPanic) { called = true }, true)
+		session.On(func(event SessionEvent) { panic("boom") })
+
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Expected re-panic with boom, got %v", r)
+			}
+			if !called {
+				t.Error("Expected the panic handler to be called first")
+			}
+		}()
+		session.dispatchEvent(SessionEvent{Type: AssistantMessage})
+		t.Error("Expected dispatchEvent to panic")
+	})
+
+	t.Run("logs panics without a panic handler", func(t *testing.T) {
+		var logged bytes.Buffer
+		session := newSession("session-1", nil, "")
+		session.logger = slog.New(slog.NewTextHandler(&logged, nil))
+		session.On(func(event SessionEvent) { panic("boom") })
+
+		session.dispatchEvent(SessionEvent{Type: AssistantMessage})
+
+		if line := logged.String(); !strings.Contains(line, "level=ERROR") || !strings.Contains(line, "panic=boom") || !strings.Contains(line, "session=session-1") {
+			t.Errorf("Unexpected log output: %s", line)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// ExportTranscript renders the session's conversation to w in the given format.
+// The transcript contains user messages, assistant responses, and tool calls with
+// their arguments and output; in Markdown and HTML, tool output is collapsed.
+//
+// Example:
+//
+//	f, _ := os.Create("transcript.md")
+//	defer f.Close()
+//	if err := session.ExportTranscript(ctx, copilot.TranscriptMarkdown, f); err != nil {
+//	    log.Fatal(err)
+//	}
+func (s *Session) ExportTranscript(ctx context.Context, format TranscriptFormat, w io.Writer) error {
+	events, err := s.GetMessages(ctx)
+	if err != nil {
+		return err
+	}
+	return writeTranscript(w, format, buildTranscript(events))
+}
+
+// buildTranscript converts session events into transcript entries, merging each
+// tool call's start and completion events into a single entry
+func buildTranscript(events []SessionEvent) []TranscriptEntry {
+	entries := make([]TranscriptEntry, 0, len(events))
+	toolEntries := make(map[string]int)
+
+	for _, event := range events {
+		switch event.Type {
+		case UserMessage, AssistantMessage:
+			if event.Data.Content == nil || *event.Data.Content == "" {
+				continue
+			}
+			role := "user"
+			if event.Type == AssistantMessage {
+				role = "assistant"
+			}
+			entries = append(entries, TranscriptEntry{Role: role, Content: *event.Data.Content, Timestamp: event.Timestamp})
+
+		case ToolExecutionStart:
+			entry := TranscriptEntry{Role: "tool", Arguments: event.Data.Arguments, Timestamp: event.Timestamp}
+			if event.Data.ToolName != nil {
+				entry.ToolName = *event.Data.ToolName
+			}
+			if event.Data.ToolCallID != nil {
+				entry.ToolCallID = *event.Data.ToolCallID
+				toolEntries[entry.ToolCallID] = len(entries)
+			}
+			entries = append(entries, entry)
+
+		case ToolExecutionComplete:
+			if event.Data.ToolCallID == nil {
+				continue
+			}
+			i, ok := toolEntries[*event.Data.ToolCallID]
+			if !ok {
+				continue
+			}
+			entries[i].Success = event.Data.Success
+			if event.Data.Result != nil {
+				entries[i].Output = event.Data.Result.Content
+			} else if event.Data.Error != nil {
+				if event.Data.Error.ErrorClass != nil {
+					entries[i].Output = event.Data.Error.ErrorClass.Message
+				} else if event.Data.Error.String != nil {
+					entries[i].Output = *event.Data.Error.String
+				}
+			}
+		}
+	}
+	return entries
+}
+
+func writeTranscript(w io.Writer, format TranscriptFormat, entries []TranscriptEntry) error {
+	switch format {
+	case TranscriptJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			return fmt.Errorf("failed to write transcript: %w", err)
+		}
+		return nil
+	case TranscriptMarkdown:
+		return writeMarkdownTranscript(w, entries)
+	case TranscriptHTML:
+		if err := htmlTranscriptTemplate.Execute(w, entries); err != nil {
+			return fmt.Errorf("failed to write transcript: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown transcript format %q", format)
+	}
+}
+
+func writeMarkdownTranscript(w io.Writer, entries []TranscriptEntry) error {
+	var b strings.Builder
+	b.WriteString("# Transcript\n")
+	for _, entry := range entries {
+		switch entry.Role {
+		case "user":
+			fmt.Fprintf(&b, "\n## User\n\n%s\n", entry.Content)
+		case "assistant":
+			fmt.Fprintf(&b, "\n## Assistant\n\n%s\n", entry.Content)
+		case "tool":
+			fmt.Fprintf(&b, "\n<details>\n<summary>Tool: %s%s</summary>\n\n", entry.ToolName, toolStatusSuffix(entry.Success))
+			if entry.Arguments != nil {
+				fmt.Fprintf(&b, "Arguments:\n\n%s\n\n", markdownCodeBlock(entry.argumentsJSON()))
+			}
+			if entry.Output != "" {
+				fmt.Fprintf(&b, "Output:\n\n%s\n\n", markdownCodeBlock(entry.Output))
+			}
+			b.WriteString("</details>\n")
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return nil
+}
+
+// markdownCodeBlock fences text with enough backticks that it cannot close the fence early
+func markdownCodeBlock(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
+
+func toolStatusSuffix(success *bool) string {
+	if success != nil && !*success {
+		return " (failed)"
+	}
+	return ""
+}
+
+func (e TranscriptEntry) argumentsJSON() string {
+	data, err := json.MarshalIndent(e.Arguments, "", "  ")
+	if err != nil {
+		return fmt.Sprint(e.Arguments)
+	}
+	return string(data)
+}
+
+var htmlTranscriptTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"status":    toolStatusSuffix,
+	"arguments": TranscriptEntry.argumentsJSON,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Transcript</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; }
+.message { white-space: pre-wrap; margin-bottom: 1.5em; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>Transcript</h1>
+{{- range .}}
+{{- if eq .Role "user"}}
+<h2>User</h2>
+<div class="message">{{.Content}}</div>
+{{- else if eq .Role "assistant"}}
+<h2>Assistant</h2>
+<div class="message">{{.Content}}</div>
+{{- else if eq .Role "tool"}}
+<details>
+<summary>Tool: {{.ToolName}}{{status .Success}}</summary>
+{{- if .Arguments}}
+<p>Arguments:</p>
+<pre>{{arguments .}}</pre>
+{{- end}}
+{{- if .Output}}
+<p>Output:</p>
+<pre>{{.Output}}</pre>
+{{- end}}
+</details>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
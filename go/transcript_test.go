@@ -0,0 +1,85 @@
+package copilot
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func transcriptTestEvents() []SessionEvent {
+	str := func(s string) *string { return &s }
+	return []SessionEvent{
+		{Type: UserMessage, Data: Data{Content: str("List the <files>")}},
+		{Type: ToolExecutionStart, Data: Data{ToolCallID: str("call-1"), ToolName: str("bash"), Arguments: map[string]any{"command": "ls"}}},
+		{Type: AssistantMessageDelta, Data: Data{DeltaContent: str("ignored")}},
+		{Type: ToolExecutionComplete, Data: Data{ToolCallID: str("call-1"), Success: Bool(true), Result: &Result{Content: "main.go\n```\n"}}},
+		{Type: AssistantMessage, Data: Data{Content: str("There is one file.")}},
+	}
+}
+
+func TestBuildTranscript(t *testing.T) {
+	entries := buildTranscript(transcriptTestEvents())
+
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].Role != "user" || entries[2].Role != "assistant" {
+		t.Errorf("Unexpected roles: %q, %q", entries[0].Role, entries[2].Role)
+	}
+	tool := entries[1]
+	if tool.Role != "tool" || tool.ToolName != "bash" || tool.Output != "main.go\n```\n" || tool.Success == nil || !*tool.Success {
+		t.Errorf("Expected tool start and completion to be merged, got %+v", tool)
+	}
+}
+
+func TestWriteTranscript(t *testing.T) {
+	entries := buildTranscript(transcriptTestEvents())
+
+	t.Run("markdown collapses tool output", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeTranscript(&buf, TranscriptMarkdown, entries); err != nil {
+			t.Fatalf("writeTranscript failed: %v", err)
+		}
+		out := buf.String()
+		for _, want := range []string{"## User\n\nList the <files>", "<summary>Tool: bash</summary>", "````\nmain.go\n```\n````", "## Assistant\n\nThere is one file."} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected markdown to contain %q, got:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("json round-trips entries", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeTranscript(&buf, TranscriptJSON, entries); err != nil {
+			t.Fatalf("writeTranscript failed: %v", err)
+		}
+		var decoded []TranscriptEntry
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		if len(decoded) != 3 || decoded[1].ToolName != "bash" {
+			t.Errorf("Unexpected decoded entries: %+v", decoded)
+		}
+	})
+
+	t.Run("html escapes content", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := writeTranscript(&buf, TranscriptHTML, entries); err != nil {
+			t.Fatalf("writeTranscript failed: %v", err)
+		}
+		out := buf.String()
+		if !strings.Contains(out, "List the &lt;files&gt;") {
+			t.Errorf("Expected escaped user content, got:\n%s", out)
+		}
+		if !strings.Contains(out, "<summary>Tool: bash</summary>") {
+			t.Errorf("Expected collapsed tool call, got:\n%s", out)
+		}
+	})
+
+	t.Run("rejects unknown formats", func(t *testing.T) {
+		if err := writeTranscript(&bytes.Buffer{}, "pdf", entries); err == nil {
+			t.Error("Expected error for unknown format")
+		}
+	})
+}
@@ -0,0 +1,130 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunTurn sends a message and waits until the session becomes idle, like
+// [Session.SendAndWait], but returns everything that happened during the turn: the
+// final assistant message, all events, the tools that were executed with their
+// results, the model's reasoning, and the turn's token usage.
+//
+// If ctx has no deadline, a 60 second timeout applies. When waiting fails because of
+// a session error or the timeout, the events collected so far are returned along
+// with the error.
+//
+// Example:
+//
+//	turn, err := session.RunTurn(ctx, copilot.MessageOptions{Prompt: "Fix the failing test"})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, call := range turn.ToolCalls {
+//	    fmt.Printf("%s succeeded=%v\n", call.ToolName, call.Success)
+//	}
+//	fmt.Printf("tokens out=%d\n", turn.Usage.OutputTokens)
+func (s *Session) RunTurn(ctx context.Context, options MessageOptions) (*TurnResult, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 60*time.Second)
+		defer cancel()
+	}
+
+	collector := &turnCollector{}
+	unsubscribe := s.On(collector.record)
+	defer unsubscribe()
+
+	idle := s.newIdleWaiter()
+	defer idle.stop()
+
+	if _, err := s.Send(ctx, options); err != nil {
+		return nil, err
+	}
+
+	err := idle.wait(ctx)
+	return collector.result(), err
+}
+
+// turnCollector accumulates the events of a turn into a TurnResult
+type turnCollector struct {
+	mu        sync.Mutex
+	turn      TurnResult
+	reasoning []string
+	toolCalls map[string]int
+}
+
+func (c *turnCollector) record(event SessionEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.turn.Events = append(c.turn.Events, event)
+	switch event.Type {
+	case AssistantMessage:
+		eventCopy := event
+		c.turn.Message = &eventCopy
+
+	case AssistantReasoning:
+		if event.Data.Content != nil && *event.Data.Content != "" {
+			c.reasoning = append(c.reasoning, *event.Data.Content)
+		}
+
+	case AssistantUsage:
+		c.turn.Usage.add(usageFromEvent(event))
+
+	case ToolExecutionStart:
+		call := TurnToolCall{Arguments: event.Data.Arguments}
+		if event.Data.ToolName != nil {
+			call.ToolName = *event.Data.ToolName
+		}
+		if event.Data.ToolCallID != nil {
+			call.ToolCallID = *event.Data.ToolCallID
+			if c.toolCalls == nil {
+				c.toolCalls = make(map[string]int)
+			}
+			c.toolCalls[call.ToolCallID] = len(c.turn.ToolCalls)
+		}
+		c.turn.ToolCalls = append(c.turn.ToolCalls, call)
+
+	case ToolExecutionComplete:
+		if event.Data.ToolCallID == nil {
+			return
+		}
+		i, ok := c.toolCalls[*event.Data.ToolCallID]
+		if !ok {
+			return
+		}
+		call := &c.turn.ToolCalls[i]
+		call.Completed = true
+		call.Success = event.Data.Success != nil && *event.Data.Success
+		if event.Data.Result != nil {
+			call.Output = event.Data.Result.Content
+		} else if event.Data.Error != nil {
+			if event.Data.Error.ErrorClass != nil {
+				call.Output = event.Data.Error.ErrorClass.Message
+			} else if event.Data.Error.String != nil {
+				call.Output = *event.Data.Error.String
+			}
+		}
+	}
+}
+
+func (c *turnCollector) result() *TurnResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := c.turn
+	result.Events = append([]SessionEvent(nil), c.turn.Events...)
+	result.ToolCalls = append([]TurnToolCall(nil), c.turn.ToolCalls...)
+	result.Reasoning = strings.Join(c.reasoning, "\n\n")
+	if result.Reasoning == "" && result.Message != nil && result.Message.Data.ReasoningText != nil {
+		result.Reasoning = *result.Message.Data.ReasoningText
+	}
+	return &result
+}
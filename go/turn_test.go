@@ -0,0 +1,96 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSession_RunTurn(t *testing.T) {
+	str := func(s string) *string { return &s }
+	f64 := func(f float64) *float64 { return &f }
+	yes, no := true, false
+
+	t.Run("collects the turn's message, tool calls, reasoning, and usage", func(t *testing.T) {
+		var session *Session
+		session = newTestSession(t, func(method string, params json.RawMessage) any {
+			if method == "session.send" {
+				session.dispatchEvent(SessionEvent{Type: AssistantReasoning, Data: Data{Content: str("Need to list files")}})
+				session.dispatchEvent(SessionEvent{Type: ToolExecutionStart, Data: Data{ToolCallID: str("c1"), ToolName: str("ls"), Arguments: map[string]any{"path": "."}}})
+				session.dispatchEvent(SessionEvent{Type: ToolExecutionStart, Data: Data{ToolCallID: str("c2"), ToolName: str("cat")}})
+				session.dispatchEvent(SessionEvent{Type: ToolExecutionComplete, Data: Data{ToolCallID: str("c1"), Success: &yes, Result: &Result{Content: "main.go"}}})
+				session.dispatchEvent(SessionEvent{Type: ToolExecutionComplete, Data: Data{ToolCallID: str("c2"), Success: &no, Error: &ErrorUnion{String: str("not found")}}})
+				session.dispatchEvent(SessionEvent{Type: AssistantUsage, Data: Data{InputTokens: f64(100), OutputTokens: f64(20)}})
+				session.dispatchEvent(SessionEvent{Type: AssistantUsage, Data: Data{InputTokens: f64(50), OutputTokens: f64(5)}})
+				session.dispatchEvent(SessionEvent{Type: AssistantMessage, Data: Data{Content: str("There is one file.")}})
+				session.dispatchEvent(SessionEvent{Type: SessionIdle})
+			}
+			return map[string]any{"messageId": "m1"}
+		})
+
+		turn, err := session.RunTurn(t.Context(), MessageOptions{Prompt: "What files are here?"})
+		if err != nil {
+			t.Fatalf("RunTurn failed: %v", err)
+		}
+		if turn.Message == nil || *turn.Message.Data.Content != "There is one file." {
+			t.Errorf("Unexpected final message: %v", turn.Message)
+		}
+		if len(turn.Events) != 9 {
+			t.Errorf("Expected 9 events, got %d", len(turn.Events))
+		}
+		if turn.Reasoning != "Need to list files" {
+			t.Errorf("Unexpected reasoning %q", turn.Reasoning)
+		}
+		if turn.Usage.InputTokens != 150 || turn.Usage.OutputTokens != 25 || turn.Usage.Requests != 2 {
+			t.Errorf("Unexpected usage: %+v", turn.Usage)
+		}
+
+		if len(turn.ToolCalls) != 2 {
+			t.Fatalf("Expected 2 tool calls, got %d", len(turn.ToolCalls))
+		}
+		ls, cat := turn.ToolCalls[0], turn.ToolCalls[1]
+		if ls.ToolName != "ls" || !ls.Completed || !ls.Success || ls.Output != "main.go" {
+			t.Errorf("Unexpected ls call: %+v", ls)
+		}
+		if cat.ToolName != "cat" || !cat.Completed || cat.Success || cat.Output != "not found" {
+			t.Errorf("Unexpected cat call: %+v", cat)
+		}
+	})
+
+	t.Run("returns the partial turn with a session error", func(t *testing.T) {
+		var session *Session
+		session = newTestSession(t, func(method string, params json.RawMessage) any {
+			if method == "session.send" {
+				session.dispatchEvent(SessionEvent{Type: ToolExecutionStart, Data: Data{ToolCallID: str("c1"), ToolName: str("build")}})
+				session.dispatchEvent(SessionEvent{Type: SessionError, Data: Data{Message: str("model unavailable")}})
+			}
+			return map[string]any{"messageId": "m1"}
+		})
+
+		turn, err := session.RunTurn(t.Context(), MessageOptions{Prompt: "Build it"})
+		if err == nil {
+			t.Fatal("Expected session error")
+		}
+		if turn == nil || len(turn.ToolCalls) != 1 || turn.ToolCalls[0].Completed {
+			t.Errorf("Expected one incomplete tool call, got %+v", turn)
+		}
+	})
+
+	t.Run("returns the partial turn when the context is done", func(t *testing.T) {
+		session := newTestSession(t, func(method string, params json.RawMessage) any {
+			return map[string]any{"messageId": "m1"}
+		})
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		turn, err := session.RunTurn(ctx, MessageOptions{Prompt: "hello"})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+		if turn == nil {
+			t.Error("Expected a partial turn result")
+		}
+	})
+}
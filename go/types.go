@@ -1,6 +1,21 @@
 package copilot
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/github/copilot-sdk/go/internal/jsonrpc2"
+)
+
+// MinSdkProtocolVersion is the oldest server protocol version this SDK can communicate with.
+const MinSdkProtocolVersion = 2
 
 // ConnectionState represents the client connection state
 type ConnectionState string
@@ -14,8 +29,13 @@ const (
 
 // ClientOptions configures the CopilotClient
 type ClientOptions struct {
-	// CLIPath is the path to the Copilot CLI executable (default: "copilot")
+	// CLIPath is the path to the Copilot CLI executable. When empty, the CLI is looked
+	// up through the other CLISources.
 	CLIPath string
+	// CLISources is the order in which the CLI executable is looked up (default:
+	// CLISourceOption, CLISourceEnv, CLISourcePath). Use [Client.ResolveCLI] to see which
+	// source is chosen.
+	CLISources []CLISource
 	// Cwd is the working directory for the CLI process (default: "" = inherit from current process)
 	Cwd string
 	// Port for TCP transport (default: 0 = random port)
@@ -26,10 +46,46 @@ type ClientOptions struct {
 	// CLIUrl is the URL of an existing Copilot CLI server to connect to over TCP
 	// Format: "host:port", "http://host:port", or just "port" (defaults to localhost)
 	// Examples: "localhost:8080", "http://127.0.0.1:9000", "8080"
+	// Use a "ws://" or "wss://" URL (e.g. "wss://copilot.example.com/rpc") to connect over
+	// WebSocket, such as through a reverse proxy that doesn't pass raw TCP.
 	// Mutually exclusive with CLIPath, UseStdio
 	CLIUrl string
+	// Dial overrides how TCP connections to the CLI server are established, e.g. to route
+	// through a SOCKS proxy or custom tunnel (default: net.Dialer with a 10 second timeout).
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Conn is an already-established connection to a CLI server, such as an in-memory pipe.
+	// When set, the client neither spawns a CLI process nor dials; Stop closes Conn.
+	// Mutually exclusive with CLIUrl, CLIPath, UseStdio, and Dial.
+	Conn io.ReadWriteCloser
+	// Framing selects how JSON-RPC messages are delimited on stdio, TCP, and Conn
+	// connections (default: FramingContentLength). The server must use the same framing;
+	// WebSocket connections carry one message per frame and ignore this option.
+	Framing MessageFraming
+	// Trace, if set, receives every raw JSON-RPC message exchanged with the CLI server,
+	// without framing, for debugging protocol issues. It is called synchronously and
+	// possibly concurrently from the goroutines sending and receiving messages, so it
+	// should be fast and safe for concurrent use, and must not retain or modify payload.
+	Trace TraceFunc
+	// MaxMessageSize is the largest JSON-RPC message, in bytes, accepted from the CLI server
+	// (default: 64 MiB). Larger messages are discarded so a misbehaving server can't
+	// exhaust memory; a request whose response is discarded waits until its ctx is done.
+	MaxMessageSize int
+	// OnMessageDropped, if set, is called with the reason whenever a message from the CLI
+	// server is discarded, such as one larger than MaxMessageSize
+	OnMessageDropped func(err error)
+	// HeartbeatInterval, when positive, pings the CLI server this often and closes the
+	// connection if it doesn't answer within HeartbeatTimeout, so requests to a silently
+	// dead server fail with [ErrConnectionLost] instead of hanging
+	HeartbeatInterval time.Duration
+	// HeartbeatTimeout is how long a heartbeat ping may take (default: HeartbeatInterval)
+	HeartbeatTimeout time.Duration
 	// LogLevel for the CLI server
 	LogLevel string
+	// Logger receives the SDK's own diagnostics, such as read failures, recovered handler
+	// panics without a PanicHandler, and allowed protocol mismatches. Its handler decides
+	// the level and destination (default: slog.Default()). LogLevel is separate and only
+	// affects the CLI server.
+	Logger *slog.Logger
 	// AutoStart automatically starts the CLI server on first use (default: true).
 	// Use Bool(false) to disable.
 	AutoStart *bool
@@ -46,14 +102,150 @@ type ClientOptions struct {
 	// When provided, the token is passed to the CLI server via environment variable.
 	// This takes priority over other authentication methods.
 	GithubToken string
+	// TokenProvider supplies GitHub tokens that may rotate over time, such as short-lived
+	// GitHub App installation tokens. It is consulted when the CLI server is started and
+	// again whenever a session reports an authentication error.
+	// Mutually exclusive with GithubToken; cannot be used with CLIUrl or Conn.
+	TokenProvider TokenProvider
 	// UseLoggedInUser controls whether to use the logged-in user for authentication.
 	// When true, the CLI server will attempt to use stored OAuth tokens or gh CLI auth.
 	// When false, only explicit tokens (GithubToken or environment variables) are used.
 	// Default: true (but defaults to false when GithubToken is provided).
 	// Use Bool(false) to explicitly disable.
 	UseLoggedInUser *bool
+	// OnProcessExit is called when the spawned CLI process exits unexpectedly.
+	// It is not called when the process is stopped via Stop or ForceStop.
+	OnProcessExit ProcessExitHandler
+	// MinProtocolVersion is the lowest server protocol version accepted (default: MinSdkProtocolVersion)
+	MinProtocolVersion int
+	// MaxProtocolVersion is the highest server protocol version accepted (default: SdkProtocolVersion).
+	// Raise this to accept backwards-compatible server protocol bumps.
+	MaxProtocolVersion int
+	// AllowProtocolMismatch downgrades a protocol version mismatch from a Start error to a warning
+	// logged to Logger.
+	AllowProtocolMismatch bool
+	// EventQueueSize, when positive, delivers each session's events to its handlers on a
+	// separate goroutine through a queue of this many events, so a slow handler doesn't
+	// stall RPC traffic. Zero (default) calls handlers synchronously as events arrive.
+	EventQueueSize int
+	// EventOverflow decides what happens when a session's event queue is full (default: block)
+	EventOverflow EventOverflowPolicy
+	// PanicHandler is called when a session event handler or the OnProcessExit handler
+	// panics, e.g. to report the panic to an error tracker. By default the panic value
+	// and stack are logged to Logger as an error.
+	PanicHandler PanicHandler
+	// RepanicOnHandlerPanic re-raises event handler panics after PanicHandler has been
+	// called, crashing the program as an unrecovered panic would
+	RepanicOnHandlerPanic bool
+	// EventLog, if set, receives every session event as one JSON line (an
+	// [EventLogEntry] with the session ID and receive time), e.g. an audit log file.
+	// Read it back with [ReadEventLog].
+	EventLog io.Writer
+	// Metrics, if set, receives counts of active sessions, turns, tool calls, permission
+	// denials, and tokens, and tool call durations; see [Metrics]
+	Metrics Metrics
+}
+
+// HandlerPanic describes a panic recovered from a session event handler
+type HandlerPanic struct {
+	SessionID string
+	// Event is the event the handler was called with
+	Event SessionEvent
+	// HandlerID identifies the handler by subscription order within the session
+	HandlerID uint64
+	// HandlerName is the handler's function name, as reported by the runtime
+	HandlerName string
+	// Value is the value passed to panic
+	Value any
+	// Stack is the goroutine stack trace at the time of the panic
+	Stack []byte
 }
 
+// PanicHandler receives panics recovered from session event handlers
+type PanicHandler func(p HandlerPanic)
+
+// TraceDirection tells whether a traced JSON-RPC message was sent or received
+type TraceDirection = jsonrpc2.TraceDirection
+
+const (
+	// TraceSend marks a message sent to the CLI server
+	TraceSend = jsonrpc2.TraceSend
+	// TraceReceive marks a message received from the CLI server
+	TraceReceive = jsonrpc2.TraceReceive
+)
+
+// TraceFunc observes raw JSON-RPC messages; see [ClientOptions.Trace]
+type TraceFunc = jsonrpc2.TraceFunc
+
+// MessageFraming is how JSON-RPC messages are delimited on a byte stream
+type MessageFraming string
+
+const (
+	// FramingContentLength prefixes each message with an LSP-style Content-Length header
+	FramingContentLength MessageFraming = "content-length"
+	// FramingNDJSON writes each message as one line of newline-delimited JSON
+	FramingNDJSON MessageFraming = "ndjson"
+)
+
+// CLISource is a place the client looks for the Copilot CLI executable
+type CLISource string
+
+const (
+	// CLISourceOption uses ClientOptions.CLIPath
+	CLISourceOption CLISource = "option"
+	// CLISourceEnv uses the COPILOT_CLI_PATH environment variable
+	CLISourceEnv CLISource = "env"
+	// CLISourcePath looks up "copilot" in the directories named by PATH
+	CLISourcePath CLISource = "path"
+)
+
+// CLIResolution is the CLI executable the client starts and where it was found
+type CLIResolution struct {
+	Path   string
+	Source CLISource
+}
+
+// EventOverflowPolicy decides what happens to an event that arrives while a session's
+// event queue is full
+type EventOverflowPolicy string
+
+const (
+	// EventOverflowBlock waits for room in the queue, pausing the connection until then
+	EventOverflowBlock EventOverflowPolicy = "block"
+	// EventOverflowDrop discards the event
+	EventOverflowDrop EventOverflowPolicy = "drop"
+	// EventOverflowError discards the event and later delivers a session.error event with
+	// ErrorType "eventQueueOverflow" reporting how many events were dropped
+	EventOverflowError EventOverflowPolicy = "error"
+)
+
+// TokenProvider supplies GitHub tokens for authenticating the CLI server
+type TokenProvider interface {
+	// GetToken returns a currently valid GitHub token
+	GetToken(ctx context.Context) (string, error)
+}
+
+// TokenProviderFunc adapts an ordinary function to the TokenProvider interface
+type TokenProviderFunc func(ctx context.Context) (string, error)
+
+// GetToken calls f(ctx)
+func (f TokenProviderFunc) GetToken(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// ProcessExitEvent describes an unexpected exit of the CLI server process
+type ProcessExitEvent struct {
+	// ExitCode is the process exit code, or -1 if the process was terminated by a signal
+	ExitCode int
+	// Stderr contains the last lines the process wrote to stderr before exiting
+	Stderr []string
+	// Err is set if waiting for the process failed for a reason other than a non-zero exit
+	Err error
+}
+
+// ProcessExitHandler is a callback for unexpected CLI process exits
+type ProcessExitHandler func(event ProcessExitEvent)
+
 // Bool returns a pointer to the given bool value.
 // Use for setting AutoStart or AutoRestart: AutoStart: Bool(false)
 func Bool(v bool) *bool {
@@ -98,6 +290,71 @@ type PermissionRequest struct {
 	Extra      map[string]any `json:"-"` // Additional fields vary by kind
 }
 
+// UnmarshalJSON decodes Kind and ToolCallID and collects all other fields into Extra
+func (r *PermissionRequest) UnmarshalJSON(data []byte) error {
+	type plain PermissionRequest
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	delete(fields, "kind")
+	delete(fields, "toolCallId")
+	r.Extra = fields
+	return nil
+}
+
+// ShellPermissionRequest holds the fields of a "shell" permission request
+type ShellPermissionRequest struct {
+	// FullCommandText is the complete command line to be run
+	FullCommandText string `json:"fullCommandText"`
+	// Intention describes why the agent wants to run the command
+	Intention string `json:"intention,omitempty"`
+	// Commands lists the individual commands in the command line
+	Commands                []ShellCommand `json:"commands,omitempty"`
+	PossiblePaths           []string       `json:"possiblePaths,omitempty"`
+	PossibleURLs            []string       `json:"possibleUrls,omitempty"`
+	HasWriteFileRedirection bool           `json:"hasWriteFileRedirection,omitempty"`
+	Warning                 string         `json:"warning,omitempty"`
+}
+
+// ShellCommand is one command within a shell permission request
+type ShellCommand struct {
+	Identifier string `json:"identifier"`
+	ReadOnly   bool   `json:"readOnly"`
+}
+
+// WritePermissionRequest holds the fields of a "write" permission request
+type WritePermissionRequest struct {
+	FileName        string `json:"fileName"`
+	Intention       string `json:"intention,omitempty"`
+	Diff            string `json:"diff,omitempty"`
+	NewFileContents string `json:"newFileContents,omitempty"`
+}
+
+// ReadPermissionRequest holds the fields of a "read" permission request
+type ReadPermissionRequest struct {
+	Path      string `json:"path"`
+	Intention string `json:"intention,omitempty"`
+}
+
+// MCPPermissionRequest holds the fields of an "mcp" permission request
+type MCPPermissionRequest struct {
+	ServerName string `json:"serverName"`
+	ToolName   string `json:"toolName"`
+	ToolTitle  string `json:"toolTitle,omitempty"`
+	Args       any    `json:"args,omitempty"`
+	ReadOnly   bool   `json:"readOnly,omitempty"`
+}
+
+// URLPermissionRequest holds the fields of a "url" permission request
+type URLPermissionRequest struct {
+	URL       string `json:"url"`
+	Intention string `json:"intention,omitempty"`
+}
+
 // PermissionRequestResult represents the result of a permission request
 type PermissionRequestResult struct {
 	Kind  string `json:"kind"`
@@ -106,13 +363,63 @@ type PermissionRequestResult struct {
 
 // PermissionHandler executes a permission request
 // The handler should return a PermissionRequestResult. Returning an error denies the permission.
-type PermissionHandler func(request PermissionRequest, invocation PermissionInvocation) (PermissionRequestResult, error)
+// ctx is cancelled when the session is aborted or destroyed, or when the session's
+// PermissionTimeout elapses.
+type PermissionHandler func(ctx context.Context, request PermissionRequest, invocation PermissionInvocation) (PermissionRequestResult, error)
 
 // PermissionInvocation provides context about a permission request
 type PermissionInvocation struct {
 	SessionID string
 }
 
+// PermissionAction is the decision a PermissionRule makes
+type PermissionAction string
+
+const (
+	PermissionAllow PermissionAction = "allow"
+	PermissionDeny  PermissionAction = "deny"
+)
+
+// PermissionRule matches permission requests. Empty conditions match anything.
+type PermissionRule struct {
+	// Kinds restricts the rule to these request kinds: "shell", "write", "read", "mcp", "url"
+	Kinds []string `json:"kinds,omitempty"`
+	// ServerName is a glob matched against the MCP server name of "mcp" requests
+	ServerName string `json:"serverName,omitempty"`
+	// ToolName is a glob matched against the MCP tool name of "mcp" requests
+	ToolName string `json:"toolName,omitempty"`
+	// PathGlob is matched against the file paths a request touches; "**" spans directories
+	PathGlob string `json:"pathGlob,omitempty"`
+	// CommandPattern is a regular expression matched against the full command of "shell" requests
+	CommandPattern string `json:"commandPattern,omitempty"`
+	// Action is the decision for matching requests
+	Action PermissionAction `json:"action"`
+}
+
+// PermissionStore persists "always allow" rules recorded by a PermissionPolicy
+type PermissionStore interface {
+	Load() ([]PermissionRule, error)
+	Save(rules []PermissionRule) error
+}
+
+// PermissionPolicy is a declarative permission handler. Use [PermissionPolicy.Handle]
+// as SessionConfig.OnPermissionRequest.
+type PermissionPolicy struct {
+	// Rules are evaluated deny rules first, then the rest in order; the first matching rule decides
+	Rules []PermissionRule
+	// Fallback, if set, decides requests that no rule matches, e.g. by prompting the user
+	Fallback PermissionHandler
+	// Default decides requests no rule matches when Fallback is nil.
+	// Empty denies them as having no approval rule.
+	Default PermissionAction
+	// Store persists rules added with AlwaysAllow. Optional.
+	Store PermissionStore
+
+	mu        sync.Mutex
+	persisted []PermissionRule
+	loaded    bool
+}
+
 // UserInputRequest represents a request for user input from the agent
 type UserInputRequest struct {
 	Question      string
@@ -128,7 +435,25 @@ type UserInputResponse struct {
 
 // UserInputHandler handles user input requests from the agent
 // The handler should return a UserInputResponse. Returning an error fails the request.
-type UserInputHandler func(request UserInputRequest, invocation UserInputInvocation) (UserInputResponse, error)
+// ctx is cancelled when the session is aborted or destroyed. Use [AsyncUserInputHandler]
+// to answer from elsewhere, such as a GUI event loop.
+type UserInputHandler func(ctx context.Context, request UserInputRequest, invocation UserInputInvocation) (UserInputResponse, error)
+
+// PendingUserInput is a user input request waiting for an answer.
+// Exactly one of Respond or Fail takes effect; later calls are ignored.
+type PendingUserInput struct {
+	Request    UserInputRequest
+	Invocation UserInputInvocation
+
+	ctx     context.Context
+	once    sync.Once
+	outcome chan userInputOutcome
+}
+
+type userInputOutcome struct {
+	response UserInputResponse
+	err      error
+}
 
 // UserInputInvocation provides context about a user input request
 type UserInputInvocation struct {
@@ -262,30 +587,6 @@ type SessionHooks struct {
 	OnErrorOccurred       ErrorOccurredHandler
 }
 
-// MCPLocalServerConfig configures a local/stdio MCP server
-type MCPLocalServerConfig struct {
-	Tools   []string          `json:"tools"`
-	Type    string            `json:"type,omitempty"` // "local" or "stdio"
-	Timeout int               `json:"timeout,omitempty"`
-	Command string            `json:"command"`
-	Args    []string          `json:"args"`
-	Env     map[string]string `json:"env,omitempty"`
-	Cwd     string            `json:"cwd,omitempty"`
-}
-
-// MCPRemoteServerConfig configures a remote MCP server (HTTP or SSE)
-type MCPRemoteServerConfig struct {
-	Tools   []string          `json:"tools"`
-	Type    string            `json:"type"` // "http" or "sse"
-	Timeout int               `json:"timeout,omitempty"`
-	URL     string            `json:"url"`
-	Headers map[string]string `json:"headers,omitempty"`
-}
-
-// MCPServerConfig can be either MCPLocalServerConfig or MCPRemoteServerConfig
-// Use a map[string]any for flexibility, or create separate configs
-type MCPServerConfig map[string]any
-
 // CustomAgentConfig configures a custom agent
 type CustomAgentConfig struct {
 	// Name is the unique name of the custom agent
@@ -343,6 +644,11 @@ type SessionConfig struct {
 	ExcludedTools []string
 	// OnPermissionRequest is a handler for permission requests from the server
 	OnPermissionRequest PermissionHandler
+	// PermissionTimeout limits how long OnPermissionRequest may take to decide.
+	// Zero means no timeout.
+	PermissionTimeout time.Duration
+	// PermissionTimeoutAction is the decision when PermissionTimeout elapses (default: deny)
+	PermissionTimeoutAction PermissionAction
 	// OnUserInputRequest is a handler for user input requests from the agent (enables ask_user tool)
 	OnUserInputRequest UserInputHandler
 	// Hooks configures hook handlers for session lifecycle events
@@ -367,6 +673,9 @@ type SessionConfig struct {
 	// InfiniteSessions configures infinite sessions for persistent workspaces and automatic compaction.
 	// When enabled (default), sessions automatically manage context limits and persist state.
 	InfiniteSessions *InfiniteSessionConfig
+	// Labels are key/value pairs attached to the session, e.g. the owning user or project.
+	// Use ListSessionsOptions.Labels to find sessions by label.
+	Labels map[string]string
 }
 
 // Tool describes a caller-implemented tool that can be invoked by Copilot
@@ -375,6 +684,14 @@ type Tool struct {
 	Description string         `json:"description,omitempty"`
 	Parameters  map[string]any `json:"parameters,omitempty"`
 	Handler     ToolHandler    `json:"-"`
+	// Timeout limits how long a single invocation may run, including time spent waiting
+	// for a concurrency slot. On timeout the handler's context is cancelled and a failure
+	// result is returned to the model. Zero means no timeout.
+	Timeout time.Duration `json:"-"`
+	// MaxConcurrent limits how many invocations of this tool run at once; further calls
+	// wait for a slot. Calls started before a tool update still count against the limit
+	// of the tool that replaces it. Zero means no limit.
+	MaxConcurrent int `json:"-"`
 }
 
 // ToolInvocation describes a tool call initiated by Copilot
@@ -387,7 +704,21 @@ type ToolInvocation struct {
 
 // ToolHandler executes a tool invocation.
 // The handler should return a ToolResult. Returning an error marks the tool execution as a failure.
-type ToolHandler func(invocation ToolInvocation) (ToolResult, error)
+// ctx is cancelled when the session is aborted or destroyed; long-running handlers should
+// stop promptly when it is done.
+type ToolHandler func(ctx context.Context, invocation ToolInvocation) (ToolResult, error)
+
+// ToolProgress is an intermediate status update from a running tool, reported with
+// [ReportToolProgress]
+type ToolProgress struct {
+	// Message is a short human-readable status, e.g. "Installing dependencies"
+	Message string
+	// Percent is the completion percentage from 0 to 100, or nil if unknown. It is
+	// appended to Message in the tool.execution_progress event, e.g. "Compiling (50%)".
+	Percent *float64
+	// Output is partial output produced since the last update, e.g. log lines
+	Output string
+}
 
 // ToolResult represents the result of a tool invocation.
 type ToolResult struct {
@@ -420,6 +751,11 @@ type ResumeSessionConfig struct {
 	ReasoningEffort string
 	// OnPermissionRequest is a handler for permission requests from the server
 	OnPermissionRequest PermissionHandler
+	// PermissionTimeout limits how long OnPermissionRequest may take to decide.
+	// Zero means no timeout.
+	PermissionTimeout time.Duration
+	// PermissionTimeoutAction is the decision when PermissionTimeout elapses (default: deny)
+	PermissionTimeoutAction PermissionAction
 	// OnUserInputRequest is a handler for user input requests from the agent (enables ask_user tool)
 	OnUserInputRequest UserInputHandler
 	// Hooks configures hook handlers for session lifecycle events
@@ -446,6 +782,15 @@ type ResumeSessionConfig struct {
 	// DisableResume, when true, skips emitting the session.resume event.
 	// Useful for reconnecting to a session without triggering resume-related side effects.
 	DisableResume bool
+	// Labels are added to the session's labels, replacing values of existing keys
+	Labels map[string]string
+	// OnEvent is subscribed to the session's events before it is resumed, so that it
+	// receives the replayed history and no live events are missed
+	OnEvent SessionEventHandler
+	// ReplayHistory delivers the session's existing events to OnEvent before any live
+	// events, so past and new messages can share one rendering path. During the replay,
+	// [Session.IsReplaying] returns true.
+	ReplayHistory bool
 }
 
 // ProviderConfig configures a custom model provider
@@ -488,6 +833,96 @@ type MessageOptions struct {
 	Attachments []Attachment
 	// Mode is the message delivery mode (default: "enqueue")
 	Mode string
+	// Content is an ordered list of content blocks (text, images, file references)
+	// making up the message. If Prompt is also set, it is sent as a leading text block.
+	Content []ContentBlock
+	// AbortOnCancel aborts the agent turn started by this message when the context
+	// passed to Send is cancelled before the session becomes idle
+	AbortOnCancel bool
+}
+
+// ContentBlockType identifies the kind of a message content block
+type ContentBlockType string
+
+const (
+	ContentBlockText  ContentBlockType = "text"
+	ContentBlockImage ContentBlockType = "image"
+	ContentBlockFile  ContentBlockType = "file"
+	// ContentBlockResource is named in-memory content that does not exist on disk
+	ContentBlockResource ContentBlockType = "resource"
+)
+
+// ContentBlock is one part of a multi-part user message.
+// Use TextBlock, ImageBlock, or FileBlock to construct blocks.
+type ContentBlock struct {
+	Type ContentBlockType `json:"type"`
+	// Text is the content of a text block, or of a text resource block
+	Text string `json:"text,omitempty"`
+	// Data is the base64-encoded content of an image block, or of a binary resource block
+	Data string `json:"data,omitempty"`
+	// MimeType is the media type of an image or resource block, e.g. "image/png"
+	MimeType string `json:"mimeType,omitempty"`
+	// Path is the file path of a file block
+	Path string `json:"path,omitempty"`
+	// Name identifies a resource block to the model, e.g. "changes.diff"
+	Name string `json:"name,omitempty"`
+}
+
+// TextBlock returns a text content block
+func TextBlock(text string) ContentBlock {
+	return ContentBlock{Type: ContentBlockText, Text: text}
+}
+
+// ImageBlock returns an image content block with the given raw image bytes and media type
+func ImageBlock(data []byte, mimeType string) ContentBlock {
+	return ContentBlock{Type: ContentBlockImage, Data: base64.StdEncoding.EncodeToString(data), MimeType: mimeType}
+}
+
+// FileBlock returns a content block referencing a file on disk
+func FileBlock(path string) ContentBlock {
+	return ContentBlock{Type: ContentBlockFile, Path: path}
+}
+
+// ResourceBlock returns a content block carrying named in-memory text, such as a
+// generated diff, without writing it to disk
+func ResourceBlock(name, text string) ContentBlock {
+	return ContentBlock{Type: ContentBlockResource, Name: name, Text: text, MimeType: "text/plain"}
+}
+
+// validate reports whether the block has the fields its type requires
+func (b ContentBlock) validate() error {
+	switch b.Type {
+	case ContentBlockText:
+		return nil
+	case ContentBlockImage:
+		if b.Data == "" || b.MimeType == "" {
+			return fmt.Errorf("image content block requires Data and MimeType")
+		}
+	case ContentBlockFile:
+		if b.Path == "" {
+			return fmt.Errorf("file content block requires Path")
+		}
+	case ContentBlockResource:
+		if b.Name == "" {
+			return fmt.Errorf("resource content block requires Name")
+		}
+		if b.Text == "" && b.Data == "" {
+			return fmt.Errorf("resource content block requires Text or Data")
+		}
+	default:
+		return fmt.Errorf("unknown content block type %q", b.Type)
+	}
+	return nil
+}
+
+// StreamChunk is an incremental piece of assistant output from [Session.SendAndStream]
+type StreamChunk struct {
+	// Content is the delta text
+	Content string
+	// Reasoning is true for reasoning deltas and false for message content deltas
+	Reasoning bool
+	// MessageID identifies the assistant message the delta belongs to, if known
+	MessageID string
 }
 
 // SessionEventHandler is a callback for session events
@@ -546,8 +981,10 @@ type SessionMetadata struct {
 	SessionID    string  `json:"sessionId"`
 	StartTime    string  `json:"startTime"`
 	ModifiedTime string  `json:"modifiedTime"`
-	Summary      *string `json:"summary,omitempty"`
+	Summary      *string `json:"summary,omitempty"` // Set with [Session.SetSummary]
 	IsRemote     bool    `json:"isRemote"`
+	// Labels are the key/value pairs attached with SessionConfig.Labels
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // SessionLifecycleEventType represents the type of session lifecycle event
@@ -610,6 +1047,7 @@ type createSessionRequest struct {
 	SkillDirectories  []string                   `json:"skillDirectories,omitempty"`
 	DisabledSkills    []string                   `json:"disabledSkills,omitempty"`
 	InfiniteSessions  *InfiniteSessionConfig     `json:"infiniteSessions,omitempty"`
+	Labels            map[string]string          `json:"labels,omitempty"`
 }
 
 // createSessionResponse is the response from session.create
@@ -640,6 +1078,7 @@ type resumeSessionRequest struct {
 	SkillDirectories  []string                   `json:"skillDirectories,omitempty"`
 	DisabledSkills    []string                   `json:"disabledSkills,omitempty"`
 	InfiniteSessions  *InfiniteSessionConfig     `json:"infiniteSessions,omitempty"`
+	Labels            map[string]string          `json:"labels,omitempty"`
 }
 
 // resumeSessionResponse is the response from session.resume
@@ -655,7 +1094,9 @@ type hooksInvokeRequest struct {
 }
 
 // listSessionsRequest is the request for session.list
-type listSessionsRequest struct{}
+type listSessionsRequest struct {
+	Labels map[string]string `json:"labels,omitempty"`
+}
 
 // listSessionsResponse is the response from session.list
 type listSessionsResponse struct {
@@ -725,6 +1166,202 @@ type GetAuthStatusResponse struct {
 	StatusMessage   *string `json:"statusMessage,omitempty"`
 }
 
+// DeviceCode is the device-code OAuth challenge the user must complete to log in
+type DeviceCode struct {
+	// UserCode is the code the user enters at VerificationURI
+	UserCode string `json:"userCode"`
+	// VerificationURI is the URL where the user enters UserCode
+	VerificationURI string `json:"verificationUri"`
+	// ExpiresIn is the number of seconds until the code expires
+	ExpiresIn int `json:"expiresIn,omitempty"`
+	// Interval is the minimum number of seconds between status polls
+	Interval int `json:"interval,omitempty"`
+}
+
+// LoginOptions configures a device-code login
+type LoginOptions struct {
+	// Host is the GitHub host to authenticate against (default: server default, usually github.com)
+	Host string
+	// OnDeviceCode is called with the device code once the flow has started
+	OnDeviceCode func(code DeviceCode)
+	// PollInterval overrides how often the authentication status is polled
+	// (default: the interval returned by the server, or 5 seconds)
+	PollInterval time.Duration
+}
+
+// authLoginRequest is the request for auth.login
+type authLoginRequest struct {
+	Host string `json:"host,omitempty"`
+}
+
+// authSetTokenRequest is the request for auth.setToken
+type authSetTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// authLogoutRequest is the request for auth.logout
+type authLogoutRequest struct{}
+
+// QuotaWatchOptions configures [Client.WatchQuota]
+type QuotaWatchOptions struct {
+	// Interval between quota polls (default: 1 minute)
+	Interval time.Duration
+	// Thresholds are remaining percentages (0-100) to notify about, e.g. []float64{50, 20, 5}
+	Thresholds []float64
+	// OnThreshold is called when a quota's remaining percentage drops to or below a threshold
+	OnThreshold func(event QuotaThresholdEvent)
+	// OnError is called when a poll fails; polling continues afterwards. If nil, the
+	// error is logged to ClientOptions.Logger.
+	OnError func(err error)
+}
+
+// QuotaThresholdEvent reports that a quota crossed a configured threshold
+type QuotaThresholdEvent struct {
+	// QuotaType identifies the quota, e.g. "premium_interactions"
+	QuotaType string
+	// Threshold is the remaining percentage that was crossed
+	Threshold float64
+	// Snapshot is the quota state that crossed the threshold
+	Snapshot QuotaSnapshot
+}
+
+// GetMessagesOptions filters and paginates session history
+type GetMessagesOptions struct {
+	// Types restricts results to these event types. Empty means all types.
+	Types []SessionEventType
+	// Since excludes events with an earlier timestamp
+	Since time.Time
+	// After is a cursor: only events following the event with this ID are returned.
+	// Use the ID of the last event of the previous page.
+	After string
+	// Limit is the maximum number of events to return. Zero means no limit.
+	Limit int
+}
+
+// ListSessionsOptions filters the sessions returned by [Client.ListSessionsWithOptions]
+type ListSessionsOptions struct {
+	// Labels restricts results to sessions having all of these labels with equal values
+	Labels map[string]string
+}
+
+// Checkpoint describes a snapshot of session state in the workspace checkpoints/
+// directory, as returned by [Session.ListCheckpoints]
+type Checkpoint struct {
+	// Number is the checkpoint's sequence number within the session, from its file name
+	Number int
+	// Path is the checkpoint file's path
+	Path string
+	// CreatedAt is when the checkpoint file was written
+	CreatedAt time.Time
+}
+
+// CompactionResult describes the outcome of [Session.Compact]
+type CompactionResult struct {
+	// Summary is the summary that replaced the compacted messages
+	Summary              string `json:"summaryContent,omitempty"`
+	PreCompactionTokens  int    `json:"preCompactionTokens"`
+	PostCompactionTokens int    `json:"postCompactionTokens"`
+	// TokensRemoved is the number of context tokens freed
+	TokensRemoved   int `json:"tokensRemoved"`
+	MessagesRemoved int `json:"messagesRemoved"`
+	// CheckpointNumber is the checkpoint written for the compacted history, if any
+	CheckpointNumber int `json:"checkpointNumber,omitempty"`
+}
+
+// Workspace reads and writes files in the files/ directory of a session's
+// infinite-session workspace. Obtain one with [Session.Workspace].
+type Workspace struct {
+	dir string
+}
+
+// TranscriptFormat is the output format for [Session.ExportTranscript]
+type TranscriptFormat string
+
+const (
+	TranscriptMarkdown TranscriptFormat = "markdown"
+	TranscriptJSON     TranscriptFormat = "json"
+	TranscriptHTML     TranscriptFormat = "html"
+)
+
+// TranscriptEntry is one item of an exported transcript
+type TranscriptEntry struct {
+	// Role is "user", "assistant", or "tool"
+	Role      string    `json:"role"`
+	Content   string    `json:"content,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// ToolName, ToolCallID, Arguments, Output, and Success are set for tool entries
+	ToolName   string `json:"toolName,omitempty"`
+	ToolCallID string `json:"toolCallId,omitempty"`
+	Arguments  any    `json:"arguments,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Success    *bool  `json:"success,omitempty"`
+}
+
+// TurnResult describes one turn, from sending a message until the session is idle
+type TurnResult struct {
+	// Message is the final assistant message, or nil if none was received
+	Message *SessionEvent
+	// Events holds every event the session emitted during the turn, in order
+	Events []SessionEvent
+	// ToolCalls lists the tools the agent executed, in the order they started
+	ToolCalls []TurnToolCall
+	// Reasoning is the model's reasoning text, if the model exposed it
+	Reasoning string
+	// Usage totals the assistant.usage events of the turn
+	Usage UsageTotals
+}
+
+// TurnToolCall is a tool execution that happened during a turn
+type TurnToolCall struct {
+	ToolCallID string
+	ToolName   string
+	Arguments  any
+	// Completed is false if the turn ended before the tool finished
+	Completed bool
+	Success   bool
+	// Output is the tool's result content, or its error message if it failed
+	Output string
+}
+
+// UsageTotals aggregates token usage across model calls
+type UsageTotals struct {
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	// Requests is the number of model calls
+	Requests int
+	// Cost is the premium request cost reported by the CLI
+	Cost float64
+}
+
+// TurnUsage is the usage attributed to a single assistant turn
+type TurnUsage struct {
+	TurnID string
+	// Model is the model that served the turn; the last one if it used several
+	Model string
+	UsageTotals
+	// ByModel breaks the turn's totals down by model ID
+	ByModel map[string]UsageTotals
+}
+
+// SessionUsage is the usage accumulated by a session since it was created or resumed
+type SessionUsage struct {
+	UsageTotals
+	// ByModel breaks the totals down by model ID
+	ByModel map[string]UsageTotals
+	// Turns lists per-turn usage in the order the turns started
+	Turns []TurnUsage
+}
+
+// getQuotaRequest is the request for account.getQuota
+type getQuotaRequest struct{}
+
+// getQuotaResponse is the response from account.getQuota
+type getQuotaResponse struct {
+	QuotaSnapshots map[string]QuotaSnapshot `json:"quotaSnapshots"`
+}
+
 // listModelsRequest is the request for models.list
 type listModelsRequest struct{}
 
@@ -735,7 +1372,11 @@ type listModelsResponse struct {
 
 // sessionGetMessagesRequest is the request for session.getMessages
 type sessionGetMessagesRequest struct {
-	SessionID string `json:"sessionId"`
+	SessionID string             `json:"sessionId"`
+	Types     []SessionEventType `json:"types,omitempty"`
+	Since     *time.Time         `json:"since,omitempty"`
+	After     string             `json:"after,omitempty"`
+	Limit     int                `json:"limit,omitempty"`
 }
 
 // sessionGetMessagesResponse is the response from session.getMessages
@@ -753,11 +1394,35 @@ type sessionAbortRequest struct {
 	SessionID string `json:"sessionId"`
 }
 
+// sessionCompactRequest is the request for session.compact
+type sessionCompactRequest struct {
+	SessionID string `json:"sessionId"`
+}
+
+// sessionSetSummaryRequest is the request for session.setSummary
+type sessionSetSummaryRequest struct {
+	SessionID string `json:"sessionId"`
+	Summary   string `json:"summary"`
+}
+
+// sessionUpdateToolsRequest is the request for session.updateTools
+type sessionUpdateToolsRequest struct {
+	SessionID string `json:"sessionId"`
+	Tools     []Tool `json:"tools"`
+}
+
+// sessionUpdateHooksRequest is the request for session.updateHooks
+type sessionUpdateHooksRequest struct {
+	SessionID string `json:"sessionId"`
+	Hooks     bool   `json:"hooks"`
+}
+
 type sessionSendRequest struct {
-	SessionID   string       `json:"sessionId"`
-	Prompt      string       `json:"prompt"`
-	Attachments []Attachment `json:"attachments,omitempty"`
-	Mode        string       `json:"mode,omitempty"`
+	SessionID   string         `json:"sessionId"`
+	Prompt      string         `json:"prompt"`
+	Content     []ContentBlock `json:"content,omitempty"`
+	Attachments []Attachment   `json:"attachments,omitempty"`
+	Mode        string         `json:"mode,omitempty"`
 }
 
 // sessionSendResponse is the response from session.send
@@ -0,0 +1,145 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import "maps"
+
+// Usage returns the token usage and premium request cost accumulated from the
+// assistant.usage events this session has received, in total, per model, and per turn.
+//
+// Example:
+//
+//	session.SendAndWait(ctx, copilot.MessageOptions{Prompt: "Summarize README.md"})
+//	usage := session.Usage()
+//	fmt.Printf("tokens in=%d out=%d, premium requests=%.2f\n",
+//	    usage.InputTokens, usage.OutputTokens, usage.Cost)
+func (s *Session) Usage() SessionUsage {
+	s.usageMux.Lock()
+	defer s.usageMux.Unlock()
+
+	turns := make([]TurnUsage, len(s.usage.Turns))
+	for i, turn := range s.usage.Turns {
+		turn.ByModel = maps.Clone(turn.ByModel)
+		turns[i] = turn
+	}
+	return SessionUsage{
+		UsageTotals: s.usage.UsageTotals,
+		ByModel:     maps.Clone(s.usage.ByModel),
+		Turns:       turns,
+	}
+}
+
+// EstimatedCost estimates the premium requests consumed using each model's billing
+// multiplier from models, as returned by [Client.ListModels]. Models without billing
+// information count as one premium request per call.
+func (u SessionUsage) EstimatedCost(models []ModelInfo) float64 {
+	return estimateCost(u.ByModel, models)
+}
+
+// EstimatedCost estimates the premium requests consumed by this turn, like
+// [SessionUsage.EstimatedCost].
+//
+// Example:
+//
+//	for _, turn := range session.Usage().Turns {
+//	    chargeback.Record(tenant, turn.TurnID, turn.Model, turn.EstimatedCost(models))
+//	}
+func (t TurnUsage) EstimatedCost(models []ModelInfo) float64 {
+	return estimateCost(t.ByModel, models)
+}
+
+// estimateCost sums the requests in byModel weighted by each model's billing multiplier
+func estimateCost(byModel map[string]UsageTotals, models []ModelInfo) float64 {
+	multipliers := make(map[string]float64, len(models))
+	for _, m := range models {
+		if m.Billing != nil {
+			multipliers[m.ID] = m.Billing.Multiplier
+		}
+	}
+
+	var cost float64
+	for model, totals := range byModel {
+		multiplier, ok := multipliers[model]
+		if !ok {
+			multiplier = 1
+		}
+		cost += float64(totals.Requests) * multiplier
+	}
+	return cost
+}
+
+// recordUsage updates the session's usage from turn and usage events
+func (s *Session) recordUsage(event SessionEvent) {
+	switch event.Type {
+	case AssistantTurnStart:
+		turn := TurnUsage{}
+		if event.Data.TurnID != nil {
+			turn.TurnID = *event.Data.TurnID
+		}
+		s.usageMux.Lock()
+		s.usage.Turns = append(s.usage.Turns, turn)
+		s.usageMux.Unlock()
+
+	case AssistantUsage:
+		delta := usageFromEvent(event)
+		model := ""
+		if event.Data.Model != nil {
+			model = *event.Data.Model
+		}
+
+		s.usageMux.Lock()
+		defer s.usageMux.Unlock()
+		s.usage.UsageTotals.add(delta)
+		byModel := s.usage.ByModel[model]
+		byModel.add(delta)
+		s.usage.ByModel[model] = byModel
+		if len(s.usage.Turns) == 0 {
+			s.usage.Turns = append(s.usage.Turns, TurnUsage{})
+		}
+		s.usage.Turns[len(s.usage.Turns)-1].record(model, delta)
+	}
+}
+
+// usageFromEvent returns the usage reported by an assistant.usage event
+func usageFromEvent(event SessionEvent) UsageTotals {
+	delta := UsageTotals{Requests: 1}
+	if event.Data.InputTokens != nil {
+		delta.InputTokens = int(*event.Data.InputTokens)
+	}
+	if event.Data.OutputTokens != nil {
+		delta.OutputTokens = int(*event.Data.OutputTokens)
+	}
+	if event.Data.CacheReadTokens != nil {
+		delta.CacheReadTokens = int(*event.Data.CacheReadTokens)
+	}
+	if event.Data.CacheWriteTokens != nil {
+		delta.CacheWriteTokens = int(*event.Data.CacheWriteTokens)
+	}
+	if event.Data.Cost != nil {
+		delta.Cost = *event.Data.Cost
+	}
+	return delta
+}
+
+// record adds usage reported by model to the turn
+func (t *TurnUsage) record(model string, delta UsageTotals) {
+	t.Model = model
+	t.UsageTotals.add(delta)
+	if t.ByModel == nil {
+		t.ByModel = make(map[string]UsageTotals)
+	}
+	byModel := t.ByModel[model]
+	byModel.add(delta)
+	t.ByModel[model] = byModel
+}
+
+func (t *UsageTotals) add(other UsageTotals) {
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
+	t.CacheReadTokens += other.CacheReadTokens
+	t.CacheWriteTokens += other.CacheWriteTokens
+	t.Requests += other.Requests
+	t.Cost += other.Cost
+}
@@ -0,0 +1,79 @@
+package copilot
+
+import "testing"
+
+func TestSession_Usage(t *testing.T) {
+	usageEvent := func(model string, input, output, cost float64) SessionEvent {
+		return SessionEvent{Type: AssistantUsage, Data: Data{
+			Model:        &model,
+			InputTokens:  Float64(input),
+			OutputTokens: Float64(output),
+			Cost:         Float64(cost),
+		}}
+	}
+	turnStart := func(id string) SessionEvent {
+		return SessionEvent{Type: AssistantTurnStart, Data: Data{TurnID: &id}}
+	}
+
+	session := newSession("session-1", nil, "")
+	session.dispatchEvent(turnStart("t1"))
+	session.dispatchEvent(usageEvent("gpt-4.1", 100, 20, 0))
+	session.dispatchEvent(usageEvent("claude-sonnet-4", 50, 10, 1))
+	session.dispatchEvent(turnStart("t2"))
+	session.dispatchEvent(usageEvent("claude-sonnet-4", 30, 5, 1))
+
+	usage := session.Usage()
+
+	t.Run("aggregates session totals", func(t *testing.T) {
+		want := UsageTotals{InputTokens: 180, OutputTokens: 35, Requests: 3, Cost: 2}
+		if usage.UsageTotals != want {
+			t.Errorf("Expected %+v, got %+v", want, usage.UsageTotals)
+		}
+	})
+
+	t.Run("breaks down usage by model", func(t *testing.T) {
+		sonnet := usage.ByModel["claude-sonnet-4"]
+		if sonnet.InputTokens != 80 || sonnet.Requests != 2 {
+			t.Errorf("Unexpected claude-sonnet-4 totals: %+v", sonnet)
+		}
+	})
+
+	t.Run("attributes usage to turns", func(t *testing.T) {
+		if len(usage.Turns) != 2 {
+			t.Fatalf("Expected 2 turns, got %d", len(usage.Turns))
+		}
+		if usage.Turns[0].TurnID != "t1" || usage.Turns[0].Requests != 2 {
+			t.Errorf("Unexpected first turn: %+v", usage.Turns[0])
+		}
+		if usage.Turns[1].TurnID != "t2" || usage.Turns[1].InputTokens != 30 {
+			t.Errorf("Unexpected second turn: %+v", usage.Turns[1])
+		}
+		if usage.Turns[0].Model != "claude-sonnet-4" || usage.Turns[0].ByModel["gpt-4.1"].Requests != 1 {
+			t.Errorf("Expected the turn's models to be recorded, got %+v", usage.Turns[0])
+		}
+	})
+
+	t.Run("returns a snapshot", func(t *testing.T) {
+		usage.ByModel["gpt-4.1"] = UsageTotals{}
+		if session.Usage().ByModel["gpt-4.1"].Requests != 1 {
+			t.Error("Expected Usage to return a copy of the per-model totals")
+		}
+	})
+
+	t.Run("estimates cost from billing multipliers", func(t *testing.T) {
+		models := []ModelInfo{
+			{ID: "gpt-4.1", Billing: &ModelBilling{Multiplier: 0}},
+			{ID: "claude-sonnet-4", Billing: &ModelBilling{Multiplier: 1.5}},
+		}
+		if got := session.Usage().EstimatedCost(models); got != 3 {
+			t.Errorf("Expected estimated cost 3, got %v", got)
+		}
+		turns := session.Usage().Turns
+		if got := turns[0].EstimatedCost(models); got != 1.5 {
+			t.Errorf("Expected first turn cost 1.5, got %v", got)
+		}
+		if got := turns[1].EstimatedCost(models); got != 1.5 {
+			t.Errorf("Expected second turn cost 1.5, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import "context"
+
+// AsyncUserInputHandler adapts a callback that answers user input requests later into a
+// [UserInputHandler]. start is called with each request and should return promptly, e.g.
+// after posting a dialog to a GUI event loop; the answer is delivered by calling
+// Respond or Fail on the pending request from any goroutine. If the session is aborted
+// or destroyed first, the request fails and Done is closed.
+//
+// Example:
+//
+//	session, _ := client.CreateSession(ctx, &copilot.SessionConfig{
+//	    OnUserInputRequest: copilot.AsyncUserInputHandler(func(pending *copilot.PendingUserInput) {
+//	        ui.ShowQuestion(pending.Request.Question, func(answer string) {
+//	            pending.Respond(copilot.UserInputResponse{Answer: answer, WasFreeform: true})
+//	        })
+//	    }),
+//	})
+func AsyncUserInputHandler(start func(pending *PendingUserInput)) UserInputHandler {
+	return func(ctx context.Context, request UserInputRequest, invocation UserInputInvocation) (UserInputResponse, error) {
+		pending := &PendingUserInput{
+			Request:    request,
+			Invocation: invocation,
+			ctx:        ctx,
+			outcome:    make(chan userInputOutcome, 1),
+		}
+		start(pending)
+
+		select {
+		case o := <-pending.outcome:
+			return o.response, o.err
+		case <-ctx.Done():
+			pending.Fail(ctx.Err())
+			return UserInputResponse{}, ctx.Err()
+		}
+	}
+}
+
+// Respond answers the request with response
+func (p *PendingUserInput) Respond(response UserInputResponse) {
+	p.resolve(userInputOutcome{response: response})
+}
+
+// Fail rejects the request; the agent receives err as the request's error
+func (p *PendingUserInput) Fail(err error) {
+	p.resolve(userInputOutcome{err: err})
+}
+
+// Done returns a channel that is closed when the request no longer needs an answer
+// because the session was aborted or destroyed
+func (p *PendingUserInput) Done() <-chan struct{} {
+	return p.ctx.Done()
+}
+
+func (p *PendingUserInput) resolve(o userInputOutcome) {
+	p.once.Do(func() {
+		p.outcome <- o
+	})
+}
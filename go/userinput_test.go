@@ -0,0 +1,76 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAsyncUserInputHandler(t *testing.T) {
+	t.Run("should return the answer delivered later", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		session.registerUserInputHandler(AsyncUserInputHandler(func(pending *PendingUserInput) {
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				pending.Respond(UserInputResponse{Answer: pending.Request.Choices[1]})
+				pending.Respond(UserInputResponse{Answer: "ignored"})
+			}()
+		}))
+
+		response, err := session.handleUserInputRequest(t.Context(), UserInputRequest{Question: "Pick", Choices: []string{"a", "b"}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.Answer != "b" {
+			t.Errorf("Expected answer b, got %q", response.Answer)
+		}
+	})
+
+	t.Run("should return the error passed to Fail", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		dismissed := errors.New("dialog dismissed")
+		session.registerUserInputHandler(AsyncUserInputHandler(func(pending *PendingUserInput) {
+			pending.Fail(dismissed)
+		}))
+
+		if _, err := session.handleUserInputRequest(t.Context(), UserInputRequest{Question: "Continue?"}); !errors.Is(err, dismissed) {
+			t.Errorf("Expected dismissed error, got %v", err)
+		}
+	})
+
+	t.Run("should give up when the session is aborted", func(t *testing.T) {
+		session := newSession("session-1", nil, "")
+		started := make(chan *PendingUserInput, 1)
+		session.registerUserInputHandler(AsyncUserInputHandler(func(pending *PendingUserInput) {
+			started <- pending
+		}))
+
+		go func() {
+			<-started
+			session.dispatchEvent(SessionEvent{Type: Abort})
+		}()
+
+		_, err := session.handleUserInputRequest(t.Context(), UserInputRequest{Question: "Continue?"})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("should close Done when the request is abandoned", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var pending *PendingUserInput
+		handler := AsyncUserInputHandler(func(p *PendingUserInput) {
+			pending = p
+			cancel()
+		})
+
+		handler(ctx, UserInputRequest{Question: "Continue?"}, UserInputInvocation{})
+		select {
+		case <-pending.Done():
+		default:
+			t.Error("Expected Done to be closed")
+		}
+		pending.Respond(UserInputResponse{Answer: "late"}) // must not block
+	})
+}
@@ -0,0 +1,203 @@
+/*---------------------------------------------------------------------------------------------
+ *  Copyright (c) Microsoft Corporation. All rights reserved.
+ *--------------------------------------------------------------------------------------------*/
+
+package copilot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Workspace returns helpers for the files/ directory of the session workspace.
+// File names are slash-separated paths relative to that directory; names that would
+// escape it, including through symlinks, are rejected.
+//
+// The workspace lives on the machine running the CLI, so this is only useful when the
+// CLI runs locally. Returns an error if infinite sessions are not enabled for this session.
+//
+// Example:
+//
+//	ws, err := session.Workspace()
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	notes, err := ws.ReadFile("notes/todo.md")
+func (s *Session) Workspace() (*Workspace, error) {
+	if err := s.requireWorkspace(); err != nil {
+		return nil, err
+	}
+	return &Workspace{dir: filepath.Join(s.workspacePath, "files")}, nil
+}
+
+// Dir returns the absolute path of the workspace files/ directory
+func (w *Workspace) Dir() string {
+	return w.dir
+}
+
+// ListFiles returns the names of all regular files in the workspace, recursively,
+// in lexical order. A missing files/ directory yields no files.
+func (w *Workspace) ListFiles() ([]string, error) {
+	root, err := os.OpenRoot(w.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace: %w", err)
+	}
+	defer root.Close()
+
+	var names []string
+	err = fs.WalkDir(root.FS(), ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace files: %w", err)
+	}
+	return names, nil
+}
+
+// ReadFile returns the contents of the named workspace file
+func (w *Workspace) ReadFile(name string) ([]byte, error) {
+	name, err := cleanWorkspaceName(name)
+	if err != nil {
+		return nil, err
+	}
+	root, err := os.OpenRoot(w.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace: %w", err)
+	}
+	defer root.Close()
+
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace file: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace file: %w", err)
+	}
+	return data, nil
+}
+
+// WriteFile writes data to the named workspace file, creating it and any parent
+// directories as needed and truncating an existing file
+func (w *Workspace) WriteFile(name string, data []byte) error {
+	name, err := cleanWorkspaceName(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(w.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	root, err := os.OpenRoot(w.dir)
+	if err != nil {
+		return fmt.Errorf("failed to open workspace: %w", err)
+	}
+	defer root.Close()
+
+	// Create parent directories one level at a time; the root rejects any that escape it
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		if err := root.Mkdir(strings.Join(parts[:i], "/"), 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("failed to create workspace directory: %w", err)
+		}
+	}
+
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write workspace file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write workspace file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write workspace file: %w", err)
+	}
+	return nil
+}
+
+// RemoveFile deletes the named workspace file
+func (w *Workspace) RemoveFile(name string) error {
+	name, err := cleanWorkspaceName(name)
+	if err != nil {
+		return err
+	}
+	root, err := os.OpenRoot(w.dir)
+	if err != nil {
+		return fmt.Errorf("failed to open workspace: %w", err)
+	}
+	defer root.Close()
+
+	if err := root.Remove(name); err != nil {
+		return fmt.Errorf("failed to remove workspace file: %w", err)
+	}
+	return nil
+}
+
+// cleanWorkspaceName validates a workspace file name and returns it in canonical form
+func cleanWorkspaceName(name string) (string, error) {
+	cleaned := path.Clean(filepath.ToSlash(name))
+	if !fs.ValidPath(cleaned) || cleaned == "." {
+		return "", fmt.Errorf("invalid workspace file name %q", name)
+	}
+	return cleaned, nil
+}
+
+// GetPlan returns the contents of the agent's plan, plan.md in the session workspace.
+// An empty string is returned if the agent has not written a plan yet.
+//
+// Returns an error if infinite sessions are not enabled for this session.
+func (s *Session) GetPlan() (string, error) {
+	if err := s.requireWorkspace(); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(s.workspacePath, "plan.md"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read plan: %w", err)
+	}
+	return string(data), nil
+}
+
+// OnPlanChanged calls handler with the new plan whenever the agent revises plan.md.
+// The plan is checked after each tool execution and when the session becomes idle.
+// It returns a function that unsubscribes the handler.
+//
+// Example:
+//
+//	unsubscribe := session.OnPlanChanged(func(plan string) {
+//	    ui.RenderPlan(plan)
+//	})
+//	defer unsubscribe()
+func (s *Session) OnPlanChanged(handler func(plan string)) func() {
+	last, _ := s.GetPlan()
+	return s.On(func(event SessionEvent) {
+		if event.Type != ToolExecutionComplete && event.Type != SessionIdle {
+			return
+		}
+		plan, err := s.GetPlan()
+		if err != nil || plan == last {
+			return
+		}
+		last = plan
+		handler(plan)
+	})
+}
@@ -0,0 +1,120 @@
+package copilot
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSession_Workspace(t *testing.T) {
+	t.Run("requires infinite sessions", func(t *testing.T) {
+		if _, err := newSession("session-1", nil, "").Workspace(); err == nil {
+			t.Error("Expected error without a workspace")
+		}
+	})
+
+	t.Run("writes, lists, reads, and removes files", func(t *testing.T) {
+		dir := t.TempDir()
+		ws, err := newSession("session-1", nil, dir).Workspace()
+		if err != nil {
+			t.Fatalf("Workspace failed: %v", err)
+		}
+		if ws.Dir() != filepath.Join(dir, "files") {
+			t.Errorf("Unexpected dir %q", ws.Dir())
+		}
+
+		if names, err := ws.ListFiles(); err != nil || len(names) != 0 {
+			t.Errorf("Expected no files before files/ exists, got %v, %v", names, err)
+		}
+
+		if err := ws.WriteFile("notes/todo.md", []byte("- ship it")); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if err := ws.WriteFile("a.txt", []byte("a")); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		names, err := ws.ListFiles()
+		if err != nil {
+			t.Fatalf("ListFiles failed: %v", err)
+		}
+		if !slices.Equal(names, []string{"a.txt", "notes/todo.md"}) {
+			t.Errorf("Unexpected files %v", names)
+		}
+
+		data, err := ws.ReadFile("notes/todo.md")
+		if err != nil || string(data) != "- ship it" {
+			t.Errorf("Unexpected contents %q, %v", data, err)
+		}
+
+		if err := ws.RemoveFile("a.txt"); err != nil {
+			t.Fatalf("RemoveFile failed: %v", err)
+		}
+		if _, err := ws.ReadFile("a.txt"); err == nil {
+			t.Error("Expected removed file to be gone")
+		}
+	})
+
+	t.Run("rejects paths outside the workspace", func(t *testing.T) {
+		dir := t.TempDir()
+		ws, _ := newSession("session-1", nil, dir).Workspace()
+		os.WriteFile(filepath.Join(dir, "secret"), []byte("x"), 0o644)
+		os.MkdirAll(ws.Dir(), 0o755)
+
+		for _, name := range []string{"../secret", "notes/../../secret", "/etc/passwd", "", "."} {
+			if _, err := ws.ReadFile(name); err == nil {
+				t.Errorf("Expected ReadFile(%q) to fail", name)
+			}
+			if err := ws.WriteFile(name, []byte("x")); err == nil {
+				t.Errorf("Expected WriteFile(%q) to fail", name)
+			}
+		}
+
+		if err := os.Symlink(dir, filepath.Join(ws.Dir(), "escape")); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+		if _, err := ws.ReadFile("escape/secret"); err == nil {
+			t.Error("Expected reading through a symlink out of the workspace to fail")
+		}
+	})
+}
+
+func TestSession_Plan(t *testing.T) {
+	t.Run("returns an empty plan before one is written", func(t *testing.T) {
+		plan, err := newSession("session-1", nil, t.TempDir()).GetPlan()
+		if err != nil || plan != "" {
+			t.Errorf("Expected empty plan, got %q, %v", plan, err)
+		}
+	})
+
+	t.Run("notifies handlers when the plan changes", func(t *testing.T) {
+		dir := t.TempDir()
+		planPath := filepath.Join(dir, "plan.md")
+		os.WriteFile(planPath, []byte("1. Read code"), 0o644)
+		session := newSession("session-1", nil, dir)
+
+		var plans []string
+		unsubscribe := session.OnPlanChanged(func(plan string) { plans = append(plans, plan) })
+
+		session.dispatchEvent(SessionEvent{Type: ToolExecutionComplete})
+		os.WriteFile(planPath, []byte("1. Read code\n2. Fix bug"), 0o644)
+		session.dispatchEvent(SessionEvent{Type: AssistantMessage})
+		session.dispatchEvent(SessionEvent{Type: ToolExecutionComplete})
+		session.dispatchEvent(SessionEvent{Type: SessionIdle})
+
+		if !slices.Equal(plans, []string{"1. Read code\n2. Fix bug"}) {
+			t.Errorf("Expected one change notification, got %q", plans)
+		}
+		if plan, _ := session.GetPlan(); plan != "1. Read code\n2. Fix bug" {
+			t.Errorf("Unexpected plan %q", plan)
+		}
+
+		unsubscribe()
+		os.WriteFile(planPath, []byte("done"), 0o644)
+		session.dispatchEvent(SessionEvent{Type: SessionIdle})
+		if len(plans) != 1 {
+			t.Errorf("Expected no notification after unsubscribe, got %q", plans)
+		}
+	})
+}